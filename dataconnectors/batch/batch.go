@@ -0,0 +1,170 @@
+package batch
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Called with the combined JSON array of a batch and its metadata. It is called
+// without the batcher lock held and may run concurrently with Add and with the
+// flush of another batch.
+type FlushFunc func(data []byte, metadata map[string]string) error
+
+// Batcher combines JSON messages into a single JSON array, flushed when
+// batch_size messages have been added or batch_interval has elapsed since the
+// first message of the batch, whichever comes first.
+type Batcher struct {
+	size     int
+	interval time.Duration
+	flush    FlushFunc
+
+	mutex      sync.Mutex
+	messages   [][]byte
+	firstTime  time.Time
+	lastTime   time.Time
+	generation int
+	timer      *time.Timer
+}
+
+// Returns nil if neither batch_size nor batch_interval are set
+func NewBatcherFromParams(params map[string]string, flush FlushFunc) (*Batcher, error) {
+	var size int
+	if sizeParam, ok := params["batch_size"]; ok && sizeParam != "" {
+		s, err := strconv.Atoi(sizeParam)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch_size '%s': %s", sizeParam, err)
+		}
+		if s < 1 {
+			return nil, fmt.Errorf("invalid batch_size '%s': size must be >= 1", sizeParam)
+		}
+		size = s
+	}
+
+	var interval time.Duration
+	if intervalParam, ok := params["batch_interval"]; ok && intervalParam != "" {
+		i, err := time.ParseDuration(intervalParam)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch_interval '%s': %s", intervalParam, err)
+		}
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid batch_interval '%s': interval must be > 0", intervalParam)
+		}
+		interval = i
+	}
+
+	if size == 0 && interval == 0 {
+		return nil, nil
+	}
+
+	return NewBatcher(size, interval, flush), nil
+}
+
+// A size or interval of zero disables the corresponding flush trigger
+func NewBatcher(size int, interval time.Duration, flush FlushFunc) *Batcher {
+	return &Batcher{
+		size:     size,
+		interval: interval,
+		flush:    flush,
+	}
+}
+
+// Adds a JSON message with its timestamp to the current batch
+func (b *Batcher) Add(message []byte, messageTime time.Time) error {
+	b.mutex.Lock()
+
+	if len(b.messages) == 0 {
+		b.firstTime = messageTime
+		b.lastTime = messageTime
+		if b.interval > 0 {
+			generation := b.generation
+			b.timer = time.AfterFunc(b.interval, func() {
+				b.flushGeneration(generation)
+			})
+		}
+	}
+
+	if messageTime.Before(b.firstTime) {
+		b.firstTime = messageTime
+	}
+	if messageTime.After(b.lastTime) {
+		b.lastTime = messageTime
+	}
+
+	b.messages = append(b.messages, message)
+
+	if b.size == 0 || len(b.messages) < b.size {
+		b.mutex.Unlock()
+		return nil
+	}
+
+	data, metadata := b.takeLocked()
+	b.mutex.Unlock()
+
+	return b.flush(data, metadata)
+}
+
+// Flushes the current batch, if any
+func (b *Batcher) Flush() error {
+	b.mutex.Lock()
+	data, metadata := b.takeLocked()
+	b.mutex.Unlock()
+
+	if data == nil {
+		return nil
+	}
+
+	return b.flush(data, metadata)
+}
+
+func (b *Batcher) flushGeneration(generation int) {
+	b.mutex.Lock()
+	if generation != b.generation {
+		// Batch already flushed
+		b.mutex.Unlock()
+		return
+	}
+	data, metadata := b.takeLocked()
+	b.mutex.Unlock()
+
+	if data == nil {
+		return
+	}
+
+	err := b.flush(data, metadata)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// Removes the current batch and returns its combined data, or nil if it is empty.
+// Must be called with the mutex held; the flush func is called after unlocking
+// so a slow handler does not block Add.
+func (b *Batcher) takeLocked() ([]byte, map[string]string) {
+	if len(b.messages) == 0 {
+		return nil, nil
+	}
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	data := make([]byte, 0, 2+len(b.messages)*len(b.messages[0]))
+	data = append(data, '[')
+	data = append(data, bytes.Join(b.messages, []byte{','})...)
+	data = append(data, ']')
+
+	metadata := map[string]string{}
+	metadata["batch_count"] = strconv.Itoa(len(b.messages))
+	metadata["batch_start"] = b.firstTime.Format(time.RFC3339Nano)
+	metadata["batch_end"] = b.lastTime.Format(time.RFC3339Nano)
+
+	b.messages = nil
+	b.generation++
+
+	return data, metadata
+}
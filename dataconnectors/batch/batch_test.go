@@ -0,0 +1,151 @@
+package batch_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spiceai/data-components-contrib/dataconnectors/batch"
+	"github.com/stretchr/testify/assert"
+)
+
+type flushed struct {
+	data     string
+	metadata map[string]string
+}
+
+func TestNewBatcherFromParams(t *testing.T) {
+	noop := func(data []byte, metadata map[string]string) error { return nil }
+
+	b, err := batch.NewBatcherFromParams(map[string]string{}, noop)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = batch.NewBatcherFromParams(map[string]string{"batch_size": "10"}, noop)
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	b, err = batch.NewBatcherFromParams(map[string]string{"batch_interval": "1s"}, noop)
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	_, err = batch.NewBatcherFromParams(map[string]string{"batch_size": "0"}, noop)
+	assert.Error(t, err)
+
+	_, err = batch.NewBatcherFromParams(map[string]string{"batch_size": "abc"}, noop)
+	assert.Error(t, err)
+
+	_, err = batch.NewBatcherFromParams(map[string]string{"batch_interval": "-1s"}, noop)
+	assert.Error(t, err)
+}
+
+func TestBatcherSize(t *testing.T) {
+	var batches []flushed
+	b := batch.NewBatcher(3, 0, func(data []byte, metadata map[string]string) error {
+		batches = append(batches, flushed{data: string(data), metadata: metadata})
+		return nil
+	})
+
+	start := time.Unix(1633421096, 0).UTC()
+	for i := 0; i < 7; i++ {
+		err := b.Add([]byte(`{"i":1}`), start.Add(time.Duration(i)*time.Second))
+		assert.NoError(t, err)
+	}
+
+	if assert.Len(t, batches, 2) {
+		assert.Equal(t, `[{"i":1},{"i":1},{"i":1}]`, batches[0].data)
+		assert.Equal(t, "3", batches[0].metadata["batch_count"])
+		assert.Equal(t, "2021-10-05T08:04:56Z", batches[0].metadata["batch_start"])
+		assert.Equal(t, "2021-10-05T08:04:58Z", batches[0].metadata["batch_end"])
+		assert.Equal(t, "2021-10-05T08:04:59Z", batches[1].metadata["batch_start"])
+	}
+
+	err := b.Flush()
+	assert.NoError(t, err)
+	if assert.Len(t, batches, 3) {
+		assert.Equal(t, `[{"i":1}]`, batches[2].data)
+		assert.Equal(t, "1", batches[2].metadata["batch_count"])
+	}
+
+	// Nothing left to flush
+	err = b.Flush()
+	assert.NoError(t, err)
+	assert.Len(t, batches, 3)
+}
+
+func TestBatcherInterval(t *testing.T) {
+	flushedChan := make(chan flushed, 2)
+	b := batch.NewBatcher(0, 50*time.Millisecond, func(data []byte, metadata map[string]string) error {
+		flushedChan <- flushed{data: string(data), metadata: metadata}
+		return nil
+	})
+
+	now := time.Now()
+	assert.NoError(t, b.Add([]byte(`1`), now))
+	assert.NoError(t, b.Add([]byte(`2`), now))
+
+	select {
+	case batch := <-flushedChan:
+		assert.Equal(t, `[1,2]`, batch.data)
+		assert.Equal(t, "2", batch.metadata["batch_count"])
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed on interval")
+	}
+}
+
+func TestBatcherSizeAndInterval(t *testing.T) {
+	mutex := sync.Mutex{}
+	var batches []flushed
+	b := batch.NewBatcher(2, 50*time.Millisecond, func(data []byte, metadata map[string]string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		batches = append(batches, flushed{data: string(data), metadata: metadata})
+		return nil
+	})
+
+	now := time.Now()
+	assert.NoError(t, b.Add([]byte(`1`), now))
+	assert.NoError(t, b.Add([]byte(`2`), now))
+	assert.NoError(t, b.Add([]byte(`3`), now))
+
+	time.Sleep(200 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// The interval timer of the first batch must not flush the second batch twice
+	if assert.Len(t, batches, 2) {
+		assert.Equal(t, `[1,2]`, batches[0].data)
+		assert.Equal(t, `[3]`, batches[1].data)
+	}
+}
+
+func TestBatcherSlowFlush(t *testing.T) {
+	flushing := make(chan struct{})
+	release := make(chan struct{})
+	b := batch.NewBatcher(0, 10*time.Millisecond, func(data []byte, metadata map[string]string) error {
+		if string(data) == `[1]` {
+			close(flushing)
+			<-release
+		}
+		return nil
+	})
+	defer close(release)
+
+	now := time.Now()
+	assert.NoError(t, b.Add([]byte(`1`), now))
+	<-flushing
+
+	// The interval flush of the first batch is still in progress and must not block Add
+	added := make(chan error)
+	go func() {
+		added <- b.Add([]byte(`2`), now)
+	}()
+
+	select {
+	case err := <-added:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Add was blocked by a slow flush")
+	}
+}
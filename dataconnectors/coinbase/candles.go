@@ -0,0 +1,165 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// Coinbase returns at most 300 candles per request
+	maxCandlesPerRequest = 300
+)
+
+var (
+	supportedGranularities = []time.Duration{
+		time.Minute,
+		5 * time.Minute,
+		15 * time.Minute,
+		time.Hour,
+		6 * time.Hour,
+		24 * time.Hour,
+	}
+)
+
+// Candle is a historic rate entry converted to the same shape as ticker messages
+type Candle struct {
+	Type      string `json:"type"`
+	ProductID string `json:"product_id"`
+	Time      string `json:"time"`
+	Price     string `json:"price"`
+	Open      string `json:"open"`
+	High      string `json:"high"`
+	Low       string `json:"low"`
+	Close     string `json:"close"`
+	Volume    string `json:"volume"`
+
+	time time.Time
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func getGranularity(interval time.Duration) (time.Duration, error) {
+	if interval == 0 {
+		return time.Minute, nil
+	}
+
+	for _, granularity := range supportedGranularities {
+		if interval == granularity {
+			return granularity, nil
+		}
+	}
+
+	return 0, fmt.Errorf("interval %s is not a supported candle granularity (1m, 5m, 15m, 1h, 6h or 24h)", interval)
+}
+
+// Fetches candles for all products over [start, end) sorted by time, without duplicates
+func fetchCandles(client *http.Client, productIds []string, start time.Time, end time.Time, granularity time.Duration) ([]*Candle, error) {
+	var candles []*Candle
+
+	pageDuration := granularity * maxCandlesPerRequest
+	for _, productId := range productIds {
+		seen := make(map[int64]bool)
+		for pageStart := start; pageStart.Before(end); pageStart = pageStart.Add(pageDuration) {
+			pageEnd := pageStart.Add(pageDuration)
+			if pageEnd.After(end) {
+				pageEnd = end
+			}
+
+			pageCandles, err := fetchCandlesPage(client, productId, pageStart, pageEnd, granularity)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, candle := range pageCandles {
+				// Pages overlap on their boundaries
+				if seen[candle.time.Unix()] || candle.time.Before(start) || !candle.time.Before(end) {
+					continue
+				}
+				seen[candle.time.Unix()] = true
+				candles = append(candles, candle)
+			}
+		}
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool {
+		if candles[i].time.Equal(candles[j].time) {
+			return candles[i].ProductID < candles[j].ProductID
+		}
+		return candles[i].time.Before(candles[j].time)
+	})
+
+	return candles, nil
+}
+
+func fetchCandlesPage(client *http.Client, productId string, start time.Time, end time.Time, granularity time.Duration) ([]*Candle, error) {
+	query := url.Values{}
+	query.Set("granularity", strconv.FormatInt(int64(granularity.Seconds()), 10))
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+
+	requestUrl := fmt.Sprintf("%s/products/%s/candles?%s", restUrl, url.PathEscape(productId), query.Encode())
+	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("User-Agent", "spiceai-coinbase-connector")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch candles for %s: %w", productId, err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read candles for %s: %w", productId, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var errResponse errorResponse
+		if err := json.Unmarshal(body, &errResponse); err == nil && errResponse.Message != "" {
+			return nil, fmt.Errorf("failed to fetch candles for %s: %s (status code %d)", productId, errResponse.Message, response.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to fetch candles for %s: status code %d", productId, response.StatusCode)
+	}
+
+	// Each entry is [ time, low, high, open, close, volume ]
+	var rates [][6]float64
+	err = json.Unmarshal(body, &rates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid candles received for %s: %w", productId, err)
+	}
+
+	candles := make([]*Candle, 0, len(rates))
+	for _, rate := range rates {
+		candleTime := time.Unix(int64(rate[0]), 0).UTC()
+		closePrice := formatPrice(rate[4])
+		candles = append(candles, &Candle{
+			Type:      "candle",
+			ProductID: productId,
+			Time:      candleTime.Format(time.RFC3339Nano),
+			Price:     closePrice,
+			Low:       formatPrice(rate[1]),
+			High:      formatPrice(rate[2]),
+			Open:      formatPrice(rate[3]),
+			Close:     closePrice,
+			Volume:    formatPrice(rate[5]),
+			time:      candleTime,
+		})
+	}
+
+	return candles, nil
+}
+
+func formatPrice(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
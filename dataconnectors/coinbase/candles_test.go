@@ -0,0 +1,172 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGranularity(t *testing.T) {
+	granularity, err := getGranularity(0)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, granularity)
+
+	granularity, err = getGranularity(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, granularity)
+
+	_, err = getGranularity(2 * time.Minute)
+	assert.Error(t, err)
+}
+
+func TestFetchCandles(t *testing.T) {
+	start := time.Unix(1633420800, 0).UTC()
+	end := start.Add(400 * time.Minute)
+
+	server := httptest.NewServer(newCandlesHandler(t, time.Minute))
+	defer server.Close()
+	setRestUrl(t, server.URL)
+
+	candles, err := fetchCandles(server.Client(), []string{"BTC-USD", "ETH-USD"}, start, end, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 400 candles per product, without the candles duplicated on page boundaries
+	assert.Len(t, candles, 800)
+
+	for i := 1; i < len(candles); i++ {
+		assert.False(t, candles[i].time.Before(candles[i-1].time), "candles must be ordered by time")
+	}
+	assert.Equal(t, "BTC-USD", candles[0].ProductID)
+	assert.Equal(t, "ETH-USD", candles[1].ProductID)
+	assert.Equal(t, start.Format(time.RFC3339Nano), candles[0].Time)
+	assert.Equal(t, "1633420800", candles[0].Close)
+	assert.Equal(t, candles[0].Close, candles[0].Price)
+}
+
+func TestFetchCandlesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message":"NotFound"}`))
+	}))
+	defer server.Close()
+	setRestUrl(t, server.URL)
+
+	start := time.Unix(1633420800, 0).UTC()
+	_, err := fetchCandles(server.Client(), []string{"BTC-USD"}, start, start.Add(time.Hour), time.Minute)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "NotFound")
+	}
+}
+
+func TestInitWithBackfill(t *testing.T) {
+	period := 10 * time.Minute
+	nowTime := time.Unix(1633421400, 0).UTC()
+	now = clockwork.NewFakeClockAt(nowTime).Now
+	t.Cleanup(func() {
+		now = time.Now
+	})
+
+	restServer := httptest.NewServer(newCandlesHandler(t, time.Minute))
+	defer restServer.Close()
+	setRestUrl(t, restServer.URL)
+
+	staleTicker := fmt.Sprintf(`{"type":"ticker","product_id":"BTC-USD","price":"1","time":"%s"}`, nowTime.Add(-5*time.Minute).Format(time.RFC3339Nano))
+	lastCandleTicker := fmt.Sprintf(`{"type":"ticker","product_id":"BTC-USD","price":"2","time":"%s"}`, nowTime.Add(-30*time.Second).Format(time.RFC3339Nano))
+	liveTicker := fmt.Sprintf(`{"type":"ticker","product_id":"BTC-USD","price":"3","time":"%s"}`, nowTime.Format(time.RFC3339Nano))
+
+	setFeedServer(t, staleTicker, lastCandleTicker, liveTicker)
+
+	c := NewCoinbaseConnector()
+
+	var messages []string
+	var allMetadata []map[string]string
+	messagesMutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		messagesMutex.Lock()
+		defer messagesMutex.Unlock()
+		messages = append(messages, string(data))
+		allMetadata = append(allMetadata, metadata)
+		wg.Done()
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.Init(time.Time{}, period, time.Minute, map[string]string{
+		"product_ids": "BTC-USD",
+		"backfill":    "true",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, "backfill", allMetadata[0]["type"])
+	assert.Equal(t, nowTime.Add(-period).Format(time.RFC3339), allMetadata[0]["start"])
+	assert.Equal(t, nowTime.Format(time.RFC3339), allMetadata[0]["end"])
+
+	var candles []Candle
+	err = json.Unmarshal([]byte(messages[0]), &candles)
+	if assert.NoError(t, err) {
+		assert.Len(t, candles, 10)
+	}
+
+	// The stale ticker and the ticker within the last candle are covered by the backfill and must be dropped
+	assert.Equal(t, liveTicker, messages[1])
+}
+
+func TestInitWithBackfillNoPeriod(t *testing.T) {
+	c := NewCoinbaseConnector()
+
+	err := c.Init(time.Time{}, 0, time.Minute, map[string]string{
+		"product_ids": "BTC-USD",
+		"backfill":    "true",
+	})
+	assert.Error(t, err)
+}
+
+// Serves one candle per granularity step in [start, end], newest first like the Coinbase API
+func newCandlesHandler(t *testing.T, granularity time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, strconv.Itoa(int(granularity.Seconds())), query.Get("granularity"))
+
+		start, err := time.Parse(time.RFC3339, query.Get("start"))
+		assert.NoError(t, err)
+		end, err := time.Parse(time.RFC3339, query.Get("end"))
+		assert.NoError(t, err)
+
+		assert.LessOrEqual(t, int(end.Sub(start)/granularity), maxCandlesPerRequest)
+
+		var rates [][6]float64
+		for candleTime := end; !candleTime.Before(start); candleTime = candleTime.Add(-granularity) {
+			value := float64(candleTime.Unix())
+			rates = append(rates, [6]float64{value, value, value, value, value, 1})
+		}
+
+		data, err := json.Marshal(rates)
+		assert.NoError(t, err)
+		_, _ = w.Write(data)
+	})
+}
+
+func setRestUrl(t *testing.T, url string) {
+	originalRestUrl := restUrl
+	restUrl = url
+	t.Cleanup(func() {
+		restUrl = originalRestUrl
+	})
+}
@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/logrusorgru/aurora"
+	"github.com/spiceai/data-components-contrib/dataconnectors/batch"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -24,6 +27,8 @@ type SubscribeRequest struct {
 type MessageHeaders struct {
 	MessageType string `json:"type,omitempty"`
 	Sequence    *int   `sequence:"type,omitempty"`
+	ProductID   string `json:"product_id,omitempty"`
+	Time        string `json:"time,omitempty"`
 }
 
 type Subscriptions struct {
@@ -48,8 +53,20 @@ const (
 	CoinbaseConnectorName string = "coinbase"
 )
 
+var (
+	now        = time.Now
+	feedUrl    = url.URL{Scheme: "wss", Host: "ws-feed.exchange.coinbase.com"}
+	restUrl    = "https://api.exchange.coinbase.com"
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
 type CoinbaseConnector struct {
 	readHandlers []*func(data []byte, metadata map[string]string) ([]byte, error)
+	batcher      *batch.Batcher
+
+	// End of the last backfilled candle per product, used to drop stale live messages
+	backfillEndsMutex sync.RWMutex
+	backfillEnds      map[string]time.Time
 }
 
 func NewCoinbaseConnector() *CoinbaseConnector {
@@ -62,10 +79,23 @@ func (c *CoinbaseConnector) Init(epoch time.Time, period time.Duration, interval
 		return errors.New("product_ids is required")
 	}
 
+	batcher, err := batch.NewBatcherFromParams(params, c.sendToHandlers)
+	if err != nil {
+		return err
+	}
+	c.batcher = batcher
+
 	channels := []string{"ticker", "heartbeat"}
 	productIds := strings.Split(pids, ",")
 
-	u := url.URL{Scheme: "wss", Host: "ws-feed.exchange.coinbase.com"}
+	if params["backfill"] == "true" {
+		err = c.backfill(epoch, period, interval, productIds)
+		if err != nil {
+			return err
+		}
+	}
+
+	u := feedUrl
 	log.Printf("connecting to %s\n", u.String())
 
 	wsClient, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
@@ -89,6 +119,12 @@ func (c *CoinbaseConnector) Init(epoch time.Time, period time.Duration, interval
 		for {
 			_, message, err := wsClient.ReadMessage()
 			if err != nil {
+				if c.batcher != nil {
+					// Don't lose a partial batch when the feed closes
+					if err := c.batcher.Flush(); err != nil {
+						log.Println(err.Error())
+					}
+				}
 				return
 			}
 			c.sendData(message)
@@ -103,6 +139,82 @@ func (c *CoinbaseConnector) Read(handler func(data []byte, metadata map[string]s
 	return nil
 }
 
+func (c *CoinbaseConnector) backfill(epoch time.Time, period time.Duration, interval time.Duration, productIds []string) error {
+	if period <= 0 {
+		return errors.New("backfill requires a period to be set")
+	}
+
+	granularity, err := getGranularity(interval)
+	if err != nil {
+		return err
+	}
+
+	var start time.Time
+	var end time.Time
+	if epoch.IsZero() {
+		end = now().UTC()
+		start = end.Add(-period)
+	} else {
+		start = epoch.UTC()
+		end = start.Add(period)
+	}
+
+	log.Printf("coinbase connector backfilling %s candles from %s to %s", aurora.BrightBlue(granularity), start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+	candles, err := fetchCandles(httpClient, productIds, start, end, granularity)
+	if err != nil {
+		return err
+	}
+
+	// A candle covers the ticks from its start time up to one granularity later
+	c.backfillEndsMutex.Lock()
+	c.backfillEnds = make(map[string]time.Time)
+	for _, candle := range candles {
+		c.backfillEnds[candle.ProductID] = candle.time.Add(granularity)
+	}
+	c.backfillEndsMutex.Unlock()
+
+	if len(candles) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(candles)
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]string{}
+	metadata["type"] = "backfill"
+	metadata["start"] = start.Format(time.RFC3339)
+	metadata["end"] = end.Format(time.RFC3339)
+
+	// Sent before the feed is dialed, so handlers get the backfill before any live message
+	err = c.sendToHandlers(data, metadata)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	return nil
+}
+
+// Live messages before the end of the last backfilled candle for the same product are duplicates
+func (c *CoinbaseConnector) isBackfilled(headers *MessageHeaders) bool {
+	c.backfillEndsMutex.RLock()
+	defer c.backfillEndsMutex.RUnlock()
+
+	backfillEnd, ok := c.backfillEnds[headers.ProductID]
+	if !ok || headers.Time == "" {
+		return false
+	}
+
+	messageTime, err := time.Parse(time.RFC3339Nano, headers.Time)
+	if err != nil {
+		return false
+	}
+
+	return messageTime.Before(backfillEnd)
+}
+
 func (c *CoinbaseConnector) sendData(data []byte) {
 	if len(c.readHandlers) == 0 {
 		// Nothing to read
@@ -140,14 +252,37 @@ func (c *CoinbaseConnector) sendData(data []byte) {
 		return
 	}
 
+	if c.isBackfilled(&headers) {
+		return
+	}
+
+	if c.batcher != nil {
+		messageTime, err := time.Parse(time.RFC3339Nano, headers.Time)
+		if err != nil {
+			messageTime = now().UTC()
+		}
+		err = c.batcher.Add(data, messageTime)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		return
+	}
+
 	metadata := map[string]string{}
 
-	errGroup, _ := errgroup.WithContext(context.Background())
+	err = c.sendToHandlers(data, metadata)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
 
+func (c *CoinbaseConnector) sendToHandlers(data []byte, metadata map[string]string) error {
 	if len(c.readHandlers) == 0 {
-		return
+		return nil
 	}
 
+	errGroup, _ := errgroup.WithContext(context.Background())
+
 	for _, handler := range c.readHandlers {
 		readHandler := *handler
 		errGroup.Go(func() error {
@@ -156,8 +291,5 @@ func (c *CoinbaseConnector) sendData(data []byte) {
 		})
 	}
 
-	err = errGroup.Wait()
-	if err != nil {
-		log.Println(err.Error())
-	}
+	return errGroup.Wait()
 }
@@ -0,0 +1,107 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitWithBatching(t *testing.T) {
+	start := time.Unix(1633421096, 0).UTC()
+
+	var tickers []string
+	for i := 0; i < 5; i++ {
+		tickerTime := start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano)
+		tickers = append(tickers, fmt.Sprintf(`{"type":"ticker","product_id":"BTC-USD","price":"%d","time":"%s"}`, i, tickerTime))
+	}
+	setFeedServer(t, tickers...)
+
+	c := NewCoinbaseConnector()
+
+	batches := make(chan map[string]string, 3)
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		var messages []map[string]string
+		err := json.Unmarshal(data, &messages)
+		assert.NoError(t, err)
+		assert.Equal(t, metadata["batch_count"], fmt.Sprintf("%d", len(messages)))
+		batches <- metadata
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.Init(time.Time{}, 0, 0, map[string]string{
+		"product_ids":    "BTC-USD",
+		"batch_size":     "2",
+		"batch_interval": "100ms",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expected := []struct {
+		count string
+		start string
+		end   string
+	}{
+		{"2", "2021-10-05T08:04:56Z", "2021-10-05T08:04:57Z"},
+		{"2", "2021-10-05T08:04:58Z", "2021-10-05T08:04:59Z"},
+		// Last ticker is flushed on interval
+		{"1", "2021-10-05T08:05:00Z", "2021-10-05T08:05:00Z"},
+	}
+	for _, e := range expected {
+		select {
+		case metadata := <-batches:
+			assert.Equal(t, e.count, metadata["batch_count"])
+			assert.Equal(t, e.start, metadata["batch_start"])
+			assert.Equal(t, e.end, metadata["batch_end"])
+		case <-time.After(time.Second):
+			t.Fatal("expected batch was not received")
+		}
+	}
+}
+
+func TestInitWithInvalidBatchSize(t *testing.T) {
+	c := NewCoinbaseConnector()
+
+	err := c.Init(time.Time{}, 0, 0, map[string]string{
+		"product_ids": "BTC-USD",
+		"batch_size":  "-1",
+	})
+	assert.Error(t, err)
+}
+
+// Replaces the Coinbase websocket feed with a local server sending the given messages after subscription
+func setFeedServer(t *testing.T, messages ...string) {
+	upgrader := websocket.Upgrader{}
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var subReq SubscribeRequest
+		if err := conn.ReadJSON(&subReq); err != nil {
+			return
+		}
+		for _, message := range messages {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+		_, _, _ = conn.ReadMessage()
+	}))
+
+	originalFeedUrl := feedUrl
+	feedUrl.Scheme = "ws"
+	feedUrl.Host = strings.TrimPrefix(feedServer.URL, "http://")
+	t.Cleanup(func() {
+		feedUrl = originalFeedUrl
+		feedServer.Close()
+	})
+}
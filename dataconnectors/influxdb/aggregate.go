@@ -0,0 +1,129 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	fillNull     = "null"
+	fillPrevious = "previous"
+)
+
+// Flux aggregates supported by the fn param
+var aggregateFunctions = map[string]bool{
+	"mean":     true,
+	"median":   true,
+	"sum":      true,
+	"count":    true,
+	"min":      true,
+	"max":      true,
+	"last":     true,
+	"first":    true,
+	"quantile": true,
+}
+
+// Aggregation applied to each interval window of the query
+type aggregate struct {
+	fn          string
+	quantile    float64
+	createEmpty bool
+	// Empty windows are left null, filled with the previous value or with a constant
+	fill string
+}
+
+func parseAggregate(params map[string]string) (*aggregate, error) {
+	a := &aggregate{
+		// Default to "mean"
+		fn: "mean",
+	}
+
+	if fn, ok := params["fn"]; ok {
+		fn = strings.TrimSpace(fn)
+		if !aggregateFunctions[fn] {
+			return nil, fmt.Errorf("unsupported fn '%s': must be one of mean, median, sum, count, min, max, last, first or quantile", fn)
+		}
+		a.fn = fn
+	}
+
+	if quantile, ok := params["quantile"]; ok {
+		if a.fn != "quantile" {
+			return nil, fmt.Errorf("the quantile parameter requires fn to be 'quantile', got '%s'", a.fn)
+		}
+		q, err := strconv.ParseFloat(quantile, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile '%s': %s", quantile, err)
+		}
+		if q < 0 || q > 1 {
+			return nil, fmt.Errorf("invalid quantile '%s': quantile must be between 0 and 1", quantile)
+		}
+		a.quantile = q
+	} else if a.fn == "quantile" {
+		return nil, errors.New("fn 'quantile' requires the 'quantile' parameter to be set, e.g. 0.99")
+	}
+
+	if createEmpty, ok := params["create_empty"]; ok {
+		value, err := strconv.ParseBool(createEmpty)
+		if err != nil {
+			return nil, fmt.Errorf("invalid create_empty '%s': must be true or false", createEmpty)
+		}
+		a.createEmpty = value
+	}
+
+	if fill, ok := params["fill"]; ok {
+		fill = strings.TrimSpace(fill)
+		if !a.createEmpty {
+			return nil, errors.New("the fill parameter requires create_empty to be true")
+		}
+		if a.fn == "count" {
+			return nil, errors.New("the fill parameter is not supported with fn 'count', empty windows are counted as 0")
+		}
+		switch fill {
+		case fillNull, fillPrevious:
+		default:
+			if _, err := strconv.ParseFloat(fill, 64); err != nil {
+				return nil, fmt.Errorf("invalid fill '%s': must be null, previous or a number", fill)
+			}
+		}
+		a.fill = fill
+	}
+
+	return a, nil
+}
+
+// Returns the Flux pipeline aggregating each window of the given interval
+func (a *aggregate) flux(every string) string {
+	fn := a.fn
+	if a.fn == "quantile" {
+		fn = fmt.Sprintf("(column, tables=<-) => tables |> quantile(q: %s, column: column)", formatFloat(a.quantile))
+	}
+
+	pipeline := fmt.Sprintf("aggregateWindow(every: %s, fn: %s, createEmpty: %t)", every, fn, a.createEmpty)
+
+	if a.fn == "count" {
+		// Counts are integers, but the flux-csv processor reads float values
+		pipeline += " |>\n\t\ttoFloat()"
+	}
+
+	switch a.fill {
+	case "", fillNull:
+	case fillPrevious:
+		pipeline += " |>\n\t\tfill(usePrevious: true)"
+	default:
+		value, _ := strconv.ParseFloat(a.fill, 64)
+		pipeline += fmt.Sprintf(" |>\n\t\tfill(value: %s)", formatFloat(value))
+	}
+
+	return pipeline
+}
+
+// Formats a float as a Flux float literal, which requires a decimal point
+func formatFloat(value float64) string {
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.Contains(formatted, ".") {
+		formatted += ".0"
+	}
+	return formatted
+}
@@ -0,0 +1,211 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChunkRetries = 2
+)
+
+// A sub-window of the period fetched with its own query
+type chunk struct {
+	index int
+	start time.Time
+	end   time.Time
+}
+
+// Splits the period into chunks of chunkPeriod. Chunk bounds are aligned to multiples of chunkPeriod since the Unix
+// epoch, like aggregation windows, so that no window is split across chunks. A chunkPeriod of 0 returns the whole period
+// as a single chunk.
+func splitPeriod(periodStart time.Time, periodEnd time.Time, chunkPeriod time.Duration) []chunk {
+	if chunkPeriod <= 0 {
+		return []chunk{{start: periodStart, end: periodEnd}}
+	}
+
+	var chunks []chunk
+	start := periodStart
+	end := periodStart.Add(-time.Duration(periodStart.UnixNano() % int64(chunkPeriod)))
+	for start.Before(periodEnd) {
+		end = end.Add(chunkPeriod)
+		if end.After(periodEnd) {
+			end = periodEnd
+		}
+		chunks = append(chunks, chunk{index: len(chunks), start: start, end: end})
+		start = end
+	}
+	return chunks
+}
+
+func (c *InfluxDbConnector) parseChunkParams(params map[string]string, interval time.Duration) error {
+	if chunkPeriod, ok := params["chunk_period"]; ok {
+		cp, err := time.ParseDuration(chunkPeriod)
+		if err != nil {
+			return fmt.Errorf("invalid chunk_period '%s': %s", chunkPeriod, err)
+		}
+		if cp < 0 {
+			return fmt.Errorf("invalid chunk_period '%s': period must be >= 0", chunkPeriod)
+		}
+		if cp > 0 && interval > 0 && cp%interval != 0 {
+			return fmt.Errorf("invalid chunk_period '%s': period must be a multiple of the interval %s", chunkPeriod, interval)
+		}
+		c.chunkPeriod = cp
+	}
+
+	if chunkConcurrency, ok := params["chunk_concurrency"]; ok {
+		cc, err := strconv.Atoi(chunkConcurrency)
+		if err != nil || cc < 1 {
+			return fmt.Errorf("invalid chunk_concurrency '%s': must be an integer >= 1", chunkConcurrency)
+		}
+		c.chunkConcurrency = cc
+	}
+
+	if chunkRetries, ok := params["chunk_retries"]; ok {
+		cr, err := strconv.Atoi(chunkRetries)
+		if err != nil || cr < 0 {
+			return fmt.Errorf("invalid chunk_retries '%s': must be an integer >= 0", chunkRetries)
+		}
+		c.chunkRetries = cr
+	}
+
+	return nil
+}
+
+// Fetches and sends the chunks not completed yet with up to chunkConcurrency queries at once, and marks the chunks
+// sent as completed. After a chunk fails all its retries, no new chunk is started.
+func (c *InfluxDbConnector) fetchChunks(chunks []chunk, completed []bool, interval time.Duration) error {
+	chunked := len(chunks) > 1
+
+	var remaining []chunk
+	for i, ch := range chunks {
+		if !completed[i] {
+			remaining = append(remaining, ch)
+		}
+	}
+
+	concurrency := c.chunkConcurrency
+	if concurrency > len(remaining) {
+		concurrency = len(remaining)
+	}
+
+	var mutex sync.Mutex
+	var firstErr error
+
+	chunksChan := make(chan chunk)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ch := range chunksChan {
+				mutex.Lock()
+				failed := firstErr != nil
+				mutex.Unlock()
+				if failed {
+					// Chunks handed out before the failure was seen are not fetched
+					continue
+				}
+
+				data, err := c.fetchChunk(ch, interval, chunked)
+				if err == nil {
+					err = c.sendChunk(data, ch, len(chunks), chunked)
+				}
+				mutex.Lock()
+				if err == nil {
+					completed[ch.index] = true
+				} else if firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, ch := range remaining {
+		mutex.Lock()
+		failed := firstErr != nil
+		mutex.Unlock()
+		if failed || c.isClosed() {
+			break
+		}
+		chunksChan <- ch
+	}
+	close(chunksChan)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	for _, done := range completed {
+		if !done {
+			return errors.New("connector closed before fetching all chunks")
+		}
+	}
+	return nil
+}
+
+// Queries the chunk, retrying failed queries of chunked periods with backoff
+func (c *InfluxDbConnector) fetchChunk(ch chunk, interval time.Duration, chunked bool) ([]byte, error) {
+	retries := 0
+	if chunked {
+		retries = c.chunkRetries
+	}
+
+	backoff := time.Duration(0)
+	for attempt := 0; ; attempt++ {
+		data, err := c.query(ch.start, ch.end, interval)
+		if err == nil {
+			return data, nil
+		}
+
+		if attempt >= retries {
+			if chunked {
+				return nil, fmt.Errorf("failed to fetch chunk %s - %s: %w", ch.start.Format(time.RFC3339), ch.end.Format(time.RFC3339), err)
+			}
+			return nil, err
+		}
+
+		backoff = c.nextBackoff(backoff)
+		log.Printf("InfluxDb chunk %s - %s query failed, retrying in %s: %v\n", ch.start.Format(time.RFC3339), ch.end.Format(time.RFC3339), backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *InfluxDbConnector) sendChunk(data []byte, ch chunk, chunkCount int, chunked bool) error {
+	metadata := map[string]string{
+		"start": ch.start.Format(time.RFC3339),
+		"end":   ch.end.Format(time.RFC3339),
+	}
+	if chunked {
+		metadata["chunk"] = strconv.Itoa(ch.index)
+		metadata["chunk_count"] = strconv.Itoa(chunkCount)
+	}
+
+	// Chunks are sent one at a time
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.data = data
+	return c.sendData(metadata)
+}
+
+func (c *InfluxDbConnector) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
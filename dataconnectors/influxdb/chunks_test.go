@@ -0,0 +1,256 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/api"
+	"github.com/influxdata/influxdb-client-go/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+var rangeRegexp = regexp.MustCompile(`range\(start: (\S+), stop: (\S+)\)`)
+
+func TestSplitPeriod(t *testing.T) {
+	start := time.Date(2021, 7, 4, 23, 4, 56, 0, time.UTC)
+	end := time.Date(2021, 7, 7, 23, 4, 56, 0, time.UTC)
+
+	assert.Equal(t, []chunk{{start: start, end: end}}, splitPeriod(start, end, 0))
+
+	assert.Equal(t, []chunk{
+		{index: 0, start: start, end: time.Date(2021, 7, 5, 0, 0, 0, 0, time.UTC)},
+		{index: 1, start: time.Date(2021, 7, 5, 0, 0, 0, 0, time.UTC), end: time.Date(2021, 7, 6, 0, 0, 0, 0, time.UTC)},
+		{index: 2, start: time.Date(2021, 7, 6, 0, 0, 0, 0, time.UTC), end: time.Date(2021, 7, 7, 0, 0, 0, 0, time.UTC)},
+		{index: 3, start: time.Date(2021, 7, 7, 0, 0, 0, 0, time.UTC), end: end},
+	}, splitPeriod(start, end, 24*time.Hour))
+
+	aligned := time.Date(2021, 7, 5, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []chunk{
+		{index: 0, start: aligned, end: aligned.Add(12 * time.Hour)},
+		{index: 1, start: aligned.Add(12 * time.Hour), end: aligned.Add(24 * time.Hour)},
+	}, splitPeriod(aligned, aligned.Add(24*time.Hour), 12*time.Hour))
+}
+
+func TestInfluxDbConnectorChunks(t *testing.T) {
+	originalRetryBackoffStart := retryBackoffStart
+	retryBackoffStart = time.Millisecond
+	t.Cleanup(func() {
+		retryBackoffStart = originalRetryBackoffStart
+	})
+
+	epoch := time.Date(2021, 7, 5, 0, 0, 0, 0, time.UTC)
+
+	for _, concurrency := range []string{"1", "3"} {
+		var mutex sync.Mutex
+		attempts := make(map[string]int)
+
+		c := newChunksTestConnector(func(start string) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			attempts[start]++
+			if start == "2021-07-06T00:00:00Z" && attempts[start] < 3 {
+				return errors.New("timeout")
+			}
+			return nil
+		})
+
+		var metadatas []map[string]string
+		err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			assert.Equal(t, "result "+metadata["start"], string(data))
+			metadatas = append(metadatas, metadata)
+			return nil, nil
+		})
+		assert.NoError(t, err)
+
+		err = c.Init(epoch, 4*24*time.Hour, time.Hour, map[string]string{
+			"url":               "fake-url-for-test",
+			"token":             "fake-token-for-test",
+			"chunk_period":      "24h",
+			"chunk_concurrency": concurrency,
+		})
+		if !assert.NoError(t, err, "chunk_concurrency %s", concurrency) {
+			continue
+		}
+
+		// Only the failed chunk is retried
+		assert.Equal(t, map[string]int{
+			"2021-07-05T00:00:00Z": 1,
+			"2021-07-06T00:00:00Z": 3,
+			"2021-07-07T00:00:00Z": 1,
+			"2021-07-08T00:00:00Z": 1,
+		}, attempts)
+
+		sort.Slice(metadatas, func(i, j int) bool {
+			return metadatas[i]["chunk"] < metadatas[j]["chunk"]
+		})
+		assert.Equal(t, []map[string]string{
+			{"start": "2021-07-05T00:00:00Z", "end": "2021-07-06T00:00:00Z", "chunk": "0", "chunk_count": "4"},
+			{"start": "2021-07-06T00:00:00Z", "end": "2021-07-07T00:00:00Z", "chunk": "1", "chunk_count": "4"},
+			{"start": "2021-07-07T00:00:00Z", "end": "2021-07-08T00:00:00Z", "chunk": "2", "chunk_count": "4"},
+			{"start": "2021-07-08T00:00:00Z", "end": "2021-07-09T00:00:00Z", "chunk": "3", "chunk_count": "4"},
+		}, metadatas)
+	}
+}
+
+func TestInfluxDbConnectorChunksResume(t *testing.T) {
+	originalRetryBackoffStart := retryBackoffStart
+	retryBackoffStart = time.Millisecond
+	t.Cleanup(func() {
+		retryBackoffStart = originalRetryBackoffStart
+	})
+
+	epoch := time.Date(2021, 7, 5, 0, 0, 0, 0, time.UTC)
+
+	var mutex sync.Mutex
+	failing := true
+	var queried []string
+	c := newChunksTestConnector(func(start string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		queried = append(queried, start)
+		if failing && start == "2021-07-06T00:00:00Z" {
+			return errors.New("timeout")
+		}
+		return nil
+	})
+
+	params := map[string]string{
+		"url":           "fake-url-for-test",
+		"token":         "fake-token-for-test",
+		"chunk_period":  "24h",
+		"chunk_retries": "1",
+	}
+	err := c.Init(epoch, 3*24*time.Hour, time.Hour, params)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"2021-07-05T00:00:00Z", "2021-07-06T00:00:00Z", "2021-07-06T00:00:00Z"}, queried)
+	// The end of the period only moves forward once all its chunks completed
+	assert.True(t, c.lastFetchPeriodEnd.IsZero())
+	assert.Equal(t, []bool{true, false, false}, c.completedChunks)
+
+	// The next refresh resumes from the failed chunk
+	failing = false
+	queried = nil
+	err = c.refreshData(epoch, 3*24*time.Hour, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2021-07-06T00:00:00Z", "2021-07-07T00:00:00Z"}, queried)
+
+	queried = nil
+	err = c.refreshData(epoch, 3*24*time.Hour, time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, queried)
+}
+
+func TestInfluxDbConnectorChunksConcurrentResume(t *testing.T) {
+	epoch := time.Date(2021, 7, 5, 0, 0, 0, 0, time.UTC)
+
+	var mutex sync.Mutex
+	failing := true
+	var sent []string
+	// The failing chunk fails once the other chunks were sent
+	othersSent := make(chan struct{})
+	c := newChunksTestConnector(func(start string) error {
+		mutex.Lock()
+		fail := failing && start == "2021-07-06T00:00:00Z"
+		mutex.Unlock()
+		if fail {
+			<-othersSent
+			return errors.New("timeout")
+		}
+		return nil
+	})
+
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent = append(sent, metadata["start"])
+		if failing && len(sent) == 3 {
+			close(othersSent)
+		}
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	params := map[string]string{
+		"url":               "fake-url-for-test",
+		"token":             "fake-token-for-test",
+		"chunk_period":      "24h",
+		"chunk_concurrency": "4",
+		"chunk_retries":     "0",
+	}
+	err = c.Init(epoch, 4*24*time.Hour, time.Hour, params)
+	assert.Error(t, err)
+	assert.True(t, c.lastFetchPeriodEnd.IsZero())
+
+	// Only the failed chunk is fetched again, without sending the chunks after it twice
+	mutex.Lock()
+	failing = false
+	sort.Strings(sent)
+	assert.Equal(t, []string{"2021-07-05T00:00:00Z", "2021-07-07T00:00:00Z", "2021-07-08T00:00:00Z"}, sent)
+	sent = nil
+	mutex.Unlock()
+
+	err = c.refreshData(epoch, 4*24*time.Hour, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2021-07-06T00:00:00Z"}, sent)
+	assert.Equal(t, time.Date(2021, 7, 9, 0, 0, 0, 0, time.UTC), c.lastFetchPeriodEnd)
+
+	sent = nil
+	err = c.refreshData(epoch, 4*24*time.Hour, time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, sent)
+}
+
+func TestInfluxDbConnectorInvalidChunks(t *testing.T) {
+	tests := []map[string]string{
+		{"chunk_period": "1 day"},
+		{"chunk_period": "-1h"},
+		{"chunk_period": "90m"},
+		{"chunk_concurrency": "0"},
+		{"chunk_concurrency": "many"},
+		{"chunk_retries": "-1"},
+	}
+
+	for _, params := range tests {
+		params["url"] = "fake-url-for-test"
+		params["token"] = "fake-token-for-test"
+
+		c := NewInfluxDbConnector()
+		c.SetInfluxdbClient(&mockClient{
+			queryAPIFunc: func(org string) api.QueryAPI {
+				t.Errorf("unexpected query with params %v", params)
+				return nil
+			},
+		})
+
+		err := c.Init(time.Time{}, 24*time.Hour, time.Hour, params)
+		assert.Error(t, err, "params %v", params)
+	}
+}
+
+// Returns a connector whose queries return "result <start>", or the error returned by queryFunc for the chunk start
+func newChunksTestConnector(queryFunc func(start string) error) *InfluxDbConnector {
+	c := NewInfluxDbConnector()
+
+	mockQueryAPI := mockQueryAPI{}
+	mockQueryAPI.setQueryRaw(func(ctx context.Context, query string, dialect *domain.Dialect) (string, error) {
+		start := rangeRegexp.FindStringSubmatch(query)[1]
+		if err := queryFunc(start); err != nil {
+			return "", err
+		}
+		return "result " + start, nil
+	})
+	c.SetInfluxdbClient(&mockClient{
+		queryAPIFunc: func(org string) api.QueryAPI {
+			return &mockQueryAPI
+		},
+	})
+
+	return c
+}
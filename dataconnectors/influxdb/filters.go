@@ -0,0 +1,114 @@
+package influxdb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var fluxRegexEscaper = strings.NewReplacer(`/`, `\/`, "\n", `\n`)
+
+// A tag predicate such as host=web-* or region!=us-east
+type tagFilter struct {
+	tag      string
+	value    string
+	negate   bool
+	wildcard bool
+}
+
+// Parses the comma-delimited tag_filters param. A '*' in a value matches any characters.
+func parseTagFilters(tagFilters string) ([]tagFilter, error) {
+	var filters []tagFilter
+	for _, predicate := range splitList(tagFilters) {
+		filter := tagFilter{}
+		var parts []string
+		if strings.Contains(predicate, "!=") {
+			parts = strings.SplitN(predicate, "!=", 2)
+			filter.negate = true
+		} else {
+			parts = strings.SplitN(predicate, "=", 2)
+		}
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid tag filter '%s': must be in the form tag=value or tag!=value", predicate)
+		}
+		filter.tag = strings.TrimSpace(parts[0])
+		filter.value = strings.TrimSpace(parts[1])
+		filter.wildcard = strings.Contains(filter.value, "*")
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func (f *tagFilter) flux() string {
+	column := fmt.Sprintf("r[%s]", fluxString(f.tag))
+
+	if !f.wildcard {
+		operator := "=="
+		if f.negate {
+			operator = "!="
+		}
+		return fmt.Sprintf("%s %s %s", column, operator, fluxString(f.value))
+	}
+
+	segments := strings.Split(f.value, "*")
+	for i, segment := range segments {
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	pattern := fluxRegexEscaper.Replace("^" + strings.Join(segments, ".*") + "$")
+
+	operator := "=~"
+	if f.negate {
+		operator = "!~"
+	}
+	return fmt.Sprintf("%s %s /%s/", column, operator, pattern)
+}
+
+// Returns the filter matching any of the values of the column
+func anyOfFilter(column string, values []string) string {
+	predicates := make([]string, len(values))
+	for i, value := range values {
+		predicates[i] = fmt.Sprintf("r[%s] == %s", fluxString(column), fluxString(value))
+	}
+	return fmt.Sprintf("filter(fn: (r) => %s)", strings.Join(predicates, " or "))
+}
+
+// Returns the filter matching the tag filters. Tag=value filters of the same tag match any of their values, all other filters must match.
+func tagFiltersFilter(filters []tagFilter) string {
+	var conditions []string
+	conditionIndices := make(map[string]int)
+	predicatesByTag := make(map[string][]string)
+	for i := range filters {
+		if filters[i].negate {
+			conditions = append(conditions, filters[i].flux())
+			continue
+		}
+		tag := filters[i].tag
+		if _, ok := conditionIndices[tag]; !ok {
+			conditionIndices[tag] = len(conditions)
+			conditions = append(conditions, "")
+		}
+		predicatesByTag[tag] = append(predicatesByTag[tag], filters[i].flux())
+	}
+
+	for tag, index := range conditionIndices {
+		predicates := predicatesByTag[tag]
+		if len(predicates) == 1 {
+			conditions[index] = predicates[0]
+		} else {
+			conditions[index] = "(" + strings.Join(predicates, " or ") + ")"
+		}
+	}
+
+	return fmt.Sprintf("filter(fn: (r) => %s)", strings.Join(conditions, " and "))
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
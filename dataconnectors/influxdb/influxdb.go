@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"text/template"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go"
@@ -22,27 +23,58 @@ var (
 )
 
 type InfluxDbConnector struct {
-	client       influxdb2.Client
+	client influxdb2.Client
+	// Major version of the InfluxDB server, 2 queries with Flux and 1 with InfluxQL
+	version      int
 	readHandlers []*func(data []byte, metadata map[string]string) ([]byte, error)
 
 	lastFetchPeriodEnd time.Time
-	lastError          error
+	// Chunks of a period whose fetch failed, and which of them completed, so only the others are fetched again
+	pendingChunks   []chunk
+	completedChunks []bool
+
+	errors    chan error
+	done      chan struct{}
+	closeOnce sync.Once
+	// Running refresh loop, waited for by Close
+	refreshing sync.WaitGroup
 
 	dataMutex sync.RWMutex
+	sendMutex sync.Mutex
 	data      []byte
 
+	url             string
+	username        string
+	password        string
+	database        string
+	retentionPolicy string
 	org             string
 	bucket          string
 	field           string
-	fn              string
+	fields          []string
+	aggregate       *aggregate
 	measurement     string
+	measurements    []string
+	tagFilters      []tagFilter
+	queryTemplate   *template.Template
+	queryParams     map[string]string
 	refreshInterval time.Duration
+	maxBackoff      time.Duration
+
+	chunkPeriod      time.Duration
+	chunkConcurrency int
+	chunkRetries     int
 }
 
 func NewInfluxDbConnector() *InfluxDbConnector {
 	return &InfluxDbConnector{
-		refreshInterval: 15 * time.Second,
-		dataMutex:       sync.RWMutex{},
+		refreshInterval:  15 * time.Second,
+		maxBackoff:       defaultMaxBackoff,
+		chunkConcurrency: 1,
+		chunkRetries:     defaultChunkRetries,
+		dataMutex:        sync.RWMutex{},
+		errors:           make(chan error, errorBufferSize),
+		done:             make(chan struct{}),
 	}
 }
 
@@ -51,12 +83,47 @@ func (c *InfluxDbConnector) Init(epoch time.Time, period time.Duration, interval
 		return errors.New("influxdb connector requires the 'url' parameter to be set")
 	}
 
-	if _, ok := params["token"]; !ok {
-		return errors.New("influxdb connector requires the 'token' parameter to be set")
+	switch params["version"] {
+	case "", "2":
+		c.version = 2
+	case "1":
+		c.version = 1
+	default:
+		return fmt.Errorf("unsupported version '%s': must be 1 or 2", params["version"])
 	}
 
-	client := influxdb2.NewClient(params["url"], params["token"])
-	c.SetInfluxdbClient(client)
+	if c.version == 1 {
+		c.url = params["url"]
+		c.username = params["username"]
+		c.password = params["password"]
+		if c.password != "" && c.username == "" {
+			return errors.New("influxdb connector requires the 'username' parameter to be set with 'password'")
+		}
+
+		// The bucket is the database in InfluxDB 1.x
+		c.database = params["database"]
+		if c.database == "" {
+			c.database = params["bucket"]
+		}
+		if c.database == "" {
+			return errors.New("influxdb connector requires the 'database' parameter to be set with version 1")
+		}
+		c.retentionPolicy = params["retention_policy"]
+
+		if _, ok := params["query"]; ok {
+			return errors.New("the 'query' parameter is not supported with version 1")
+		}
+		if _, ok := params["query_file"]; ok {
+			return errors.New("the 'query_file' parameter is not supported with version 1")
+		}
+	} else {
+		if _, ok := params["token"]; !ok {
+			return errors.New("influxdb connector requires the 'token' parameter to be set")
+		}
+
+		client := influxdb2.NewClient(params["url"], params["token"])
+		c.SetInfluxdbClient(client)
+	}
 
 	if org, ok := params["org"]; ok {
 		c.org = org
@@ -73,12 +140,11 @@ func (c *InfluxDbConnector) Init(epoch time.Time, period time.Duration, interval
 		c.field = "_value"
 	}
 
-	if fn, ok := params["fn"]; ok {
-		c.fn = fn
-	} else {
-		// Default to "mean"
-		c.fn = "mean"
+	aggregate, err := parseAggregate(params)
+	if err != nil {
+		return err
 	}
+	c.aggregate = aggregate
 
 	if measurement, ok := params["measurement"]; ok {
 		c.measurement = measurement
@@ -87,6 +153,44 @@ func (c *InfluxDbConnector) Init(epoch time.Time, period time.Duration, interval
 		c.measurement = "_measurement"
 	}
 
+	if fields, ok := params["fields"]; ok {
+		c.fields = splitList(fields)
+		if len(c.fields) == 0 {
+			return fmt.Errorf("invalid fields '%s': at least one field is required", fields)
+		}
+		c.field = c.fields[0]
+	} else {
+		c.fields = []string{c.field}
+	}
+
+	if measurements, ok := params["measurements"]; ok {
+		c.measurements = splitList(measurements)
+		if len(c.measurements) == 0 {
+			return fmt.Errorf("invalid measurements '%s': at least one measurement is required", measurements)
+		}
+		c.measurement = c.measurements[0]
+	} else {
+		c.measurements = []string{c.measurement}
+	}
+
+	tagFilters, err := parseTagFilters(params["tag_filters"])
+	if err != nil {
+		return err
+	}
+	c.tagFilters = tagFilters
+
+	queryTemplate, err := parseQueryTemplate(params)
+	if err != nil {
+		return err
+	}
+	c.queryTemplate = queryTemplate
+
+	queryParams, err := getQueryParams(params)
+	if err != nil {
+		return err
+	}
+	c.queryParams = queryParams
+
 	if refreshInterval, ok := params["refresh_interval"]; ok {
 		ri, err := time.ParseDuration(refreshInterval)
 		if err != nil {
@@ -98,29 +202,33 @@ func (c *InfluxDbConnector) Init(epoch time.Time, period time.Duration, interval
 		c.refreshInterval = ri
 	}
 
-	err := c.refreshData(epoch, period, interval)
+	if maxBackoff, ok := params["max_backoff"]; ok {
+		mb, err := time.ParseDuration(maxBackoff)
+		if err != nil {
+			return fmt.Errorf("invalid max_backoff '%s': %s", maxBackoff, err)
+		}
+		if mb <= 0 {
+			return fmt.Errorf("invalid max_backoff '%s': backoff must be > 0", maxBackoff)
+		}
+		c.maxBackoff = mb
+	}
+
+	err = c.parseChunkParams(params, interval)
+	if err != nil {
+		return err
+	}
+
+	err = c.refreshData(epoch, period, interval)
 	if err != nil {
 		return err
 	}
 
 	if c.refreshInterval > 0 {
 		ticker := time.NewTicker(c.refreshInterval)
-		done := make(chan bool)
+		c.refreshing.Add(1)
 		go func() {
-			for {
-				select {
-				case <-done:
-					return
-				case <-ticker.C:
-					err := c.refreshData(epoch, period, interval)
-					if err != nil && c.lastError != nil {
-						// Two errors in a row, stop refresh
-						log.Printf("InfluxDb connector refresh error: %s\n", c.lastError.Error())
-						return
-					}
-					c.lastError = err
-				}
-			}
+			defer c.refreshing.Done()
+			c.refreshLoop(ticker, epoch, period, interval)
 		}()
 	}
 
@@ -136,6 +244,10 @@ func (c *InfluxDbConnector) refreshData(epoch time.Time, period time.Duration, i
 	c.dataMutex.Lock()
 	defer c.dataMutex.Unlock()
 
+	if c.pendingChunks != nil {
+		return c.fetchPeriod(c.pendingChunks, c.completedChunks, interval)
+	}
+
 	var periodStart time.Time
 	var periodEnd time.Time
 
@@ -153,12 +265,12 @@ func (c *InfluxDbConnector) refreshData(epoch time.Time, period time.Duration, i
 		}
 	} else {
 		// Epoch set - always same exact window
+		periodStart = epoch.UTC()
+		periodEnd = periodStart.Add(period)
 		if !c.lastFetchPeriodEnd.IsZero() {
 			// already fetched this window
 			return nil
 		}
-		periodStart = epoch.UTC()
-		periodEnd = periodStart.Add(period)
 	}
 
 	if periodStart == periodEnd || periodStart.After(periodEnd) {
@@ -166,16 +278,45 @@ func (c *InfluxDbConnector) refreshData(epoch time.Time, period time.Duration, i
 		return nil
 	}
 
-	periodStartStr := periodStart.Format(time.RFC3339)
-	periodEndStr := periodEnd.Format(time.RFC3339)
+	chunks := splitPeriod(periodStart, periodEnd, c.chunkPeriod)
+	return c.fetchPeriod(chunks, make([]bool, len(chunks)), interval)
+}
+
+// Fetches the chunks of a period that did not complete yet. The end of the period is only moved forward once all its
+// chunks completed, and until then the next refresh fetches the remaining chunks. When no chunk completed, the next
+// refresh fetches a new period instead, like after a failed query without chunks.
+func (c *InfluxDbConnector) fetchPeriod(chunks []chunk, completed []bool, interval time.Duration) error {
+	if err := c.fetchChunks(chunks, completed, interval); err != nil {
+		c.pendingChunks = nil
+		c.completedChunks = nil
+		for _, done := range completed {
+			if done {
+				c.pendingChunks = chunks
+				c.completedChunks = completed
+				break
+			}
+		}
+		log.Printf("InfluxDb query failed: %v", err)
+		return err
+	}
+
+	c.pendingChunks = nil
+	c.completedChunks = nil
+	c.lastFetchPeriodEnd = chunks[len(chunks)-1].end
+	return nil
+}
+
+// Returns the period data as annotated Flux CSV
+func (c *InfluxDbConnector) query(periodStart time.Time, periodEnd time.Time, interval time.Duration) ([]byte, error) {
+	if c.version == 1 {
+		query := c.buildInfluxQLQuery(periodStart, periodEnd, interval)
+		return c.queryV1(query, periodStart, periodEnd, interval)
+	}
 
-	query := fmt.Sprintf(`
-		from(bucket:"%s") |>
-		range(start: %s, stop: %s) |>
-		filter(fn: (r) => r["_measurement"] == "%s") |>
-		filter(fn: (r) => r["_field"] == "%s") |>
-		aggregateWindow(every: %s, fn: mean, createEmpty: false)
-    `, c.bucket, periodStartStr, periodEndStr, c.measurement, c.field, interval.String())
+	query, err := c.buildQuery(periodStart, periodEnd, interval)
+	if err != nil {
+		return nil, err
+	}
 
 	header := true
 	annotations := []domain.DialectAnnotations{"group", "datatype", "default"}
@@ -188,33 +329,18 @@ func (c *InfluxDbConnector) refreshData(epoch time.Time, period time.Duration, i
 
 	result, err := c.client.QueryAPI(c.org).QueryRaw(context.Background(), query, dialect)
 	if err != nil {
-		log.Printf("InfluxDb query failed: %v", err)
-		return err
+		return nil, err
 	}
 
-	data := []byte(result)
-
-	c.data = data
-	c.lastFetchPeriodEnd = periodEnd
-
-	err = c.sendData(periodStartStr, periodEndStr)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return []byte(result), nil
 }
 
-func (c *InfluxDbConnector) sendData(periodStart string, periodEnd string) error {
+func (c *InfluxDbConnector) sendData(metadata map[string]string) error {
 	if len(c.readHandlers) == 0 {
 		// Nothing to read
 		return nil
 	}
 
-	metadata := map[string]string{}
-	metadata["start"] = periodStart
-	metadata["end"] = periodEnd
-
 	errGroup, _ := errgroup.WithContext(context.Background())
 
 	for _, handler := range c.readHandlers {
@@ -2,6 +2,10 @@ package influxdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -93,7 +97,7 @@ func TestInfluxDbConnectorQueries(t *testing.T) {
 		aggregateWindow(every: 2h0m0s, fn: mean, createEmpty: false)`,
 	}
 
-	t.Run("Read() set epoch", testQueriesFunc(defaultEpoch, 3*24*time.Hour, 2*time.Hour, setEpochExpectedQueries))
+	t.Run("Read() set epoch", testQueriesFunc(defaultEpoch, 3*24*time.Hour, 2*time.Hour, nil, setEpochExpectedQueries))
 
 	now = clockwork.NewFakeClockAt(time.Unix(1633421096, 0)).Now
 	zeroEpochExpectedQueries := []string{
@@ -109,7 +113,254 @@ func TestInfluxDbConnectorQueries(t *testing.T) {
 		aggregateWindow(every: 1h0m0s, fn: mean, createEmpty: false)`,
 	}
 
-	t.Run("Read() zero epoch", testQueriesFunc(time.Time{}, 7*24*time.Hour, time.Hour, zeroEpochExpectedQueries))
+	t.Run("Read() zero epoch", testQueriesFunc(time.Time{}, 7*24*time.Hour, time.Hour, nil, zeroEpochExpectedQueries))
+}
+
+func TestInfluxDbConnectorAggregates(t *testing.T) {
+	epoch := time.Unix(1625439896, 0)
+
+	tests := []struct {
+		params    map[string]string
+		aggregate string
+	}{
+		{map[string]string{"fn": "mean"}, `aggregateWindow(every: 1h0m0s, fn: mean, createEmpty: false)`},
+		{map[string]string{"fn": "median"}, `aggregateWindow(every: 1h0m0s, fn: median, createEmpty: false)`},
+		{map[string]string{"fn": "sum"}, `aggregateWindow(every: 1h0m0s, fn: sum, createEmpty: false)`},
+		{map[string]string{"fn": "count", "create_empty": "true"}, `aggregateWindow(every: 1h0m0s, fn: count, createEmpty: true) |>
+		toFloat()`},
+		{map[string]string{"fn": "min"}, `aggregateWindow(every: 1h0m0s, fn: min, createEmpty: false)`},
+		{map[string]string{"fn": "max"}, `aggregateWindow(every: 1h0m0s, fn: max, createEmpty: false)`},
+		{map[string]string{"fn": "last", "create_empty": "true", "fill": "previous"}, `aggregateWindow(every: 1h0m0s, fn: last, createEmpty: true) |>
+		fill(usePrevious: true)`},
+		{map[string]string{"fn": "first", "create_empty": "true", "fill": "null"}, `aggregateWindow(every: 1h0m0s, fn: first, createEmpty: true)`},
+		{map[string]string{"fn": "sum", "create_empty": "true", "fill": "0"}, `aggregateWindow(every: 1h0m0s, fn: sum, createEmpty: true) |>
+		fill(value: 0.0)`},
+		{map[string]string{"fn": "quantile", "quantile": "0.99"}, `aggregateWindow(every: 1h0m0s, fn: (column, tables=<-) => tables |> quantile(q: 0.99, column: column), createEmpty: false)`},
+		{map[string]string{"fn": "quantile", "quantile": "1", "create_empty": "true", "fill": "-1.5"}, `aggregateWindow(every: 1h0m0s, fn: (column, tables=<-) => tables |> quantile(q: 1.0, column: column), createEmpty: true) |>
+		fill(value: -1.5)`},
+	}
+
+	for _, test := range tests {
+		expectedQuery := `from(bucket:"") |>
+		range(start: 2021-07-04T23:04:56Z, stop: 2021-07-05T23:04:56Z) |>
+		filter(fn: (r) => r["_measurement"] == "_measurement") |>
+		filter(fn: (r) => r["_field"] == "_value") |>
+		` + test.aggregate
+		t.Run(fmt.Sprintf("Read() %v", test.params), testQueriesFunc(epoch, 24*time.Hour, time.Hour, test.params, []string{expectedQuery}))
+	}
+}
+
+func TestInfluxDbConnectorQueryTemplates(t *testing.T) {
+	epoch := time.Unix(1625439896, 0)
+
+	queryFile := filepath.Join(t.TempDir(), "query.flux")
+	err := os.WriteFile(queryFile, []byte(`from(bucket: {{ .bucket }}) |> range(start: {{ .start }}, stop: {{ .stop }})`), 0644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name          string
+		params        map[string]string
+		expectedQuery string
+	}{
+		{
+			name: "default escaped",
+			params: map[string]string{
+				"bucket":      `telegraf") |> drop(columns: ["_value"]) //`,
+				"measurement": `cpu\`,
+				"field":       "usage_${idle}",
+			},
+			expectedQuery: `from(bucket:"telegraf\") |> drop(columns: [\"_value\"]) //") |>
+		range(start: 2021-07-04T23:04:56Z, stop: 2021-07-05T23:04:56Z) |>
+		filter(fn: (r) => r["_measurement"] == "cpu\\") |>
+		filter(fn: (r) => r["_field"] == "usage_\${idle}") |>
+		aggregateWindow(every: 1h0m0s, fn: mean, createEmpty: false)`,
+		},
+		{
+			name: "query",
+			params: map[string]string{
+				"bucket":      "telegraf",
+				"fn":          "max",
+				"param.host":  `web-"1"`,
+				"param.units": "percent",
+				"query": `from(bucket: {{ .bucket }})
+				|> range(start: {{ .start }}, stop: {{ .stop }})
+				|> filter(fn: (r) => r.host == {{ .params.host }} and r.units == {{ .params.units }})
+				|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+				|> {{ .aggregate }}
+				|> group(columns: ["host"])
+				|> yield(name: "every_{{ .interval }}")`,
+			},
+			expectedQuery: `from(bucket: "telegraf")
+				|> range(start: 2021-07-04T23:04:56Z, stop: 2021-07-05T23:04:56Z)
+				|> filter(fn: (r) => r.host == "web-\"1\"" and r.units == "percent")
+				|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+				|> aggregateWindow(every: 1h0m0s, fn: max, createEmpty: false)
+				|> group(columns: ["host"])
+				|> yield(name: "every_1h0m0s")`,
+		},
+		{
+			name: "query_file",
+			params: map[string]string{
+				"bucket":     "telegraf",
+				"query_file": queryFile,
+			},
+			expectedQuery: `from(bucket: "telegraf") |> range(start: 2021-07-04T23:04:56Z, stop: 2021-07-05T23:04:56Z)`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("Read() %s", test.name), testQueriesFunc(epoch, 24*time.Hour, time.Hour, test.params, []string{test.expectedQuery}))
+	}
+}
+
+func TestInfluxDbConnectorInvalidQueryTemplates(t *testing.T) {
+	tests := []map[string]string{
+		{"query": "from(bucket: {{ .bucket }})", "query_file": "query.flux"},
+		{"query_file": filepath.Join(t.TempDir(), "missing.flux")},
+		{"query": " "},
+		{"query": "from(bucket: {{ .bucket )"},
+		{"query": "from(bucket: {{ .params.missing }})"},
+		{"query": "from(bucket: {{ .unknown }})"},
+		{"query": "from(bucket: {{ .bucket }})", "param.": "value"},
+	}
+
+	for _, params := range tests {
+		params["url"] = "fake-url-for-test"
+		params["token"] = "fake-token-for-test"
+
+		c := NewInfluxDbConnector()
+		c.SetInfluxdbClient(&mockClient{
+			queryAPIFunc: func(org string) api.QueryAPI {
+				t.Errorf("unexpected query with params %v", params)
+				return nil
+			},
+		})
+
+		err := c.Init(time.Time{}, time.Hour, time.Minute, params)
+		assert.Error(t, err, "params %v", params)
+	}
+}
+
+func TestInfluxDbConnectorFilters(t *testing.T) {
+	epoch := time.Unix(1625439896, 0)
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		filters string
+	}{
+		{
+			name: "fields and measurements",
+			params: map[string]string{
+				"measurements": "cpu, mem",
+				"fields":       "usage_idle,used_percent,latency",
+			},
+			filters: `filter(fn: (r) => r["_measurement"] == "cpu" or r["_measurement"] == "mem") |>
+			filter(fn: (r) => r["_field"] == "usage_idle" or r["_field"] == "used_percent" or r["_field"] == "latency")`,
+		},
+		{
+			name: "tag filters",
+			params: map[string]string{
+				"measurement": "cpu",
+				"field":       "usage_idle",
+				"tag_filters": "host=web-*,region=us-east,host=db-1,cpu!=cpu-total,env!=*test/*",
+			},
+			filters: `filter(fn: (r) => r["_measurement"] == "cpu") |>
+			filter(fn: (r) => r["_field"] == "usage_idle") |>
+			filter(fn: (r) => (r["host"] =~ /^web-.*$/ or r["host"] == "db-1") and r["region"] == "us-east" and r["cpu"] != "cpu-total" and r["env"] !~ /^.*test\/.*$/)`,
+		},
+		{
+			name: "escaped tag filters",
+			params: map[string]string{
+				"tag_filters": `host"=web.1*`,
+			},
+			filters: `filter(fn: (r) => r["_measurement"] == "_measurement") |>
+			filter(fn: (r) => r["_field"] == "_value") |>
+			filter(fn: (r) => r["host\""] =~ /^web\.1.*$/)`,
+		},
+	}
+
+	for _, test := range tests {
+		expectedQuery := `from(bucket:"") |>
+		range(start: 2021-07-04T23:04:56Z, stop: 2021-07-05T23:04:56Z) |>
+		` + test.filters + ` |>
+		aggregateWindow(every: 1h0m0s, fn: mean, createEmpty: false)`
+		t.Run(fmt.Sprintf("Read() %s", test.name), testQueriesFunc(epoch, 24*time.Hour, time.Hour, test.params, []string{expectedQuery}))
+	}
+
+	templateParams := map[string]string{
+		"fields":      "usage_idle,usage_user",
+		"tag_filters": "host=web-*",
+		"query":       `from(bucket: {{ .bucket }}) |> range(start: {{ .start }}, stop: {{ .stop }}) |> {{ .filters }} |> last()`,
+	}
+	expectedTemplateQuery := `from(bucket: "") |> range(start: 2021-07-04T23:04:56Z, stop: 2021-07-05T23:04:56Z) |> filter(fn: (r) => r["_measurement"] == "_measurement") |>
+	filter(fn: (r) => r["_field"] == "usage_idle" or r["_field"] == "usage_user") |>
+	filter(fn: (r) => r["host"] =~ /^web-.*$/) |> last()`
+	t.Run("Read() template filters", testQueriesFunc(epoch, 24*time.Hour, time.Hour, templateParams, []string{expectedTemplateQuery}))
+}
+
+func TestInfluxDbConnectorInvalidFilters(t *testing.T) {
+	tests := []map[string]string{
+		{"fields": " , "},
+		{"measurements": ""},
+		{"tag_filters": "host"},
+		{"tag_filters": "=web-1"},
+		{"tag_filters": "host=web-1,region"},
+	}
+
+	for _, params := range tests {
+		params["url"] = "fake-url-for-test"
+		params["token"] = "fake-token-for-test"
+
+		c := NewInfluxDbConnector()
+		c.SetInfluxdbClient(&mockClient{
+			queryAPIFunc: func(org string) api.QueryAPI {
+				t.Errorf("unexpected query with params %v", params)
+				return nil
+			},
+		})
+
+		err := c.Init(time.Time{}, time.Hour, time.Minute, params)
+		assert.Error(t, err, "params %v", params)
+	}
+}
+
+func TestFluxDuration(t *testing.T) {
+	assert.Equal(t, "1h0m0s", fluxDuration(time.Hour))
+	assert.Equal(t, "0s", fluxDuration(0))
+	assert.Equal(t, "1500ms", fluxDuration(1500*time.Millisecond))
+}
+
+func TestInfluxDbConnectorInvalidAggregates(t *testing.T) {
+	tests := []map[string]string{
+		{"fn": "average"},
+		{"fn": "quantile"},
+		{"fn": "quantile", "quantile": "1.5"},
+		{"fn": "quantile", "quantile": "p99"},
+		{"fn": "mean", "quantile": "0.5"},
+		{"create_empty": "yes"},
+		{"fill": "previous"},
+		{"create_empty": "true", "fill": "linear"},
+		{"fn": "count", "create_empty": "true", "fill": "0"},
+	}
+
+	for _, params := range tests {
+		params["url"] = "fake-url-for-test"
+		params["token"] = "fake-token-for-test"
+
+		c := NewInfluxDbConnector()
+		c.SetInfluxdbClient(&mockClient{
+			queryAPIFunc: func(org string) api.QueryAPI {
+				t.Errorf("unexpected query with params %v", params)
+				return nil
+			},
+		})
+
+		err := c.Init(time.Time{}, time.Hour, time.Minute, params)
+		assert.Error(t, err, "params %v", params)
+	}
 }
 
 func testInitFunc(params map[string]string) func(*testing.T) {
@@ -163,12 +414,15 @@ func testReadFunc(params map[string]string) func(*testing.T) {
 	}
 }
 
-func testQueriesFunc(epoch time.Time, period time.Duration, interval time.Duration, expectedQueries []string) func(*testing.T) {
+func testQueriesFunc(epoch time.Time, period time.Duration, interval time.Duration, extraParams map[string]string, expectedQueries []string) func(*testing.T) {
 	params := map[string]string{
 		"url":              "fake-url-for-test",
 		"token":            "fake-token-for-test",
 		"refresh_interval": "250ms",
 	}
+	for key, value := range extraParams {
+		params[key] = value
+	}
 
 	c := NewInfluxDbConnector()
 
@@ -209,6 +463,7 @@ func testQueriesFunc(epoch time.Time, period time.Duration, interval time.Durati
 
 		err = c.Init(epoch, period, interval, params)
 		if assert.NoError(t, err) {
+			t.Cleanup(func() { c.Close() })
 			wg.Wait()
 			isDone = true
 		}
@@ -237,21 +492,28 @@ func testReadWithRefreshFunc(params map[string]string) func(*testing.T) {
 			return expectedResult, nil
 		})
 
-		readCount := 0
+		readChan := make(chan []byte, 100)
 		err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
-			assert.Equal(t, expectedResult, string(data))
-			readCount++
+			readChan <- data
 			return nil, nil
 		})
 		assert.NoError(t, err)
 
 		params["refresh_interval"] = "100ms"
 		err = c.Init(epoch, period, interval, params)
-		if assert.NoError(t, err) {
-			timer := time.NewTimer(time.Second)
-			<-timer.C
-
-			assert.Equal(t, 10, readCount)
+		if !assert.NoError(t, err) {
+			return
+		}
+		t.Cleanup(func() { c.Close() })
+
+		// The initial read, then a read per refresh
+		for i := 0; i < 10; i++ {
+			select {
+			case data := <-readChan:
+				assert.Equal(t, expectedResult, string(data))
+			case <-time.After(time.Second):
+				t.Fatalf("refresh %d was not read", i)
+			}
 		}
 	}
 }
@@ -264,3 +526,103 @@ func cleanQuery(query string) string {
 	trimmed := strings.TrimSpace(query)
 	return strings.ReplaceAll(trimmed, "\t", "")
 }
+
+func TestInfluxDbConnectorRefreshRecovery(t *testing.T) {
+	originalRetryBackoffStart := retryBackoffStart
+	retryBackoffStart = 10 * time.Millisecond
+	clock := clockwork.NewFakeClockAt(time.Unix(1633421096, 0))
+	now = clock.Now
+	t.Cleanup(func() {
+		retryBackoffStart = originalRetryBackoffStart
+		now = time.Now
+	})
+
+	params := map[string]string{
+		"url":              "fake-url-for-test",
+		"token":            "fake-token-for-test",
+		"refresh_interval": "50ms",
+		"max_backoff":      "15ms",
+	}
+
+	c := NewInfluxDbConnector()
+
+	var mutex sync.Mutex
+	var queries []string
+	mockQueryAPI := mockQueryAPI{}
+	mockQueryAPI.setQueryRaw(func(ctx context.Context, query string, dialect *domain.Dialect) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		queries = append(queries, query)
+		// Each query happens an hour after the previous one
+		clock.Advance(time.Hour)
+		if len(queries) == 2 || len(queries) == 3 || len(queries) == 4 {
+			return "", errors.New("connection refused")
+		}
+		return "query-result", nil
+	})
+	c.SetInfluxdbClient(&mockClient{
+		queryAPIFunc: func(org string) api.QueryAPI {
+			return &mockQueryAPI
+		},
+	})
+
+	metadataChan := make(chan map[string]string, 10)
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		metadataChan <- metadata
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.Init(time.Time{}, 24*time.Hour, time.Hour, params)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// Stopping the refresh before the globals are restored
+	t.Cleanup(func() { c.Close() })
+
+	var refreshErrors []*RefreshError
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-c.Errors():
+			var refreshErr *RefreshError
+			if assert.ErrorAs(t, err, &refreshErr) {
+				refreshErrors = append(refreshErrors, refreshErr)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("refresh error was not reported")
+		}
+	}
+	assert.Equal(t, 1, refreshErrors[0].Attempts)
+	assert.Equal(t, 10*time.Millisecond, refreshErrors[0].Backoff)
+	assert.Equal(t, 2, refreshErrors[1].Attempts)
+	assert.Equal(t, 15*time.Millisecond, refreshErrors[1].Backoff)
+	assert.Equal(t, 3, refreshErrors[2].Attempts)
+	assert.Equal(t, 15*time.Millisecond, refreshErrors[2].Backoff)
+	assert.EqualError(t, refreshErrors[2].Err, "connection refused")
+
+	var metadatas []map[string]string
+	for i := 0; i < 2; i++ {
+		select {
+		case metadata := <-metadataChan:
+			metadatas = append(metadatas, metadata)
+		case <-time.After(time.Second):
+			t.Fatal("data was not received after recovering")
+		}
+	}
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+
+	mutex.Lock()
+	queryCount := len(queries)
+	mutex.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.LessOrEqual(t, len(queries), queryCount+1, "refresh did not stop after Close()")
+
+	// The first successful refresh after the errors backfills from the end of the last successful refresh
+	assert.Equal(t, map[string]string{"start": "2021-10-04T08:04:56Z", "end": "2021-10-05T08:04:56Z"}, metadatas[0])
+	assert.Equal(t, map[string]string{"start": "2021-10-05T07:04:56Z", "end": "2021-10-05T12:04:56Z"}, metadatas[1])
+	assert.Contains(t, queries[4], "range(start: 2021-10-05T07:04:56Z, stop: 2021-10-05T12:04:56Z)")
+}
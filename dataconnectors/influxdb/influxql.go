@@ -0,0 +1,339 @@
+package influxdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	httpClient = &http.Client{Timeout: 60 * time.Second}
+
+	influxQLIdentifierEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	influxQLStringEscaper     = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	influxQLRegexEscaper      = strings.NewReplacer(`/`, `\/`, "\n", `\n`)
+)
+
+// InfluxQL functions of the fn param values
+var influxQLFunctions = map[string]string{
+	"mean":   "MEAN",
+	"median": "MEDIAN",
+	"sum":    "SUM",
+	"count":  "COUNT",
+	"min":    "MIN",
+	"max":    "MAX",
+	"last":   "LAST",
+	"first":  "FIRST",
+}
+
+type influxQLResponse struct {
+	Results []struct {
+		Series []influxQLSeries `json:"series"`
+		Error  string           `json:"error"`
+	} `json:"results"`
+	Error string `json:"error"`
+}
+
+type influxQLSeries struct {
+	Name    string            `json:"name"`
+	Tags    map[string]string `json:"tags"`
+	Columns []string          `json:"columns"`
+	Values  [][]interface{}   `json:"values"`
+}
+
+func influxQLIdentifier(value string) string {
+	return `"` + influxQLIdentifierEscaper.Replace(value) + `"`
+}
+
+func influxQLString(value string) string {
+	return `'` + influxQLStringEscaper.Replace(value) + `'`
+}
+
+// Returns the duration as an InfluxQL duration literal, in the largest unit that keeps an integer magnitude
+func influxQLDuration(value time.Duration) string {
+	units := []struct {
+		unit     string
+		duration time.Duration
+	}{
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+		{"u", time.Microsecond},
+	}
+	for _, unit := range units {
+		if value%unit.duration == 0 {
+			return fmt.Sprintf("%d%s", value/unit.duration, unit.unit)
+		}
+	}
+	return fmt.Sprintf("%dns", value.Nanoseconds())
+}
+
+// Returns the InfluxQL aggregate of the field, aliased with the field name
+func (a *aggregate) influxQL(field string) string {
+	var selector string
+	if a.fn == "quantile" {
+		selector = fmt.Sprintf("PERCENTILE(%s, %s)", influxQLIdentifier(field), strconv.FormatFloat(a.quantile*100, 'f', -1, 64))
+	} else {
+		selector = fmt.Sprintf("%s(%s)", influxQLFunctions[a.fn], influxQLIdentifier(field))
+	}
+	return fmt.Sprintf("%s AS %s", selector, influxQLIdentifier(field))
+}
+
+func (a *aggregate) influxQLFill() string {
+	switch {
+	case !a.createEmpty:
+		return "none"
+	case a.fill == "" || a.fill == fillNull:
+		return "null"
+	case a.fill == fillPrevious:
+		return "previous"
+	}
+	value, _ := strconv.ParseFloat(a.fill, 64)
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (f *tagFilter) influxQL() string {
+	tag := influxQLIdentifier(f.tag)
+
+	if !f.wildcard {
+		operator := "="
+		if f.negate {
+			operator = "!="
+		}
+		return fmt.Sprintf("%s %s %s", tag, operator, influxQLString(f.value))
+	}
+
+	segments := strings.Split(f.value, "*")
+	for i, segment := range segments {
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	pattern := influxQLRegexEscaper.Replace("^" + strings.Join(segments, ".*") + "$")
+
+	operator := "=~"
+	if f.negate {
+		operator = "!~"
+	}
+	return fmt.Sprintf("%s %s /%s/", tag, operator, pattern)
+}
+
+func (c *InfluxDbConnector) buildInfluxQLQuery(periodStart time.Time, periodEnd time.Time, interval time.Duration) string {
+	selectors := make([]string, len(c.fields))
+	for i, field := range c.fields {
+		selectors[i] = c.aggregate.influxQL(field)
+	}
+
+	sources := make([]string, len(c.measurements))
+	for i, measurement := range c.measurements {
+		sources[i] = influxQLIdentifier(measurement)
+		if c.retentionPolicy != "" {
+			sources[i] = influxQLIdentifier(c.retentionPolicy) + "." + sources[i]
+		}
+	}
+
+	conditions := []string{
+		fmt.Sprintf("time >= %s", influxQLString(periodStart.UTC().Format(time.RFC3339Nano))),
+		fmt.Sprintf("time < %s", influxQLString(periodEnd.UTC().Format(time.RFC3339Nano))),
+	}
+	if len(c.tagFilters) > 0 {
+		conditions = append(conditions, c.influxQLTagConditions())
+	}
+
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s GROUP BY time(%s), * fill(%s)",
+		strings.Join(selectors, ", "),
+		strings.Join(sources, ", "),
+		strings.Join(conditions, " AND "),
+		influxQLDuration(interval),
+		c.aggregate.influxQLFill())
+}
+
+// Same grouping as the Flux tag filters: tag=value filters of the same tag match any of their values
+func (c *InfluxDbConnector) influxQLTagConditions() string {
+	var conditions []string
+	conditionIndices := make(map[string]int)
+	predicatesByTag := make(map[string][]string)
+	for i := range c.tagFilters {
+		if c.tagFilters[i].negate {
+			conditions = append(conditions, c.tagFilters[i].influxQL())
+			continue
+		}
+		tag := c.tagFilters[i].tag
+		if _, ok := conditionIndices[tag]; !ok {
+			conditionIndices[tag] = len(conditions)
+			conditions = append(conditions, "")
+		}
+		predicatesByTag[tag] = append(predicatesByTag[tag], c.tagFilters[i].influxQL())
+	}
+
+	for tag, index := range conditionIndices {
+		conditions[index] = "(" + strings.Join(predicatesByTag[tag], " OR ") + ")"
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// Runs the InfluxQL query with the 1.x query API and returns its results as annotated Flux CSV
+func (c *InfluxDbConnector) queryV1(query string, periodStart time.Time, periodEnd time.Time, interval time.Duration) ([]byte, error) {
+	values := url.Values{}
+	values.Set("db", c.database)
+	values.Set("q", query)
+
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.url, "/")+"/query?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result influxQLResponse
+	jsonErr := json.Unmarshal(body, &result)
+
+	if response.StatusCode != http.StatusOK {
+		if jsonErr == nil && result.Error != "" {
+			return nil, fmt.Errorf("influxql query failed with status code %d: %s", response.StatusCode, result.Error)
+		}
+		return nil, fmt.Errorf("influxql query failed with status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if jsonErr != nil {
+		return nil, fmt.Errorf("invalid influxql response: %w", jsonErr)
+	}
+
+	if result.Error != "" {
+		return nil, fmt.Errorf("influxql query failed: %s", result.Error)
+	}
+
+	var series []influxQLSeries
+	for _, statement := range result.Results {
+		if statement.Error != "" {
+			return nil, fmt.Errorf("influxql query failed: %s", statement.Error)
+		}
+		series = append(series, statement.Series...)
+	}
+
+	return influxQLToFluxCsv(series, periodStart, periodEnd, interval)
+}
+
+// Converts InfluxQL series to annotated Flux CSV with one table per series and field. Like aggregateWindow, the time
+// of each window is its end, where InfluxQL uses its start.
+func influxQLToFluxCsv(series []influxQLSeries, periodStart time.Time, periodEnd time.Time, interval time.Duration) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	start := periodStart.UTC().Format(time.RFC3339Nano)
+	stop := periodEnd.UTC().Format(time.RFC3339Nano)
+
+	table := 0
+	for _, s := range series {
+		timeIndex := -1
+		for i, column := range s.Columns {
+			if column == "time" {
+				timeIndex = i
+			}
+		}
+		if timeIndex == -1 {
+			return nil, fmt.Errorf("'time' not found in series '%s'", s.Name)
+		}
+
+		tagKeys := make([]string, 0, len(s.Tags))
+		for key := range s.Tags {
+			tagKeys = append(tagKeys, key)
+		}
+		sort.Strings(tagKeys)
+
+		for fieldIndex, field := range s.Columns {
+			if fieldIndex == timeIndex {
+				continue
+			}
+
+			if table > 0 {
+				// A new annotation block starts after an empty line
+				writer.Flush()
+				buffer.WriteString("\n")
+			}
+
+			group := []string{"#group", "false", "false", "true", "true", "false", "false", "true", "true"}
+			datatype := []string{"#datatype", "string", "long", "dateTime:RFC3339", "dateTime:RFC3339", "dateTime:RFC3339", "double", "string", "string"}
+			defaults := []string{"#default", "_result", "", "", "", "", "", "", ""}
+			header := []string{"", "result", "table", "_start", "_stop", "_time", "_value", "_field", "_measurement"}
+			for _, key := range tagKeys {
+				group = append(group, "true")
+				datatype = append(datatype, "string")
+				defaults = append(defaults, "")
+				header = append(header, key)
+			}
+
+			for _, row := range [][]string{group, datatype, defaults, header} {
+				if err := writer.Write(row); err != nil {
+					return nil, err
+				}
+			}
+
+			for _, values := range s.Values {
+				if len(values) != len(s.Columns) {
+					return nil, fmt.Errorf("invalid row in series '%s': expected %d values, got %d", s.Name, len(s.Columns), len(values))
+				}
+
+				timeString, ok := values[timeIndex].(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid time '%v' in series '%s'", values[timeIndex], s.Name)
+				}
+				windowStart, err := time.Parse(time.RFC3339Nano, timeString)
+				if err != nil {
+					return nil, fmt.Errorf("invalid time '%s' in series '%s': %w", timeString, s.Name, err)
+				}
+				windowEnd := windowStart.Add(interval)
+				if windowEnd.After(periodEnd) {
+					windowEnd = periodEnd
+				}
+
+				var value string
+				switch v := values[fieldIndex].(type) {
+				case nil:
+				case float64:
+					value = strconv.FormatFloat(v, 'f', -1, 64)
+				default:
+					return nil, fmt.Errorf("unsupported value '%v' of field '%s' in series '%s': only numeric values are supported", v, field, s.Name)
+				}
+
+				row := []string{"", "", strconv.Itoa(table), start, stop, windowEnd.UTC().Format(time.RFC3339Nano), value, field, s.Name}
+				for _, key := range tagKeys {
+					row = append(row, s.Tags[key])
+				}
+				if err := writer.Write(row); err != nil {
+					return nil, err
+				}
+			}
+
+			table++
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
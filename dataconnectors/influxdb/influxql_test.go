@@ -0,0 +1,241 @@
+package influxdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/spiceai/data-components-contrib/dataprocessors/flux"
+	"github.com/stretchr/testify/assert"
+)
+
+const influxQLResult = `{
+	"results": [{
+		"statement_id": 0,
+		"series": [
+			{
+				"name": "cpu",
+				"tags": {"region": "us-east", "host": "web-1"},
+				"columns": ["time", "usage_idle", "usage_user"],
+				"values": [
+					["2021-08-17T00:00:00Z", 99.5, 0.5],
+					["2021-08-17T00:20:00Z", 98.5, null]
+				]
+			},
+			{
+				"name": "cpu",
+				"tags": {"region": "us-west", "host": "web-2"},
+				"columns": ["time", "usage_idle", "usage_user"],
+				"values": [
+					["2021-08-17T00:40:00Z", 42.25, 57]
+				]
+			}
+		]
+	}]
+}`
+
+func TestInfluxQLQueries(t *testing.T) {
+	periodStart := time.Unix(1629158400, 0)
+
+	tests := []struct {
+		params        map[string]string
+		expectedQuery string
+	}{
+		{
+			params:        map[string]string{},
+			expectedQuery: `SELECT MEAN("_value") AS "_value" FROM "_measurement" WHERE time >= '2021-08-17T00:00:00Z' AND time < '2021-08-17T01:00:00Z' GROUP BY time(20m), * fill(none)`,
+		},
+		{
+			params: map[string]string{
+				"measurements":     `cpu,my"cpu`,
+				"fields":           "usage_idle,usage_user",
+				"fn":               "quantile",
+				"quantile":         "0.95",
+				"retention_policy": "autogen",
+				"tag_filters":      `host=web-*,host=db-1,region!=us-west,env!=*/test,name=o'brien`,
+			},
+			expectedQuery: `SELECT PERCENTILE("usage_idle", 95) AS "usage_idle", PERCENTILE("usage_user", 95) AS "usage_user" ` +
+				`FROM "autogen"."cpu", "autogen"."my\"cpu" ` +
+				`WHERE time >= '2021-08-17T00:00:00Z' AND time < '2021-08-17T01:00:00Z' AND ` +
+				`("host" =~ /^web-.*$/ OR "host" = 'db-1') AND "region" != 'us-west' AND "env" !~ /^.*\/test$/ AND ("name" = 'o\'brien') ` +
+				`GROUP BY time(20m), * fill(none)`,
+		},
+		{
+			params:        map[string]string{"fn": "count", "create_empty": "true"},
+			expectedQuery: `SELECT COUNT("_value") AS "_value" FROM "_measurement" WHERE time >= '2021-08-17T00:00:00Z' AND time < '2021-08-17T01:00:00Z' GROUP BY time(20m), * fill(null)`,
+		},
+		{
+			params:        map[string]string{"fn": "last", "create_empty": "true", "fill": "previous"},
+			expectedQuery: `SELECT LAST("_value") AS "_value" FROM "_measurement" WHERE time >= '2021-08-17T00:00:00Z' AND time < '2021-08-17T01:00:00Z' GROUP BY time(20m), * fill(previous)`,
+		},
+		{
+			params:        map[string]string{"fn": "sum", "create_empty": "true", "fill": "-1.5"},
+			expectedQuery: `SELECT SUM("_value") AS "_value" FROM "_measurement" WHERE time >= '2021-08-17T00:00:00Z' AND time < '2021-08-17T01:00:00Z' GROUP BY time(20m), * fill(-1.5)`,
+		},
+	}
+
+	for _, test := range tests {
+		var query string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query().Get("q")
+			_, _ = w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		}))
+
+		params := map[string]string{
+			"version":          "1",
+			"url":              server.URL,
+			"database":         "telegraf",
+			"refresh_interval": "0",
+		}
+		for key, value := range test.params {
+			params[key] = value
+		}
+
+		c := NewInfluxDbConnector()
+		err := c.Init(periodStart, time.Hour, 20*time.Minute, params)
+		if assert.NoError(t, err, "params %v", test.params) {
+			assert.Equal(t, test.expectedQuery, query)
+		}
+
+		server.Close()
+	}
+}
+
+func TestInfluxQLRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"authorization failed"}`))
+			return
+		}
+		assert.Equal(t, "/query", r.URL.Path)
+		assert.Equal(t, "telegraf", r.URL.Query().Get("db"))
+		_, _ = w.Write([]byte(influxQLResult))
+	}))
+	defer server.Close()
+
+	params := map[string]string{
+		"version":          "1",
+		"url":              server.URL,
+		"username":         "admin",
+		"password":         "secret",
+		"bucket":           "telegraf",
+		"measurement":      "cpu",
+		"fields":           "usage_idle,usage_user",
+		"refresh_interval": "0",
+	}
+
+	c := NewInfluxDbConnector()
+
+	var data []byte
+	var metadata map[string]string
+	err := c.Read(func(d []byte, m map[string]string) ([]byte, error) {
+		data = d
+		metadata = m
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	periodStart := time.Unix(1629158400, 0)
+	err = c.Init(periodStart, time.Hour, 20*time.Minute, params)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]string{"start": "2021-08-17T00:00:00Z", "end": "2021-08-17T01:00:00Z"}, metadata)
+
+	// The converted data is consumed by the flux-csv processor
+	dp := flux.NewFluxCsvProcessor()
+	err = dp.Init(nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = dp.OnData(data)
+	assert.NoError(t, err)
+
+	record, err := dp.GetRecord()
+	if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+		return
+	}
+	defer record.Release()
+
+	fields := []arrow.Field{
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "measure.usage_idle", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "measure.usage_user", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}
+	pool := memory.NewGoAllocator()
+	recordBuilder := array.NewRecordBuilder(pool, arrow.NewSchema(fields, nil))
+	defer recordBuilder.Release()
+	// Window end times, like aggregateWindow
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1629159600, 1629160800, 1629162000}, nil)
+	recordBuilder.Field(1).(*array.Float64Builder).AppendValues([]float64{99.5, 98.5, 42.25}, nil)
+	recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{0.5, 0, 57}, []bool{true, false, true})
+	listBuilder := recordBuilder.Field(3).(*array.ListBuilder)
+	valueBuilder := listBuilder.ValueBuilder().(*array.StringBuilder)
+	for _, tags := range [][]string{{"web-1", "us-east"}, {"web-1", "us-east"}, {"web-2", "us-west"}} {
+		listBuilder.Append(true)
+		valueBuilder.AppendValues(tags, nil)
+	}
+
+	expectedRecord := recordBuilder.NewRecord()
+	defer expectedRecord.Release()
+
+	assert.True(t, expectedRecord.Schema().Equal(record.Schema()), "Schema not correct: %s", record.Schema())
+	assert.True(t, array.RecordEqual(expectedRecord, record), "Record not correct: %v", record)
+}
+
+func TestInfluxQLErrors(t *testing.T) {
+	responses := []struct {
+		statusCode int
+		body       string
+	}{
+		{http.StatusUnauthorized, `{"error":"authorization failed"}`},
+		{http.StatusOK, `{"results":[{"statement_id":0,"error":"database not found: telegraf"}]}`},
+		{http.StatusOK, `not json`},
+		{http.StatusOK, `{"results":[{"series":[{"name":"cpu","columns":["time","usage"],"values":[["2021-08-17T00:00:00Z","high"]]}]}]}`},
+	}
+
+	for _, response := range responses {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(response.statusCode)
+			_, _ = w.Write([]byte(response.body))
+		}))
+
+		c := NewInfluxDbConnector()
+		err := c.Init(time.Unix(1629158400, 0), time.Hour, 20*time.Minute, map[string]string{
+			"version":          "1",
+			"url":              server.URL,
+			"database":         "telegraf",
+			"refresh_interval": "0",
+		})
+		assert.Error(t, err, "response %s", response.body)
+
+		server.Close()
+	}
+
+	invalidParams := []map[string]string{
+		{"version": "3", "url": "http://localhost:8086", "token": "token"},
+		{"version": "1", "url": "http://localhost:8086"},
+		{"version": "1", "url": "http://localhost:8086", "database": "telegraf", "password": "secret"},
+		{"version": "1", "url": "http://localhost:8086", "database": "telegraf", "query": "SELECT 1"},
+	}
+
+	for _, params := range invalidParams {
+		c := NewInfluxDbConnector()
+		err := c.Init(time.Time{}, time.Hour, time.Minute, params)
+		assert.Error(t, err, "params %v", params)
+	}
+}
+
+func TestInfluxQLDuration(t *testing.T) {
+	assert.Equal(t, "1h", influxQLDuration(time.Hour))
+	assert.Equal(t, "90m", influxQLDuration(90*time.Minute))
+	assert.Equal(t, "1500ms", influxQLDuration(1500*time.Millisecond))
+	assert.Equal(t, "1500ns", influxQLDuration(1500*time.Nanosecond))
+}
@@ -0,0 +1,132 @@
+package influxdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// Params prefixed with "param." are available to query templates as {{ .params.<name> }}
+	queryParamPrefix = "param."
+)
+
+var fluxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `${`, `\${`)
+
+// Returns the value as a Flux string literal, escaped so that it can't alter the query
+func fluxString(value string) string {
+	return `"` + fluxStringEscaper.Replace(value) + `"`
+}
+
+func fluxTime(value time.Time) string {
+	return value.UTC().Format(time.RFC3339)
+}
+
+// Returns the duration as a Flux duration literal, which only allows integer magnitudes
+func fluxDuration(value time.Duration) string {
+	switch {
+	case value%time.Second == 0:
+		return value.String()
+	case value%time.Millisecond == 0:
+		return fmt.Sprintf("%dms", value.Milliseconds())
+	case value%time.Microsecond == 0:
+		return fmt.Sprintf("%dus", value.Microseconds())
+	}
+	return fmt.Sprintf("%dns", value.Nanoseconds())
+}
+
+// Parses the query template from the query or query_file params, or returns nil if neither is set
+func parseQueryTemplate(params map[string]string) (*template.Template, error) {
+	query, hasQuery := params["query"]
+	queryFile, hasQueryFile := params["query_file"]
+
+	if hasQuery && hasQueryFile {
+		return nil, errors.New("only one of the 'query' and 'query_file' parameters can be set")
+	}
+
+	if hasQueryFile {
+		content, err := os.ReadFile(strings.TrimSpace(queryFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open query_file '%s': %w", queryFile, err)
+		}
+		query = string(content)
+	} else if !hasQuery {
+		return nil, nil
+	}
+
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("query is empty")
+	}
+
+	queryTemplate, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query template: %w", err)
+	}
+
+	return queryTemplate, nil
+}
+
+// Returns the escaped param.<name> values, keyed by name
+func getQueryParams(params map[string]string) (map[string]string, error) {
+	queryParams := make(map[string]string)
+	for key, value := range params {
+		if !strings.HasPrefix(key, queryParamPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, queryParamPrefix)
+		if name == "" {
+			return nil, fmt.Errorf("invalid query param '%s': a name is required", key)
+		}
+		queryParams[name] = fluxString(value)
+	}
+	return queryParams, nil
+}
+
+func (c *InfluxDbConnector) buildQuery(periodStart time.Time, periodEnd time.Time, interval time.Duration) (string, error) {
+	filters := c.filtersFlux()
+
+	if c.queryTemplate == nil {
+		return fmt.Sprintf(`
+		from(bucket:%s) |>
+		range(start: %s, stop: %s) |>
+		%s |>
+		%s
+    `, fluxString(c.bucket), fluxTime(periodStart), fluxTime(periodEnd), filters, c.aggregate.flux(fluxDuration(interval))), nil
+	}
+
+	data := map[string]interface{}{
+		"filters":     filters,
+		"bucket":      fluxString(c.bucket),
+		"measurement": fluxString(c.measurement),
+		"field":       fluxString(c.field),
+		"start":       fluxTime(periodStart),
+		"stop":        fluxTime(periodEnd),
+		"interval":    fluxDuration(interval),
+		"aggregate":   c.aggregate.flux(fluxDuration(interval)),
+		"params":      c.queryParams,
+	}
+
+	var query bytes.Buffer
+	err := c.queryTemplate.Execute(&query, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute query template: %w", err)
+	}
+
+	return query.String(), nil
+}
+
+// Returns the filters of the measurements, fields and tag filters
+func (c *InfluxDbConnector) filtersFlux() string {
+	filters := []string{
+		anyOfFilter("_measurement", c.measurements),
+		anyOfFilter("_field", c.fields),
+	}
+	if len(c.tagFilters) > 0 {
+		filters = append(filters, tagFiltersFilter(c.tagFilters))
+	}
+	return strings.Join(filters, " |>\n\t\t")
+}
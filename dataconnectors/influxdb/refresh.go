@@ -0,0 +1,115 @@
+package influxdb
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	errorBufferSize = 100
+)
+
+var (
+	retryBackoffStart = time.Second
+	defaultMaxBackoff = 5 * time.Minute
+)
+
+// RefreshError is reported on the Errors() channel when a refresh fails
+type RefreshError struct {
+	Err error
+	// Wait before the next retry
+	Backoff time.Duration
+	// Consecutive failed refreshes
+	Attempts int
+}
+
+func (e *RefreshError) Error() string {
+	return fmt.Sprintf("refresh failed (attempt %d), retrying in %s: %s", e.Attempts, e.Backoff, e.Err.Error())
+}
+
+func (e *RefreshError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns the channel refresh errors are reported on. Errors are dropped if the channel is full.
+func (c *InfluxDbConnector) Errors() <-chan error {
+	return c.errors
+}
+
+// Close stops refreshing data, and waits for a refresh in progress to end
+func (c *InfluxDbConnector) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.refreshing.Wait()
+	return nil
+}
+
+// Refreshes data every refresh interval until the connector is closed. Failed refreshes are retried with exponential
+// backoff, and since the period end is only updated on success, the first successful refresh backfills the gap.
+func (c *InfluxDbConnector) refreshLoop(ticker *time.Ticker, epoch time.Time, period time.Duration, interval time.Duration) {
+	defer ticker.Stop()
+
+	var retryTimer *time.Timer
+	defer func() {
+		if retryTimer != nil {
+			retryTimer.Stop()
+		}
+	}()
+
+	attempts := 0
+	backoff := time.Duration(0)
+	for {
+		if backoff > 0 {
+			retryTimer = time.NewTimer(backoff)
+			select {
+			case <-c.done:
+				return
+			case <-retryTimer.C:
+			}
+		} else {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+		}
+
+		err := c.refreshData(epoch, period, interval)
+		if err != nil {
+			attempts++
+			backoff = c.nextBackoff(backoff)
+			c.reportError(&RefreshError{Err: err, Backoff: backoff, Attempts: attempts})
+			continue
+		}
+
+		if attempts > 0 {
+			log.Printf("InfluxDb connector refresh recovered after %d failed attempts\n", attempts)
+		}
+		attempts = 0
+		backoff = 0
+	}
+}
+
+func (c *InfluxDbConnector) nextBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		backoff = retryBackoffStart
+	} else {
+		backoff *= 2
+	}
+	if backoff > c.maxBackoff {
+		backoff = c.maxBackoff
+	}
+	return backoff
+}
+
+func (c *InfluxDbConnector) reportError(err error) {
+	log.Printf("InfluxDb connector refresh error: %s\n", err.Error())
+
+	select {
+	case c.errors <- err:
+	default:
+		// Nobody is reading the errors channel
+	}
+}
@@ -0,0 +1,260 @@
+package twitter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Filters of the v1.1 filter stream and the logic to find which of them matched a tweet
+type streamFilters struct {
+	track     []string
+	languages []string
+	locations []boundingBox
+	follow    []string
+}
+
+type boundingBox struct {
+	swLng float64
+	swLat float64
+	neLng float64
+	neLat float64
+}
+
+func (b *boundingBox) contains(lng float64, lat float64) bool {
+	return lng >= b.swLng && lng <= b.neLng && lat >= b.swLat && lat <= b.neLat
+}
+
+func (b *boundingBox) intersects(other *boundingBox) bool {
+	return b.swLng <= other.neLng && other.swLng <= b.neLng && b.swLat <= other.neLat && other.swLat <= b.neLat
+}
+
+func parseStreamFilters(params map[string]string) (*streamFilters, error) {
+	filters := &streamFilters{
+		track:     splitList(params["track"]),
+		languages: splitList(params["languages"]),
+		follow:    splitList(params["follow"]),
+	}
+
+	if len(filters.track) == 0 && params["filter"] != "" {
+		filters.track = []string{params["filter"]}
+	}
+
+	for _, userId := range filters.follow {
+		if _, err := strconv.ParseInt(userId, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid follow user id '%s': user ids must be numeric", userId)
+		}
+	}
+
+	coordinates := splitList(params["locations"])
+	if len(coordinates)%4 != 0 {
+		return nil, fmt.Errorf("invalid locations '%s': each bounding box requires 4 coordinates (sw_lng,sw_lat,ne_lng,ne_lat)", params["locations"])
+	}
+	for i := 0; i < len(coordinates); i += 4 {
+		var values [4]float64
+		for j := range values {
+			value, err := strconv.ParseFloat(coordinates[i+j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid locations coordinate '%s': %s", coordinates[i+j], err)
+			}
+			values[j] = value
+		}
+		box := boundingBox{swLng: values[0], swLat: values[1], neLng: values[2], neLat: values[3]}
+		if box.swLng > box.neLng || box.swLat > box.neLat {
+			return nil, fmt.Errorf("invalid locations bounding box %d: south-west must come before north-east", len(filters.locations))
+		}
+		filters.locations = append(filters.locations, box)
+	}
+
+	if len(filters.track) == 0 && len(filters.follow) == 0 && len(filters.locations) == 0 {
+		return nil, errors.New("at least one of track, follow or locations is required")
+	}
+
+	return filters, nil
+}
+
+func (f *streamFilters) filterParams() *twitter.StreamFilterParams {
+	var locations []string
+	for _, box := range f.locations {
+		for _, value := range []float64{box.swLng, box.swLat, box.neLng, box.neLat} {
+			locations = append(locations, strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+
+	return &twitter.StreamFilterParams{
+		Track:         f.track,
+		Language:      f.languages,
+		Locations:     locations,
+		Follow:        f.follow,
+		StallWarnings: twitter.Bool(true),
+	}
+}
+
+func (f *streamFilters) String() string {
+	var filters []string
+	if len(f.track) > 0 {
+		filters = append(filters, fmt.Sprintf("track %s", strings.Join(f.track, ",")))
+	}
+	if len(f.follow) > 0 {
+		filters = append(filters, fmt.Sprintf("follow %s", strings.Join(f.follow, ",")))
+	}
+	if len(f.locations) > 0 {
+		filters = append(filters, fmt.Sprintf("%d locations", len(f.locations)))
+	}
+	if len(f.languages) > 0 {
+		filters = append(filters, fmt.Sprintf("languages %s", strings.Join(f.languages, ",")))
+	}
+	return strings.Join(filters, ", ")
+}
+
+// Returns the track terms, "follow:<user id>" and "location:<bounding box index>" filters matched by the tweet
+func (f *streamFilters) match(tweet *twitter.Tweet) []string {
+	matched := []string{}
+
+	if len(f.track) > 0 {
+		words := tweetWords(tweet)
+		for _, term := range f.track {
+			// Words of a term must all be present, in any order
+			termWords := splitWords(term)
+			found := len(termWords) > 0
+			for _, word := range termWords {
+				if !words[word] {
+					found = false
+					break
+				}
+			}
+			if found {
+				matched = append(matched, term)
+			}
+		}
+	}
+
+	if len(f.follow) > 0 {
+		userIds := make(map[string]bool)
+		if tweet.User != nil {
+			userIds[tweet.User.IDStr] = true
+		}
+		if tweet.InReplyToUserIDStr != "" {
+			userIds[tweet.InReplyToUserIDStr] = true
+		}
+		if tweet.RetweetedStatus != nil && tweet.RetweetedStatus.User != nil {
+			userIds[tweet.RetweetedStatus.User.IDStr] = true
+		}
+		for _, userId := range f.follow {
+			if userIds[userId] {
+				matched = append(matched, "follow:"+userId)
+			}
+		}
+	}
+
+	for i := range f.locations {
+		if locationMatches(&f.locations[i], tweet) {
+			matched = append(matched, fmt.Sprintf("location:%d", i))
+		}
+	}
+
+	return matched
+}
+
+func locationMatches(box *boundingBox, tweet *twitter.Tweet) bool {
+	if tweet.Coordinates != nil {
+		return box.contains(tweet.Coordinates.Coordinates[0], tweet.Coordinates.Coordinates[1])
+	}
+
+	if tweet.Place == nil || tweet.Place.BoundingBox == nil || len(tweet.Place.BoundingBox.Coordinates) == 0 {
+		return false
+	}
+
+	polygon := tweet.Place.BoundingBox.Coordinates[0]
+	if len(polygon) == 0 {
+		return false
+	}
+
+	placeBox := boundingBox{swLng: polygon[0][0], swLat: polygon[0][1], neLng: polygon[0][0], neLat: polygon[0][1]}
+	for _, point := range polygon[1:] {
+		if point[0] < placeBox.swLng {
+			placeBox.swLng = point[0]
+		}
+		if point[0] > placeBox.neLng {
+			placeBox.neLng = point[0]
+		}
+		if point[1] < placeBox.swLat {
+			placeBox.swLat = point[1]
+		}
+		if point[1] > placeBox.neLat {
+			placeBox.neLat = point[1]
+		}
+	}
+
+	return box.intersects(&placeBox)
+}
+
+// Words of the text, hashtags, urls and screen names the stream matches track terms against
+func tweetWords(tweet *twitter.Tweet) map[string]bool {
+	words := make(map[string]bool)
+
+	var addTweet func(t *twitter.Tweet)
+	addTweet = func(t *twitter.Tweet) {
+		text := t.Text
+		entities := t.Entities
+		if t.ExtendedTweet != nil {
+			text = t.ExtendedTweet.FullText
+			entities = t.ExtendedTweet.Entities
+		} else if t.FullText != "" {
+			text = t.FullText
+		}
+
+		texts := []string{text}
+		if t.User != nil {
+			texts = append(texts, t.User.ScreenName)
+		}
+		if entities != nil {
+			for _, hashtag := range entities.Hashtags {
+				texts = append(texts, hashtag.Text)
+			}
+			for _, url := range entities.Urls {
+				texts = append(texts, url.ExpandedURL, url.DisplayURL)
+			}
+			for _, mention := range entities.UserMentions {
+				texts = append(texts, mention.ScreenName)
+			}
+		}
+
+		for _, text := range texts {
+			for _, word := range splitWords(text) {
+				words[word] = true
+			}
+		}
+
+		if t.RetweetedStatus != nil {
+			addTweet(t.RetweetedStatus)
+		}
+		if t.QuotedStatus != nil {
+			addTweet(t.QuotedStatus)
+		}
+	}
+	addTweet(tweet)
+
+	return words
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
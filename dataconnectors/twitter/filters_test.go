@@ -0,0 +1,116 @@
+package twitter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamFilters(t *testing.T) {
+	filters, err := parseStreamFilters(map[string]string{
+		"track":     "bitcoin, ethereum classic,",
+		"languages": "en,fr",
+		"follow":    "783214,6253282",
+		"locations": "-122.75,36.8,-121.75,37.8, -74,40,-73,41",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	params := filters.filterParams()
+	assert.Equal(t, []string{"bitcoin", "ethereum classic"}, params.Track)
+	assert.Equal(t, []string{"en", "fr"}, params.Language)
+	assert.Equal(t, []string{"783214", "6253282"}, params.Follow)
+	assert.Equal(t, []string{"-122.75", "36.8", "-121.75", "37.8", "-74", "40", "-73", "41"}, params.Locations)
+	assert.True(t, *params.StallWarnings)
+
+	// filter is still supported as a single track term
+	filters, err = parseStreamFilters(map[string]string{"filter": "hodl"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"hodl"}, filters.filterParams().Track)
+	}
+
+	invalidParams := []map[string]string{
+		{},
+		{"languages": "en"},
+		{"follow": "@spiceaihq"},
+		{"locations": "-122.75,36.8,-121.75"},
+		{"locations": "-122.75,36.8,-121.75,north"},
+		{"locations": "-121.75,37.8,-122.75,36.8"},
+	}
+	for _, params := range invalidParams {
+		_, err = parseStreamFilters(params)
+		assert.Error(t, err, params)
+	}
+}
+
+func TestStreamFiltersMatch(t *testing.T) {
+	filters, err := parseStreamFilters(map[string]string{
+		"track":     "bitcoin,ethereum classic,spiceai",
+		"follow":    "783214",
+		"locations": "-122.75,36.8,-121.75,37.8,-74,40,-73,41",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tweets := []struct {
+		tweet    *twitter.Tweet
+		expected []string
+	}{
+		{&twitter.Tweet{Text: "Bitcoin is up!"}, []string{"bitcoin"}},
+		{&twitter.Tweet{Text: "Classic: Ethereum"}, []string{"ethereum classic"}},
+		{&twitter.Tweet{Text: "ethereum only"}, []string{}},
+		{&twitter.Tweet{Text: "bitcoins are not matched"}, []string{}},
+		{&twitter.Tweet{Text: "truncated", ExtendedTweet: &twitter.ExtendedTweet{FullText: "long #bitcoin text"}}, []string{"bitcoin"}},
+		{&twitter.Tweet{Text: "tagged", Entities: &twitter.Entities{Hashtags: []twitter.HashtagEntity{{Text: "Bitcoin"}}}}, []string{"bitcoin"}},
+		{&twitter.Tweet{Text: "RT", RetweetedStatus: &twitter.Tweet{Text: "bitcoin", User: &twitter.User{IDStr: "783214"}}}, []string{"bitcoin", "follow:783214"}},
+		{&twitter.Tweet{Text: "hi", User: &twitter.User{IDStr: "1", ScreenName: "spiceai"}}, []string{"spiceai"}},
+		{&twitter.Tweet{Text: "reply", InReplyToUserIDStr: "783214"}, []string{"follow:783214"}},
+		{&twitter.Tweet{Text: "sf", Coordinates: &twitter.Coordinates{Coordinates: [2]float64{-122.4, 37.7}}}, []string{"location:0"}},
+		{&twitter.Tweet{Text: "nyc", Place: &twitter.Place{BoundingBox: &twitter.BoundingBox{
+			Coordinates: [][][2]float64{{{-74.2, 40.5}, {-74.2, 40.9}, {-73.7, 40.9}, {-73.7, 40.5}}},
+		}}}, []string{"location:1"}},
+		{&twitter.Tweet{Text: "london", Coordinates: &twitter.Coordinates{Coordinates: [2]float64{-0.1, 51.5}}}, []string{}},
+	}
+
+	for _, test := range tweets {
+		assert.Equal(t, test.expected, filters.match(test.tweet), test.tweet.Text)
+	}
+}
+
+func TestSendV1Tweet(t *testing.T) {
+	filters, err := parseStreamFilters(map[string]string{"track": "bitcoin,ethereum"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c := NewTwitterConnector()
+	c.filters = filters
+
+	var readData []byte
+	var readMetadata map[string]string
+	err = c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		readData = data
+		readMetadata = metadata
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	c.sendV1Tweet(&twitter.Tweet{
+		IDStr:     "1",
+		Text:      "bitcoin and ethereum",
+		CreatedAt: "Tue Mar 01 12:00:00 +0000 2022",
+	})
+
+	assert.Equal(t, "tweet", readMetadata["type"])
+	assert.Equal(t, "bitcoin,ethereum", readMetadata["matched"])
+
+	var tweets []map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(readData, &tweets)) && assert.Len(t, tweets, 1) {
+		assert.Equal(t, "1", tweets[0]["id_str"])
+		assert.Equal(t, []interface{}{"bitcoin", "ethereum"}, tweets[0]["tags"])
+	}
+}
@@ -0,0 +1,176 @@
+package twitter
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+const (
+	StatusStallWarning string = "stall_warning"
+	StatusLimit        string = "limit"
+	StatusDisconnect   string = "disconnect"
+	StatusError        string = "error"
+	StatusReconnect    string = "reconnect"
+
+	statusBufferSize = 100
+)
+
+// Twitter recommended reconnect backoffs
+// https://developer.twitter.com/en/docs/twitter-api/v1/tweets/filter-realtime/guides/connecting
+var (
+	networkBackoffStep    = 250 * time.Millisecond
+	networkBackoffMax     = 16 * time.Second
+	httpBackoffStart      = 5 * time.Second
+	httpBackoffMax        = 320 * time.Second
+	rateLimitBackoffStart = time.Minute
+	rateLimitBackoffMax   = time.Hour
+)
+
+type errorKind int
+
+const (
+	networkError errorKind = iota
+	httpError
+	rateLimitError
+)
+
+// StreamStatus is a stream health event reported on the Status() channel
+type StreamStatus struct {
+	Type    string
+	Time    time.Time
+	Code    string
+	Message string
+	// Percent of the stall warning queue that is full
+	PercentFull int
+	// Number of undelivered tweets since the connection started, from limit notices
+	Undelivered int64
+	// Wait before the next reconnect attempt
+	Backoff time.Duration
+}
+
+type reconnectBackoff struct {
+	network   time.Duration
+	http      time.Duration
+	rateLimit time.Duration
+}
+
+// Linear for network errors, exponential for HTTP errors and rate limiting
+func (b *reconnectBackoff) next(kind errorKind) time.Duration {
+	switch kind {
+	case networkError:
+		b.network += networkBackoffStep
+		if b.network > networkBackoffMax {
+			b.network = networkBackoffMax
+		}
+		return b.network
+	case rateLimitError:
+		if b.rateLimit == 0 {
+			b.rateLimit = rateLimitBackoffStart
+		} else {
+			b.rateLimit *= 2
+		}
+		if b.rateLimit > rateLimitBackoffMax {
+			b.rateLimit = rateLimitBackoffMax
+		}
+		return b.rateLimit
+	default:
+		if b.http == 0 {
+			b.http = httpBackoffStart
+		} else {
+			b.http *= 2
+		}
+		if b.http > httpBackoffMax {
+			b.http = httpBackoffMax
+		}
+		return b.http
+	}
+}
+
+func (b *reconnectBackoff) reset() {
+	b.network = 0
+	b.http = 0
+	b.rateLimit = 0
+}
+
+// Status returns the channel stream health events are reported on. Events are dropped if the channel is full.
+func (c *TwitterConnector) Status() <-chan StreamStatus {
+	return c.status
+}
+
+// Close stops the stream and flushes any pending batch
+func (c *TwitterConnector) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+
+	c.streamMutex.Lock()
+	if c.v1Stream != nil {
+		go c.v1Stream.Stop()
+		c.v1Stream = nil
+	}
+	if c.v2Body != nil {
+		c.v2Body.Close()
+		c.v2Body = nil
+	}
+	c.streamMutex.Unlock()
+
+	if c.batcher != nil {
+		return c.batcher.Flush()
+	}
+
+	return nil
+}
+
+func (c *TwitterConnector) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns false if the connector was closed while waiting
+func (c *TwitterConnector) wait(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *TwitterConnector) reconnectBackoff(kind errorKind) time.Duration {
+	backoff := c.backoff.next(kind)
+	c.reportStatus(StreamStatus{
+		Type:    StatusReconnect,
+		Message: fmt.Sprintf("reconnecting in %s", backoff),
+		Backoff: backoff,
+	})
+	return backoff
+}
+
+func (c *TwitterConnector) reportStatus(status StreamStatus) {
+	if status.Time.IsZero() {
+		status.Time = time.Now().UTC()
+	}
+
+	switch status.Type {
+	case StatusError, StatusDisconnect:
+		log.Printf("twitter data connector: %s: %s", aurora.BrightRed(status.Type), status.Message)
+	default:
+		log.Printf("twitter data connector: %s: %s", aurora.Yellow(status.Type), status.Message)
+	}
+
+	select {
+	case c.status <- status:
+	default:
+		// Nobody is reading the status channel
+	}
+}
@@ -0,0 +1,338 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dghubble/oauth1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	backoff := reconnectBackoff{}
+
+	var network []time.Duration
+	for i := 0; i < 66; i++ {
+		network = append(network, backoff.next(networkError))
+	}
+	assert.Equal(t, 250*time.Millisecond, network[0])
+	assert.Equal(t, 500*time.Millisecond, network[1])
+	assert.Equal(t, 16*time.Second, network[63])
+	assert.Equal(t, 16*time.Second, network[65])
+
+	var http []time.Duration
+	for i := 0; i < 8; i++ {
+		http = append(http, backoff.next(httpError))
+	}
+	assert.Equal(t, []time.Duration{
+		5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second,
+		80 * time.Second, 160 * time.Second, 320 * time.Second, 320 * time.Second,
+	}, http)
+
+	assert.Equal(t, time.Minute, backoff.next(rateLimitError))
+	assert.Equal(t, 2*time.Minute, backoff.next(rateLimitError))
+
+	backoff.reset()
+	assert.Equal(t, 250*time.Millisecond, backoff.next(networkError))
+	assert.Equal(t, 5*time.Second, backoff.next(httpError))
+	assert.Equal(t, time.Minute, backoff.next(rateLimitError))
+}
+
+func TestV1StreamHealth(t *testing.T) {
+	setShortBackoffs(t)
+
+	var mutex sync.Mutex
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.1/account/verify_credentials.json":
+			_, _ = w.Write([]byte(`{"screen_name":"spiceai"}`))
+		case "/1.1/statuses/filter.json":
+			mutex.Lock()
+			connections++
+			connection := connections
+			mutex.Unlock()
+
+			lines := []string{
+				`{"warning":{"code":"FALLING_BEHIND","message":"Your connection is falling behind","percent_full":60}}`,
+				`{"limit":{"track":12}}`,
+				`{"id_str":"1","text":"btc to the moon","retweet_count":0,"created_at":"Tue Mar 01 12:00:00 +0000 2022"}`,
+				`{"disconnect":{"code":7,"stream_name":"spiceai","reason":"admin logout"}}`,
+			}
+			if connection > 1 {
+				lines = []string{`{"id_str":"2","text":"btc again","retweet_count":0,"created_at":"Tue Mar 01 12:00:01 +0000 2022"}`}
+			}
+
+			flusher := w.(http.Flusher)
+			for _, line := range lines {
+				_, _ = w.Write([]byte(line + "\r\n"))
+				flusher.Flush()
+			}
+			// Keep the connection open like Twitter does
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	setHttpContext(t, server.URL)
+
+	c := NewTwitterConnector()
+
+	metadataChan := make(chan map[string]string, 2)
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		metadataChan <- metadata
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.Init(time.Time{}, 0, 0, map[string]string{
+		"consumer_key":    "key",
+		"consumer_secret": "secret",
+		"access_token":    "token",
+		"access_secret":   "secret",
+		"track":           "btc",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+
+	statuses := receiveStatuses(t, c, 4)
+	if assert.Len(t, statuses, 4) {
+		assert.Equal(t, StatusStallWarning, statuses[0].Type)
+		assert.Equal(t, "FALLING_BEHIND", statuses[0].Code)
+		assert.Equal(t, 60, statuses[0].PercentFull)
+		assert.Equal(t, StatusLimit, statuses[1].Type)
+		assert.Equal(t, int64(12), statuses[1].Undelivered)
+		assert.Equal(t, StatusDisconnect, statuses[2].Type)
+		assert.Equal(t, "7", statuses[2].Code)
+		assert.Equal(t, "admin logout", statuses[2].Message)
+		assert.Equal(t, StatusReconnect, statuses[3].Type)
+		assert.Equal(t, httpBackoffStart, statuses[3].Backoff)
+	}
+
+	for i, expected := range []map[string]string{
+		{"type": "tweet", "matched": "btc", "undelivered": "12"},
+		{"type": "tweet", "matched": "btc"},
+	} {
+		select {
+		case metadata := <-metadataChan:
+			assert.Equal(t, expected, metadata, "tweet %d", i)
+		case <-time.After(time.Second):
+			t.Fatalf("tweet %d was not received", i)
+		}
+	}
+
+	assert.NoError(t, c.Close())
+	assert.True(t, c.isClosed())
+}
+
+func TestV2StreamReconnect(t *testing.T) {
+	setShortBackoffs(t)
+
+	mockApi := &mockStreamApi{
+		t: t,
+		lines: []string{
+			`{"errors":[{"title":"operational-disconnect","detail":"This stream has been disconnected for operational reasons."}]}`,
+			`{"data":{"id":"1","text":"btc","created_at":"2022-03-01T12:00:00.000Z"},"matching_rules":[{"id":"1","tag":"btc"}]}`,
+		},
+	}
+	server := httptest.NewServer(mockApi)
+	defer server.Close()
+	setApiUrl(t, server.URL)
+
+	c := NewTwitterConnector()
+
+	tweetsChan := make(chan []StreamTweet, 10)
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		var tweets []StreamTweet
+		assert.NoError(t, json.Unmarshal(data, &tweets))
+		tweetsChan <- tweets
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.Init(time.Time{}, 0, 0, map[string]string{
+		"api_version":  "2",
+		"bearer_token": "test-token",
+		"rule.btc":     "btc",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+
+	// Each connection reports the operational disconnect, then the stream end and the reconnect
+	statuses := receiveStatuses(t, c, 6)
+	if assert.Len(t, statuses, 6) {
+		for i := 0; i < 6; i += 3 {
+			assert.Equal(t, StatusDisconnect, statuses[i].Type)
+			assert.Contains(t, statuses[i].Message, "operational-disconnect")
+			assert.Equal(t, StatusDisconnect, statuses[i+1].Type)
+			assert.Equal(t, StatusReconnect, statuses[i+2].Type)
+			// A received tweet resets the backoff
+			assert.Equal(t, networkBackoffStep, statuses[i+2].Backoff)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case tweets := <-tweetsChan:
+			if assert.Len(t, tweets, 1) {
+				assert.Equal(t, "1", tweets[0].ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("tweet was not received after reconnecting")
+		}
+	}
+
+	assert.NoError(t, c.Close())
+}
+
+func TestV2StreamStalled(t *testing.T) {
+	setShortBackoffs(t)
+	originalIdleTimeout := streamIdleTimeout
+	streamIdleTimeout = 50 * time.Millisecond
+	t.Cleanup(func() {
+		streamIdleTimeout = originalIdleTimeout
+	})
+
+	mockApi := &mockStreamApi{
+		t:     t,
+		lines: []string{``},
+		stall: true,
+	}
+	server := httptest.NewServer(mockApi)
+	defer server.Close()
+	setApiUrl(t, server.URL)
+
+	c := NewTwitterConnector()
+	err := c.Init(time.Time{}, 0, 0, map[string]string{
+		"api_version":  "2",
+		"bearer_token": "test-token",
+		"rule.btc":     "btc",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+
+	// A stream that stops sending keep-alives is closed and reconnected
+	statuses := receiveStatuses(t, c, 4)
+	if assert.Len(t, statuses, 4) {
+		for i := 0; i < 4; i += 2 {
+			assert.Equal(t, StatusDisconnect, statuses[i].Type)
+			assert.Contains(t, statuses[i].Message, "stream stalled")
+			assert.Equal(t, StatusReconnect, statuses[i+1].Type)
+		}
+	}
+
+	assert.NoError(t, c.Close())
+}
+
+func TestV2StreamRateLimited(t *testing.T) {
+	setShortBackoffs(t)
+
+	var mutex sync.Mutex
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		connections++
+		connection := connections
+		mutex.Unlock()
+
+		if connection > 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	setApiUrl(t, server.URL)
+
+	c := NewTwitterConnector()
+	c.bearerToken = "test-token"
+
+	stream, err := c.openStream()
+	if !assert.NoError(t, err) {
+		return
+	}
+	go c.runV2Stream(stream)
+	defer c.Close()
+
+	statuses := receiveStatuses(t, c, 5)
+	if assert.Len(t, statuses, 5) {
+		assert.Equal(t, StatusDisconnect, statuses[0].Type)
+		assert.Equal(t, StatusReconnect, statuses[1].Type)
+		assert.Equal(t, networkBackoffStep, statuses[1].Backoff)
+		assert.Equal(t, StatusError, statuses[2].Type)
+		assert.Contains(t, statuses[2].Message, "status code 429")
+		assert.Equal(t, StatusReconnect, statuses[3].Type)
+		assert.Equal(t, rateLimitBackoffStart, statuses[3].Backoff)
+		assert.Equal(t, StatusError, statuses[4].Type)
+	}
+}
+
+func TestCloseWithoutStream(t *testing.T) {
+	c := NewTwitterConnector()
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+	assert.False(t, c.wait(time.Hour))
+}
+
+func receiveStatuses(t *testing.T, c *TwitterConnector, count int) []StreamStatus {
+	var statuses []StreamStatus
+	for len(statuses) < count {
+		select {
+		case status := <-c.Status():
+			assert.False(t, status.Time.IsZero())
+			statuses = append(statuses, status)
+		case <-time.After(2 * time.Second):
+			t.Errorf("expected %d statuses, received %d", count, len(statuses))
+			return statuses
+		}
+	}
+	return statuses
+}
+
+func setShortBackoffs(t *testing.T) {
+	originalNetworkStep, originalHttpStart, originalRateLimitStart := networkBackoffStep, httpBackoffStart, rateLimitBackoffStart
+	networkBackoffStep = time.Millisecond
+	httpBackoffStart = 5 * time.Millisecond
+	rateLimitBackoffStart = 10 * time.Millisecond
+	t.Cleanup(func() {
+		networkBackoffStep, httpBackoffStart, rateLimitBackoffStart = originalNetworkStep, originalHttpStart, originalRateLimitStart
+	})
+}
+
+// Sends v1.1 requests to the test server
+func setHttpContext(t *testing.T, serverUrl string) {
+	target, err := url.Parse(serverUrl)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	originalHttpContext := httpContext
+	httpContext = context.WithValue(context.Background(), oauth1.HTTPClient, &http.Client{
+		Transport: rewriteTransport{target: target},
+	})
+	t.Cleanup(func() {
+		httpContext = originalHttpContext
+	})
+}
+
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (r rewriteTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.URL.Scheme = r.target.Scheme
+	request.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(request)
+}
@@ -5,12 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/oauth1"
 	"github.com/logrusorgru/aurora"
+	"github.com/spiceai/data-components-contrib/dataconnectors/batch"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -18,16 +23,55 @@ const (
 	TwitterConnectorName string = "twitter"
 )
 
+var (
+	// Context holding the base *http.Client used for v1.1 requests
+	httpContext = oauth1.NoContext
+)
+
 type TwitterConnector struct {
 	client       *twitter.Client
+	bearerToken  string
+	filters      *streamFilters
 	readHandlers []*func(data []byte, metadata map[string]string) ([]byte, error)
+	batcher      *batch.Batcher
+
+	status    chan StreamStatus
+	done      chan struct{}
+	closeOnce sync.Once
+	backoff   reconnectBackoff
+	// Undelivered tweets reported by the last limit notice of the current connection
+	undelivered int64
+
+	streamMutex sync.Mutex
+	v1Stream    *twitter.Stream
+	v2Body      io.Closer
 }
 
 func NewTwitterConnector() *TwitterConnector {
-	return &TwitterConnector{}
+	return &TwitterConnector{
+		status: make(chan StreamStatus, statusBufferSize),
+		done:   make(chan struct{}),
+	}
 }
 
 func (c *TwitterConnector) Init(epoch time.Time, period time.Duration, interval time.Duration, params map[string]string) error {
+	batcher, err := batch.NewBatcherFromParams(params, c.sendToHandlers)
+	if err != nil {
+		return err
+	}
+	c.batcher = batcher
+
+	switch params["api_version"] {
+	case "", "1":
+		return c.initV1(params)
+	case "2":
+		return c.initV2(params)
+	}
+
+	return fmt.Errorf("unsupported api_version '%s'", params["api_version"])
+}
+
+func (c *TwitterConnector) initV1(params map[string]string) error {
 	ck := params["consumer_key"]
 	if ck == "" {
 		return errors.New("consumer_key is required")
@@ -48,14 +92,15 @@ func (c *TwitterConnector) Init(epoch time.Time, period time.Duration, interval
 		return errors.New("access_secret is required")
 	}
 
-	filter := params["filter"]
-	if filter == "" {
-		return errors.New("filter is required")
+	filters, err := parseStreamFilters(params)
+	if err != nil {
+		return err
 	}
+	c.filters = filters
 
 	config := oauth1.NewConfig(ck, cs)
 	token := oauth1.NewToken(at, as)
-	httpClient := config.Client(oauth1.NoContext, token)
+	httpClient := config.Client(httpContext, token)
 
 	// Twitter client
 	c.client = twitter.NewClient(httpClient)
@@ -68,25 +113,115 @@ func (c *TwitterConnector) Init(epoch time.Time, period time.Duration, interval
 
 	log.Println(fmt.Sprintf("twitter data connector: verified credentials for %s", aurora.BrightBlue(user.ScreenName)))
 
+	stream, err := c.client.Streams.Filter(filters.filterParams())
+	if err != nil {
+		return fmt.Errorf("failed to start stream with filters %s: %s", aurora.BrightBlue(filters), err.Error())
+	}
+
+	log.Println(aurora.Green(fmt.Sprintf("started reading twitter stream with filters %s", aurora.BrightBlue(filters))))
+
+	go c.runV1Stream(stream)
+
+	return nil
+}
+
+// Reads the stream and reconnects with backoff until the connector is closed
+func (c *TwitterConnector) runV1Stream(stream *twitter.Stream) {
+	for {
+		kind := c.readV1Stream(stream)
+		if c.isClosed() {
+			return
+		}
+
+		if !c.wait(c.reconnectBackoff(kind)) {
+			return
+		}
+
+		var err error
+		stream, err = c.client.Streams.Filter(c.filters.filterParams())
+		for err != nil {
+			c.reportStatus(StreamStatus{Type: StatusError, Message: err.Error()})
+			if !c.wait(c.reconnectBackoff(httpError)) {
+				return
+			}
+			stream, err = c.client.Streams.Filter(c.filters.filterParams())
+		}
+	}
+}
+
+// Handles stream messages until the stream stops or is disconnected, and returns the kind of error that stopped it
+func (c *TwitterConnector) readV1Stream(stream *twitter.Stream) errorKind {
+	c.streamMutex.Lock()
+	if c.isClosed() {
+		c.streamMutex.Unlock()
+		go stream.Stop()
+		return networkError
+	}
+	c.v1Stream = stream
+	c.streamMutex.Unlock()
+
+	atomic.StoreInt64(&c.undelivered, 0)
+
+	// Streams that end without an error were stopped on a non-retryable HTTP status
+	kind := httpError
+	disconnected := false
+
 	demux := twitter.NewSwitchDemux()
 	demux.Tweet = func(tweet *twitter.Tweet) {
-		c.sendData(tweet)
+		c.backoff.reset()
+		c.sendV1Tweet(tweet)
 	}
-
-	filterParams := &twitter.StreamFilterParams{
-		Track:         []string{filter},
-		StallWarnings: twitter.Bool(true),
+	demux.Warning = func(warning *twitter.StallWarning) {
+		c.reportStatus(StreamStatus{
+			Type:        StatusStallWarning,
+			Code:        warning.Code,
+			Message:     warning.Message,
+			PercentFull: warning.PercentFull,
+		})
 	}
-	stream, err := c.client.Streams.Filter(filterParams)
-	if err != nil {
-		return fmt.Errorf("failed to start stream with filter %s: %s", aurora.BrightBlue(filter), err.Error())
+	demux.StreamLimit = func(limit *twitter.StreamLimit) {
+		atomic.StoreInt64(&c.undelivered, limit.Track)
+		c.reportStatus(StreamStatus{
+			Type:        StatusLimit,
+			Message:     fmt.Sprintf("%d tweets undelivered", limit.Track),
+			Undelivered: limit.Track,
+		})
+	}
+	demux.StreamDisconnect = func(disconnect *twitter.StreamDisconnect) {
+		c.reportStatus(StreamStatus{
+			Type:    StatusDisconnect,
+			Code:    fmt.Sprintf("%d", disconnect.Code),
+			Message: disconnect.Reason,
+		})
+		disconnected = true
+	}
+	demux.Other = func(message interface{}) {
+		if err, ok := message.(error); ok {
+			c.reportStatus(StreamStatus{Type: StatusError, Message: err.Error()})
+			kind = networkError
+		}
 	}
 
-	log.Println(aurora.Green(fmt.Sprintf("started reading twitter stream with filter %s", aurora.BrightBlue(filter))))
+	for message := range stream.Messages {
+		demux.Handle(message)
+		if disconnected {
+			break
+		}
+	}
 
-	go demux.HandleChan(stream.Messages)
+	// The stream would otherwise reconnect by itself without backoff
+	c.streamMutex.Lock()
+	if c.v1Stream == stream {
+		c.v1Stream = nil
+		go stream.Stop()
+	}
+	c.streamMutex.Unlock()
 
-	return nil
+	// Drain the stream until it is stopped
+	for range stream.Messages {
+	}
+
+	return kind
 }
 
 func (c *TwitterConnector) Read(handler func(data []byte, metadata map[string]string) ([]byte, error)) error {
@@ -94,27 +229,70 @@ func (c *TwitterConnector) Read(handler func(data []byte, metadata map[string]st
 	return nil
 }
 
-func (c *TwitterConnector) sendData(tweets ...*twitter.Tweet) {
-	if len(c.readHandlers) == 0 {
-		// Nothing to read
-		return
+// Tweet sent to handlers in v1.1 mode, with the filters it matched
+type matchedTweet struct {
+	*twitter.Tweet
+	Tags []string `json:"tags"`
+}
+
+func (c *TwitterConnector) sendV1Tweet(tweet *twitter.Tweet) {
+	tweetTime, err := tweet.CreatedAtTime()
+	if err != nil {
+		tweetTime = time.Now().UTC()
 	}
 
-	metadata := map[string]string{}
-	metadata["type"] = "tweet"
+	matched := c.filters.match(tweet)
+	c.sendTweet(&matchedTweet{Tweet: tweet, Tags: matched}, tweetTime, matched)
+}
 
-	errGroup, _ := errgroup.WithContext(context.Background())
+func (c *TwitterConnector) sendTweet(tweet interface{}, tweetTime time.Time, matched []string) {
+	if c.batcher != nil {
+		data, err := json.Marshal(tweet)
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+
+		err = c.batcher.Add(data, tweetTime)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		return
+	}
 
 	if len(c.readHandlers) == 0 {
+		// Nothing to read
 		return
 	}
 
-	data, err := json.Marshal(tweets)
+	data, err := json.Marshal([]interface{}{tweet})
 	if err != nil {
 		log.Println(err.Error())
 		return
 	}
 
+	metadata := map[string]string{}
+	metadata["matched"] = strings.Join(matched, ",")
+
+	err = c.sendToHandlers(data, metadata)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (c *TwitterConnector) sendToHandlers(data []byte, metadata map[string]string) error {
+	if len(c.readHandlers) == 0 {
+		// Nothing to read
+		return nil
+	}
+
+	metadata["type"] = "tweet"
+	if undelivered := atomic.LoadInt64(&c.undelivered); undelivered > 0 {
+		metadata["undelivered"] = fmt.Sprintf("%d", undelivered)
+	}
+
+	errGroup, _ := errgroup.WithContext(context.Background())
+
 	for _, handler := range c.readHandlers {
 		readHandler := *handler
 		errGroup.Go(func() error {
@@ -123,8 +301,5 @@ func (c *TwitterConnector) sendData(tweets ...*twitter.Tweet) {
 		})
 	}
 
-	err = errGroup.Wait()
-	if err != nil {
-		log.Println(err.Error())
-	}
+	return errGroup.Wait()
 }
@@ -0,0 +1,504 @@
+package twitter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+const (
+	rulesPath  = "/2/tweets/search/stream/rules"
+	streamPath = "/2/tweets/search/stream"
+
+	// Params prefixed with "rule." define a filtered stream rule tagged with the rest of the param name
+	ruleParamPrefix = "rule."
+)
+
+var (
+	apiUrl = "https://api.twitter.com"
+
+	// Twitter sends a keep-alive every 20 seconds, so a stream without any line for longer has stalled
+	streamIdleTimeout = 30 * time.Second
+
+	streamQuery = url.Values{
+		"expansions":   {"author_id"},
+		"tweet.fields": {"author_id,created_at,lang,public_metrics"},
+		"user.fields":  {"name,username,public_metrics"},
+	}
+)
+
+type StreamRule struct {
+	ID    string `json:"id,omitempty"`
+	Value string `json:"value"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+type MatchingRule struct {
+	ID  string `json:"id"`
+	Tag string `json:"tag,omitempty"`
+}
+
+// Tweet sent to handlers in v2 mode, flattened with its author and matching rules
+type StreamTweet struct {
+	ID           string `json:"id"`
+	Text         string `json:"text"`
+	CreatedAt    string `json:"created_at"`
+	Lang         string `json:"lang,omitempty"`
+	AuthorID     string `json:"author_id,omitempty"`
+	RetweetCount int    `json:"retweet_count"`
+	ReplyCount   int    `json:"reply_count"`
+	LikeCount    int    `json:"like_count"`
+	QuoteCount   int    `json:"quote_count"`
+
+	AuthorUsername       string `json:"author_username,omitempty"`
+	AuthorName           string `json:"author_name,omitempty"`
+	AuthorFollowersCount int    `json:"author_followers_count"`
+	AuthorFollowingCount int    `json:"author_following_count"`
+	AuthorTweetCount     int    `json:"author_tweet_count"`
+
+	MatchingRules []MatchingRule `json:"matching_rules"`
+	// Tags of the matching rules
+	Tags []string `json:"tags"`
+}
+
+type apiError struct {
+	Title   string `json:"title"`
+	Detail  string `json:"detail"`
+	Message string `json:"message"`
+	Value   string `json:"value"`
+}
+
+func (e *apiError) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = e.Message
+	}
+	if e.Value != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Title, message, e.Value)
+	}
+	return fmt.Sprintf("%s: %s", e.Title, message)
+}
+
+type rulesResponse struct {
+	Data   []StreamRule `json:"data"`
+	Errors []apiError   `json:"errors"`
+}
+
+type addRulesRequest struct {
+	Add []StreamRule `json:"add"`
+}
+
+type deleteRulesRequest struct {
+	Delete struct {
+		IDs []string `json:"ids"`
+	} `json:"delete"`
+}
+
+type publicMetrics struct {
+	RetweetCount   int `json:"retweet_count"`
+	ReplyCount     int `json:"reply_count"`
+	LikeCount      int `json:"like_count"`
+	QuoteCount     int `json:"quote_count"`
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
+	TweetCount     int `json:"tweet_count"`
+}
+
+type streamMessage struct {
+	Data *struct {
+		ID            string        `json:"id"`
+		Text          string        `json:"text"`
+		CreatedAt     string        `json:"created_at"`
+		Lang          string        `json:"lang"`
+		AuthorID      string        `json:"author_id"`
+		PublicMetrics publicMetrics `json:"public_metrics"`
+	} `json:"data"`
+	Includes struct {
+		Users []struct {
+			ID            string        `json:"id"`
+			Name          string        `json:"name"`
+			Username      string        `json:"username"`
+			PublicMetrics publicMetrics `json:"public_metrics"`
+		} `json:"users"`
+	} `json:"includes"`
+	MatchingRules []MatchingRule `json:"matching_rules"`
+	Errors        []apiError     `json:"errors"`
+}
+
+// Returns the filtered stream rules defined by the rule.<tag> params, sorted by tag
+func getStreamRules(params map[string]string) ([]StreamRule, error) {
+	var rules []StreamRule
+	for key, value := range params {
+		if !strings.HasPrefix(key, ruleParamPrefix) {
+			continue
+		}
+		tag := strings.TrimPrefix(key, ruleParamPrefix)
+		value = strings.TrimSpace(value)
+		if tag == "" || value == "" {
+			return nil, fmt.Errorf("invalid rule param '%s': a tag and a value are required", key)
+		}
+		rules = append(rules, StreamRule{Value: value, Tag: tag})
+	}
+
+	if len(rules) == 0 {
+		if filter := params["filter"]; filter != "" {
+			rules = append(rules, StreamRule{Value: filter, Tag: filter})
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, errors.New("at least one 'rule.<tag>' param or a filter is required")
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Tag < rules[j].Tag
+	})
+
+	return rules, nil
+}
+
+func (c *TwitterConnector) initV2(params map[string]string) error {
+	bearerToken := params["bearer_token"]
+	if bearerToken == "" {
+		return errors.New("bearer_token is required")
+	}
+	c.bearerToken = bearerToken
+
+	rules, err := getStreamRules(params)
+	if err != nil {
+		return err
+	}
+
+	err = c.syncStreamRules(rules)
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.openStream()
+	if err != nil {
+		return err
+	}
+
+	log.Println(aurora.Green(fmt.Sprintf("started reading twitter v2 filtered stream with %d rules", len(rules))))
+
+	go c.runV2Stream(stream)
+
+	return nil
+}
+
+// Reads the stream and reconnects with backoff until the connector is closed
+func (c *TwitterConnector) runV2Stream(stream *http.Response) {
+	for {
+		kind := c.readV2Stream(stream)
+		if c.isClosed() {
+			return
+		}
+
+		if !c.wait(c.reconnectBackoff(kind)) {
+			return
+		}
+
+		var err error
+		stream, err = c.openStream()
+		for err != nil {
+			c.reportStatus(StreamStatus{Type: StatusError, Message: err.Error()})
+			kind = networkError
+			var statusErr *statusCodeError
+			if errors.As(err, &statusErr) {
+				kind = httpError
+				if statusErr.statusCode == http.StatusTooManyRequests {
+					kind = rateLimitError
+				}
+			}
+			if !c.wait(c.reconnectBackoff(kind)) {
+				return
+			}
+			stream, err = c.openStream()
+		}
+	}
+}
+
+// Reads the stream until it ends, and returns the kind of error that ended it
+func (c *TwitterConnector) readV2Stream(stream *http.Response) errorKind {
+	c.streamMutex.Lock()
+	if c.isClosed() {
+		c.streamMutex.Unlock()
+		stream.Body.Close()
+		return networkError
+	}
+	c.v2Body = stream.Body
+	c.streamMutex.Unlock()
+
+	err := c.readStream(stream)
+	if err == nil {
+		err = io.EOF
+	}
+
+	c.streamMutex.Lock()
+	if c.v2Body == stream.Body {
+		c.v2Body = nil
+	}
+	c.streamMutex.Unlock()
+	stream.Body.Close()
+
+	if !c.isClosed() {
+		c.reportStatus(StreamStatus{Type: StatusDisconnect, Message: err.Error()})
+	}
+
+	return networkError
+}
+
+// Adds missing rules and removes stale ones so that the stream rules match the desired rules
+func (c *TwitterConnector) syncStreamRules(rules []StreamRule) error {
+	var existing rulesResponse
+	err := c.doApiRequest(http.MethodGet, rulesPath, nil, &existing)
+	if err != nil {
+		return fmt.Errorf("failed to get stream rules: %w", err)
+	}
+
+	desired := make(map[StreamRule]bool)
+	for _, rule := range rules {
+		desired[rule] = true
+	}
+
+	var staleIds []string
+	for _, rule := range existing.Data {
+		key := StreamRule{Value: rule.Value, Tag: rule.Tag}
+		if desired[key] {
+			delete(desired, key)
+			continue
+		}
+		staleIds = append(staleIds, rule.ID)
+	}
+
+	if len(staleIds) > 0 {
+		deleteRequest := deleteRulesRequest{}
+		deleteRequest.Delete.IDs = staleIds
+		err = c.doApiRequest(http.MethodPost, rulesPath, deleteRequest, &rulesResponse{})
+		if err != nil {
+			return fmt.Errorf("failed to delete stale stream rules: %w", err)
+		}
+		log.Printf("twitter data connector: deleted %d stale stream rules", len(staleIds))
+	}
+
+	var missing []StreamRule
+	for _, rule := range rules {
+		if desired[rule] {
+			missing = append(missing, rule)
+		}
+	}
+
+	if len(missing) > 0 {
+		err = c.doApiRequest(http.MethodPost, rulesPath, addRulesRequest{Add: missing}, &rulesResponse{})
+		if err != nil {
+			return fmt.Errorf("failed to add stream rules: %w", err)
+		}
+		for _, rule := range missing {
+			log.Printf("twitter data connector: added stream rule %s: %s", aurora.BrightBlue(rule.Tag), rule.Value)
+		}
+	}
+
+	return nil
+}
+
+func (c *TwitterConnector) doApiRequest(method string, path string, body interface{}, result *rulesResponse) error {
+	var requestBody []byte
+	if body != nil {
+		var err error
+		requestBody, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, method, apiUrl+path, bytes.NewReader(requestBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status code %d: %s", response.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+
+	err = json.Unmarshal(responseBody, result)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		return &result.Errors[0]
+	}
+
+	return nil
+}
+
+func (c *TwitterConnector) openStream() (*http.Response, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiUrl+streamPath+"?"+streamQuery.Encode(), nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.bearerToken)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to connect to stream: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer cancel()
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("failed to connect to stream: %w", &statusCodeError{statusCode: response.StatusCode, body: strings.TrimSpace(string(body))})
+	}
+
+	// Closing the body cancels the request, which also unblocks a read of a half-open connection
+	response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: cancel}
+
+	return response, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+type statusCodeError struct {
+	statusCode int
+	body       string
+}
+
+func (e *statusCodeError) Error() string {
+	return fmt.Sprintf("status code %d: %s", e.statusCode, e.body)
+}
+
+func (c *TwitterConnector) readStream(stream *http.Response) error {
+	// Closing the stream when no line, keep-alives included, is received in time, so it is reconnected
+	var stalled int32
+	idleTimer := time.AfterFunc(streamIdleTimeout, func() {
+		atomic.StoreInt32(&stalled, 1)
+		stream.Body.Close()
+	})
+	defer idleTimer.Stop()
+
+	scanner := bufio.NewScanner(stream.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		idleTimer.Reset(streamIdleTimeout)
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			// Keep-alive signal
+			continue
+		}
+
+		var message streamMessage
+		err := json.Unmarshal(line, &message)
+		if err != nil {
+			log.Printf("twitter data connector: invalid stream message received '%s': %s", string(line), err.Error())
+			continue
+		}
+
+		if message.Data == nil {
+			for _, apiErr := range message.Errors {
+				status := StreamStatus{Type: StatusError, Message: apiErr.Error()}
+				if apiErr.Title == "operational-disconnect" {
+					status.Type = StatusDisconnect
+				}
+				c.reportStatus(status)
+			}
+			continue
+		}
+
+		c.backoff.reset()
+		c.sendStreamTweet(newStreamTweet(&message))
+	}
+
+	if atomic.LoadInt32(&stalled) == 1 {
+		return fmt.Errorf("stream stalled: nothing received for %s", streamIdleTimeout)
+	}
+	return scanner.Err()
+}
+
+func newStreamTweet(message *streamMessage) *StreamTweet {
+	data := message.Data
+	tweet := &StreamTweet{
+		ID:            data.ID,
+		Text:          data.Text,
+		CreatedAt:     data.CreatedAt,
+		Lang:          data.Lang,
+		AuthorID:      data.AuthorID,
+		RetweetCount:  data.PublicMetrics.RetweetCount,
+		ReplyCount:    data.PublicMetrics.ReplyCount,
+		LikeCount:     data.PublicMetrics.LikeCount,
+		QuoteCount:    data.PublicMetrics.QuoteCount,
+		MatchingRules: message.MatchingRules,
+		Tags:          []string{},
+	}
+
+	for _, user := range message.Includes.Users {
+		if user.ID == data.AuthorID {
+			tweet.AuthorUsername = user.Username
+			tweet.AuthorName = user.Name
+			tweet.AuthorFollowersCount = user.PublicMetrics.FollowersCount
+			tweet.AuthorFollowingCount = user.PublicMetrics.FollowingCount
+			tweet.AuthorTweetCount = user.PublicMetrics.TweetCount
+			break
+		}
+	}
+
+	for _, rule := range message.MatchingRules {
+		if rule.Tag != "" {
+			tweet.Tags = append(tweet.Tags, rule.Tag)
+		}
+	}
+
+	return tweet
+}
+
+func (c *TwitterConnector) sendStreamTweet(tweet *StreamTweet) {
+	tweetTime, err := time.Parse(time.RFC3339, tweet.CreatedAt)
+	if err != nil {
+		tweetTime = time.Now().UTC()
+	}
+
+	c.sendTweet(tweet, tweetTime, tweet.Tags)
+}
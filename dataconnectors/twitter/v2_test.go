@@ -0,0 +1,219 @@
+package twitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStreamApi struct {
+	t       *testing.T
+	mutex   sync.Mutex
+	rules   []StreamRule
+	nextId  int
+	added   []StreamRule
+	deleted []string
+	lines   []string
+	// Keeps the stream open without writing after the lines, like a stalled connection
+	stall bool
+}
+
+func (m *mockStreamApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == rulesPath && r.Method == http.MethodGet:
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.writeJSON(w, rulesResponse{Data: m.rules})
+	case r.URL.Path == rulesPath && r.Method == http.MethodPost:
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		var request struct {
+			Add    []StreamRule `json:"add"`
+			Delete *struct {
+				IDs []string `json:"ids"`
+			} `json:"delete"`
+		}
+		assert.NoError(m.t, json.NewDecoder(r.Body).Decode(&request))
+		for _, rule := range request.Add {
+			m.nextId++
+			rule.ID = fmt.Sprintf("%d", m.nextId)
+			m.rules = append(m.rules, rule)
+			m.added = append(m.added, rule)
+		}
+		if request.Delete != nil {
+			m.deleted = append(m.deleted, request.Delete.IDs...)
+		}
+		m.writeJSON(w, rulesResponse{})
+	case r.URL.Path == streamPath:
+		assert.Equal(m.t, "author_id", r.URL.Query().Get("expansions"))
+		assert.Contains(m.t, r.URL.Query().Get("tweet.fields"), "public_metrics")
+		assert.Contains(m.t, r.URL.Query().Get("user.fields"), "public_metrics")
+		flusher := w.(http.Flusher)
+		for _, line := range m.lines {
+			_, _ = w.Write([]byte(line + "\r\n"))
+			flusher.Flush()
+		}
+		if m.stall {
+			<-r.Context().Done()
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (m *mockStreamApi) writeJSON(w http.ResponseWriter, value interface{}) {
+	data, err := json.Marshal(value)
+	assert.NoError(m.t, err)
+	_, _ = w.Write(data)
+}
+
+func setApiUrl(t *testing.T, url string) {
+	originalApiUrl := apiUrl
+	apiUrl = url
+	t.Cleanup(func() {
+		apiUrl = originalApiUrl
+	})
+}
+
+func TestGetStreamRules(t *testing.T) {
+	rules, err := getStreamRules(map[string]string{
+		"rule.eth":     "ethereum lang:en",
+		"rule.bitcoin": "bitcoin OR btc",
+		"bearer_token": "test-token",
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []StreamRule{
+			{Value: "bitcoin OR btc", Tag: "bitcoin"},
+			{Value: "ethereum lang:en", Tag: "eth"},
+		}, rules)
+	}
+
+	rules, err = getStreamRules(map[string]string{"filter": "hodl"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []StreamRule{{Value: "hodl", Tag: "hodl"}}, rules)
+	}
+
+	_, err = getStreamRules(map[string]string{"rule.empty": " "})
+	assert.Error(t, err)
+
+	_, err = getStreamRules(map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestInitV2(t *testing.T) {
+	mockApi := &mockStreamApi{
+		t: t,
+		rules: []StreamRule{
+			{ID: "100", Value: "bitcoin OR btc", Tag: "bitcoin"},
+			{ID: "101", Value: "dogecoin", Tag: "doge"},
+		},
+		nextId: 200,
+		lines: []string{
+			`{"data":{"id":"1","text":"btc to the moon","created_at":"2022-03-01T12:00:00.000Z","lang":"en","author_id":"42","public_metrics":{"retweet_count":1,"reply_count":2,"like_count":3,"quote_count":4}},"includes":{"users":[{"id":"42","name":"Satoshi","username":"satoshi","public_metrics":{"followers_count":10,"following_count":20,"tweet_count":30}}]},"matching_rules":[{"id":"100","tag":"bitcoin"}]}`,
+			``,
+			`{"data":{"id":"2","text":"eth and btc","created_at":"2022-03-01T12:00:01.000Z","lang":"en","author_id":"43","public_metrics":{}},"matching_rules":[{"id":"100","tag":"bitcoin"},{"id":"201","tag":"eth"}]}`,
+		},
+	}
+	server := httptest.NewServer(mockApi)
+	defer server.Close()
+	setApiUrl(t, server.URL)
+
+	c := NewTwitterConnector()
+
+	tweetsChan := make(chan []StreamTweet, 2)
+	err := c.Read(func(data []byte, metadata map[string]string) ([]byte, error) {
+		assert.Equal(t, "tweet", metadata["type"])
+		var tweets []StreamTweet
+		assert.NoError(t, json.Unmarshal(data, &tweets))
+		if assert.Len(t, tweets, 1) {
+			assert.Equal(t, strings.Join(tweets[0].Tags, ","), metadata["matched"])
+		}
+		tweetsChan <- tweets
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.Init(time.Time{}, 0, 0, map[string]string{
+		"api_version":  "2",
+		"bearer_token": "test-token",
+		"rule.bitcoin": "bitcoin OR btc",
+		"rule.eth":     "ethereum OR eth",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+
+	mockApi.mutex.Lock()
+	assert.Equal(t, []string{"101"}, mockApi.deleted)
+	assert.Equal(t, []StreamRule{{ID: "201", Value: "ethereum OR eth", Tag: "eth"}}, mockApi.added)
+	mockApi.mutex.Unlock()
+
+	var tweets []StreamTweet
+	for i := 0; i < 2; i++ {
+		select {
+		case received := <-tweetsChan:
+			tweets = append(tweets, received...)
+		case <-time.After(time.Second):
+			t.Fatal("tweet was not received")
+		}
+	}
+
+	if assert.Len(t, tweets, 2) {
+		assert.Equal(t, StreamTweet{
+			ID:                   "1",
+			Text:                 "btc to the moon",
+			CreatedAt:            "2022-03-01T12:00:00.000Z",
+			Lang:                 "en",
+			AuthorID:             "42",
+			RetweetCount:         1,
+			ReplyCount:           2,
+			LikeCount:            3,
+			QuoteCount:           4,
+			AuthorUsername:       "satoshi",
+			AuthorName:           "Satoshi",
+			AuthorFollowersCount: 10,
+			AuthorFollowingCount: 20,
+			AuthorTweetCount:     30,
+			MatchingRules:        []MatchingRule{{ID: "100", Tag: "bitcoin"}},
+			Tags:                 []string{"bitcoin"},
+		}, tweets[0])
+		assert.Equal(t, "2", tweets[1].ID)
+		assert.Empty(t, tweets[1].AuthorUsername)
+		assert.Equal(t, []string{"bitcoin", "eth"}, tweets[1].Tags)
+	}
+}
+
+func TestInitV2Unauthorized(t *testing.T) {
+	server := httptest.NewServer(&mockStreamApi{t: t})
+	defer server.Close()
+	setApiUrl(t, server.URL)
+
+	c := NewTwitterConnector()
+	err := c.Init(time.Time{}, 0, 0, map[string]string{
+		"api_version":  "2",
+		"bearer_token": "wrong-token",
+		"filter":       "hodl",
+	})
+	assert.Error(t, err)
+}
+
+func TestInitUnsupportedVersion(t *testing.T) {
+	c := NewTwitterConnector()
+	err := c.Init(time.Time{}, 0, 0, map[string]string{
+		"api_version": "3",
+	})
+	assert.Error(t, err)
+}
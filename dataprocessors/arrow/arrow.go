@@ -2,12 +2,14 @@ package arrow
 
 import (
 	"fmt"
+	"time"
 	"unsafe"
 
 	apache_arrow "github.com/apache/arrow/go/v10/arrow"
 	"github.com/apache/arrow/go/v10/arrow/array"
 	"github.com/apache/arrow/go/v10/arrow/flight"
 	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
 )
 
 const (
@@ -15,11 +17,15 @@ const (
 )
 
 type ArrowProcessor struct {
-	timeSelector string
-	identifiers  map[string]string
-	measurements map[string]string
-	categories   map[string]string
-	tags         []string
+	timeFormat      string
+	timeSelector    string
+	timePrecision   *conv.TimePrecision
+	timeZone        *time.Location
+	measurementType *conv.MeasurementType
+	identifiers     map[string]string
+	measurements    map[string]string
+	categories      map[string]string
+	tags            []string
 
 	streamPointer *flight.FlightService_DoGetClient
 }
@@ -29,11 +35,29 @@ func NewArrowProcessor() *ArrowProcessor {
 }
 
 func (p *ArrowProcessor) Init(params map[string]string, identifiers map[string]string, measurements map[string]string, categories map[string]string, tags []string) error {
+	if format, ok := params["time_format"]; ok {
+		p.timeFormat = format
+	}
 	if selector, ok := params["time_selector"]; ok && selector != "" {
 		p.timeSelector = selector
 	} else {
 		p.timeSelector = "time"
 	}
+	timePrecision, err := conv.NewTimePrecision(params)
+	if err != nil {
+		return err
+	}
+	p.timePrecision = timePrecision
+	timeZone, err := conv.NewTimeZone(params)
+	if err != nil {
+		return err
+	}
+	p.timeZone = timeZone
+	measurementType, err := conv.NewMeasurementType(params)
+	if err != nil {
+		return err
+	}
+	p.measurementType = measurementType
 
 	p.identifiers = identifiers
 	p.measurements = measurements
@@ -65,91 +89,123 @@ func (p *ArrowProcessor) GetRecord() (apache_arrow.Record, error) {
 	defer reader.Release()
 
 	if reader.Next() {
-		record := reader.Record()
+		return p.mapRecord(reader.Record())
+	}
+
+	return nil, fmt.Errorf("no record could be read")
+}
+
+// Maps the columns of the record to the time, id.*, measure.*, cat.* and tags columns
+func (p *ArrowProcessor) mapRecord(record apache_arrow.Record) (apache_arrow.Record, error) {
+	// Creating field map for quick look-up from field name
+	fieldMap := make(map[string]FieldInfo)
+	for fieldIndex, field := range record.Schema().Fields() {
+		fieldMap[field.Name] = FieldInfo{Index: fieldIndex, Field: field}
+	}
+
+	// Checking time column is present
+	timeField, ok := fieldMap[p.timeSelector]
+	if !ok {
+		return nil, fmt.Errorf("time column '%s' not found", p.timeSelector)
+	}
+	if !conv.IsTimeType(timeField.Field.Type) {
+		return nil, fmt.Errorf("time column '%s' type mistmach: %s is not supported", p.timeSelector, timeField.Field.Type)
+	}
+	// Like the parquet processor, records with null times are rejected as the time column can't be null
+	if record.Columns()[timeField.Index].NullN() > 0 {
+		return nil, fmt.Errorf("time column '%s' has a null value", p.timeSelector)
+	}
 
-		// Creating field map for quick look-up from field name
-		fieldMap := make(map[string]FieldInfo)
-		for fieldIndex, field := range record.Schema().Fields() {
-			fieldMap[field.Name] = FieldInfo{Index: fieldIndex, Field: field}
+	// Creating new record: new schema + new columns
+	pool := memory.NewGoAllocator()
+	newFields := []apache_arrow.Field{{Name: conv.TimeColumnName, Type: timeField.Field.Type}}
+	newColumns := []apache_arrow.Array{record.Columns()[timeField.Index]}
+	if p.timePrecision.Timestamp || timeField.Field.Type.ID() != apache_arrow.INT64 {
+		// Epoch times are in the unit of the precision, and other times are converted to it
+		timeBuilder := p.timePrecision.NewBuilder(pool)
+		defer timeBuilder.Release()
+		err := p.timePrecision.AppendColumn(timeBuilder, record.Columns()[timeField.Index], p.timeFormat, p.timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time in column '%s': %w", p.timeSelector, err)
 		}
+		timeArray := timeBuilder.NewArray()
+		defer timeArray.Release()
+		newFields[0].Type = timeArray.DataType()
+		newColumns[0] = timeArray
+	}
 
-		// Checking time column is present
-		timeField, ok := fieldMap[p.timeSelector]
+	for outputName, inputName := range p.identifiers {
+		fieldInfo, ok := fieldMap[inputName]
 		if !ok {
-			return nil, fmt.Errorf("time column '%s' not found", p.timeSelector)
+			return nil, fmt.Errorf("identifier column '%s' not found", inputName)
 		}
-		if timeField.Field.Type != apache_arrow.PrimitiveTypes.Int64 {
-			return nil, fmt.Errorf("time column '%s' type mistmach", p.timeSelector)
+		column, err := conv.CastStrings(record.Columns()[fieldInfo.Index], pool)
+		if err != nil {
+			return nil, fmt.Errorf("identifier column '%s' type mistmach: %w", inputName, err)
 		}
-
-		// Creating new record: new schema + new columns
-		pool := memory.NewGoAllocator()
-		newFields := []apache_arrow.Field{timeField.Field}
-		newColumns := []apache_arrow.Array{record.Columns()[timeField.Index]}
-
-		for outputName, inputName := range p.identifiers {
-			fieldInfo, ok := fieldMap[inputName]
-			if !ok {
-				return nil, fmt.Errorf("identifier column '%s' not found", inputName)
-			}
-			if fieldInfo.Field.Type != apache_arrow.BinaryTypes.String {
-				return nil, fmt.Errorf("identifier column '%s' type mistmach", inputName)
-			}
-			newFields = append(newFields, apache_arrow.Field{Name: fmt.Sprintf("id.%s", outputName), Type: fieldInfo.Field.Type})
-			newColumns = append(newColumns, record.Columns()[fieldInfo.Index])
+		defer column.Release()
+		newFields = append(newFields, apache_arrow.Field{Name: conv.IdentifierPrefix + outputName, Type: column.DataType()})
+		newColumns = append(newColumns, column)
+	}
+	for outputName, inputName := range p.measurements {
+		fieldInfo, ok := fieldMap[inputName]
+		if !ok {
+			return nil, fmt.Errorf("measurement column '%s' not found", inputName)
 		}
-		for outputName, inputName := range p.measurements {
-			fieldInfo, ok := fieldMap[inputName]
-			if !ok {
-				return nil, fmt.Errorf("measurement column '%s' not found", inputName)
-			}
-			// Converting type if needed
-			if fieldInfo.Field.Type == apache_arrow.PrimitiveTypes.Float64 {
-				newColumns = append(newColumns, record.Columns()[fieldInfo.Index])
-			} else if fieldInfo.Field.Type == apache_arrow.PrimitiveTypes.Int64 {
-				arrayBuilder := array.NewFloat64Builder(pool)
-				defer arrayBuilder.Release()
-				column := record.Columns()[fieldInfo.Index].(*array.Int64)
-				for entryIndex := 0; entryIndex < int(record.NumRows()); entryIndex++ {
-					if column.IsNull(entryIndex) {
-						arrayBuilder.AppendNull()
-					} else {
-						arrayBuilder.Append(float64(column.Value(entryIndex)))
-					}
-				}
-				newColumns = append(newColumns, arrayBuilder.NewArray())
-			} else {
-				return nil, fmt.Errorf("measurement column '%s' type mistmach", inputName)
-			}
-			newFields = append(newFields, apache_arrow.Field{
-				Name: fmt.Sprintf("measure.%s", outputName), Type: apache_arrow.PrimitiveTypes.Float64})
+		// Converting type if needed
+		outputType, err := p.measurementType.OutputType(fieldInfo.Field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("measurement column '%s' type mistmach: %w", inputName, err)
 		}
-		for outputName, inputName := range p.categories {
-			fieldInfo, ok := fieldMap[inputName]
-			if !ok {
-				return nil, fmt.Errorf("category column '%s' not found", inputName)
-			}
-			if fieldInfo.Field.Type != apache_arrow.BinaryTypes.String {
-				return nil, fmt.Errorf("category column '%s' type mistmach", inputName)
-			}
-			newFields = append(newFields, apache_arrow.Field{Name: fmt.Sprintf("cat.%s", outputName), Type: fieldInfo.Field.Type})
-			newColumns = append(newColumns, record.Columns()[fieldInfo.Index])
+		column, err := conv.CastMeasurements(record.Columns()[fieldInfo.Index], outputType, pool)
+		if err != nil {
+			return nil, fmt.Errorf("measurement column '%s': %w", inputName, err)
 		}
-		for _, inputName := range p.tags {
-			fieldInfo, ok := fieldMap[inputName]
-			if !ok {
-				return nil, fmt.Errorf("tag column '%s' not found", inputName)
-			}
-			if fieldInfo.Field.Type != apache_arrow.BinaryTypes.String {
-				return nil, fmt.Errorf("tag column '%s' type mistmach", inputName)
+		defer column.Release()
+		newColumns = append(newColumns, column)
+		newFields = append(newFields, apache_arrow.Field{
+			Name: conv.MeasurementPrefix + outputName, Type: outputType})
+	}
+	for outputName, inputName := range p.categories {
+		fieldInfo, ok := fieldMap[inputName]
+		if !ok {
+			return nil, fmt.Errorf("category column '%s' not found", inputName)
+		}
+		column, err := conv.CastStrings(record.Columns()[fieldInfo.Index], pool)
+		if err != nil {
+			return nil, fmt.Errorf("category column '%s' type mistmach: %w", inputName, err)
+		}
+		defer column.Release()
+		newFields = append(newFields, apache_arrow.Field{Name: conv.CategoryPrefix + outputName, Type: column.DataType()})
+		newColumns = append(newColumns, column)
+	}
+	// Aggregating tags
+	var tagColumns []apache_arrow.Array
+	for _, inputName := range p.tags {
+		fieldInfo, ok := fieldMap[inputName]
+		if !ok {
+			return nil, fmt.Errorf("tag column '%s' not found", inputName)
+		}
+		if !conv.IsTagType(fieldInfo.Field.Type) {
+			return nil, fmt.Errorf("tag column '%s' type mistmach: %s is not supported", inputName, fieldInfo.Field.Type)
+		}
+		tagColumns = append(tagColumns, record.Columns()[fieldInfo.Index])
+	}
+	tagsBuilder := conv.NewTagsBuilder(pool)
+	defer tagsBuilder.Release()
+	for i := 0; i < int(record.NumRows()); i++ {
+		tagsBuilder.NewRow()
+		for j, column := range tagColumns {
+			if err := tagsBuilder.AppendValue(column, i); err != nil {
+				return nil, fmt.Errorf("tag column '%s': %w", p.tags[j], err)
 			}
-			newFields = append(newFields, apache_arrow.Field{Name: fmt.Sprintf("tag.%s", inputName), Type: fieldInfo.Field.Type})
-			newColumns = append(newColumns, record.Columns()[fieldInfo.Index])
 		}
-
-		newRecord := array.NewRecord(apache_arrow.NewSchema(newFields, nil), newColumns, record.NumRows())
-		return newRecord, nil
 	}
+	tagsArray := tagsBuilder.NewArray()
+	defer tagsArray.Release()
+	newFields = append(newFields, tagsBuilder.Field())
+	newColumns = append(newColumns, tagsArray)
 
-	return nil, fmt.Errorf("no record could be read")
+	newRecord := array.NewRecord(apache_arrow.NewSchema(newFields, nil), newColumns, record.NumRows())
+	return newRecord, nil
 }
@@ -0,0 +1,189 @@
+package arrow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	apache_arrow "github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/ipc"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/spiceai/spiceai/pkg/util"
+)
+
+const (
+	ArrowIpcProcessorName string = "arrow-ipc"
+)
+
+// Arrow IPC files, including Feather v2 files, start with this magic string. Other data is read as an IPC stream.
+var ipcFileMagic = []byte("ARROW1")
+
+// ArrowIpcProcessor reads Arrow IPC stream or file bytes, such as written by pandas or polars, with the column mapping
+// of the ArrowProcessor
+type ArrowIpcProcessor struct {
+	ArrowProcessor
+
+	dataMutex sync.RWMutex
+	data      []byte
+	dataHash  []byte
+}
+
+func NewArrowIpcProcessor() *ArrowIpcProcessor {
+	return &ArrowIpcProcessor{}
+}
+
+func (p *ArrowIpcProcessor) OnData(data []byte) ([]byte, error) {
+	p.dataMutex.Lock()
+	defer p.dataMutex.Unlock()
+
+	newDataHash, err := util.ComputeNewHash(p.data, p.dataHash, data)
+	if err != nil {
+		return nil, fmt.Errorf("error computing new data hash in arrow-ipc processor: %w", err)
+	}
+
+	if newDataHash != nil {
+		// Only update data if new
+		p.data = data
+		p.dataHash = newDataHash
+	}
+
+	return data, nil
+}
+
+// GetRecord maps all the record batches of the data and returns them as a single record
+func (p *ArrowIpcProcessor) GetRecord() (apache_arrow.Record, error) {
+	p.dataMutex.Lock()
+	defer p.dataMutex.Unlock()
+
+	if len(p.data) == 0 {
+		return nil, nil
+	}
+
+	batches, err := readIpcBatches(p.data)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseRecords(batches)
+
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("no record could be read")
+	}
+
+	var records []apache_arrow.Record
+	defer func() {
+		releaseRecords(records)
+	}()
+	for _, batch := range batches {
+		record, err := p.mapRecord(batch)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	record, err := concatenateRecords(records)
+	if err != nil {
+		return nil, err
+	}
+
+	p.data = nil
+	return record, nil
+}
+
+func readIpcBatches(data []byte) ([]apache_arrow.Record, error) {
+	var batches []apache_arrow.Record
+
+	if bytes.HasPrefix(data, ipcFileMagic) {
+		reader, err := ipc.NewFileReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read arrow ipc file: %w", err)
+		}
+		defer reader.Close()
+
+		for i := 0; i < reader.NumRecords(); i++ {
+			batch, err := reader.Record(i)
+			if err != nil {
+				releaseRecords(batches)
+				return nil, fmt.Errorf("failed to read arrow ipc file: %w", err)
+			}
+			batch.Retain()
+			batches = append(batches, batch)
+		}
+		return batches, nil
+	}
+
+	reader, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read arrow ipc stream: %w", err)
+	}
+	defer reader.Release()
+
+	for {
+		batch, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			releaseRecords(batches)
+			return nil, fmt.Errorf("failed to read arrow ipc stream: %w", err)
+		}
+		batch.Retain()
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// Concatenates the columns of the records, which have the same fields but possibly in a different order
+func concatenateRecords(records []apache_arrow.Record) (apache_arrow.Record, error) {
+	if len(records) == 1 {
+		records[0].Retain()
+		return records[0], nil
+	}
+
+	pool := memory.NewGoAllocator()
+	schema := records[0].Schema()
+	columns := make([]apache_arrow.Array, len(schema.Fields()))
+	numRows := int64(0)
+	for _, record := range records {
+		numRows += record.NumRows()
+	}
+
+	for i, field := range schema.Fields() {
+		chunks := make([]apache_arrow.Array, len(records))
+		for j, record := range records {
+			indices := record.Schema().FieldIndices(field.Name)
+			if len(indices) == 0 {
+				releaseArrays(columns)
+				return nil, fmt.Errorf("column '%s' not found in record batch %d", field.Name, j)
+			}
+			chunks[j] = record.Column(indices[0])
+		}
+
+		column, err := array.Concatenate(chunks, pool)
+		if err != nil {
+			releaseArrays(columns)
+			return nil, fmt.Errorf("failed to concatenate column '%s': %w", field.Name, err)
+		}
+		columns[i] = column
+	}
+	defer releaseArrays(columns)
+
+	return array.NewRecord(schema, columns, numRows), nil
+}
+
+func releaseRecords(records []apache_arrow.Record) {
+	for _, record := range records {
+		record.Release()
+	}
+}
+
+func releaseArrays(arrays []apache_arrow.Array) {
+	for _, arr := range arrays {
+		if arr != nil {
+			arr.Release()
+		}
+	}
+}
@@ -0,0 +1,552 @@
+package arrow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	apache_arrow "github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
+	"github.com/apache/arrow/go/v10/arrow/ipc"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrowIpcProcessor(t *testing.T) {
+	batches := getTestBatches()
+	defer releaseRecords(batches)
+
+	streamData, err := writeIpcStream(batches)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	fileData, err := writeIpcFile(batches)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	t.Run("GetRecord() - stream", testIpcGetRecordFunc(streamData))
+	t.Run("GetRecord() - file", testIpcGetRecordFunc(fileData))
+	t.Run("GetRecord() called twice", testIpcGetRecordTwiceFunc(streamData))
+	t.Run("GetRecord() missing column", testIpcGetRecordMissingColumnFunc(fileData))
+	t.Run("GetRecord() invalid data", testIpcGetRecordInvalidDataFunc())
+	t.Run("GetRecord() time precision", testIpcGetRecordTimePrecisionFunc(streamData))
+	t.Run("GetRecord() measurement types", testIpcGetRecordMeasurementTypesFunc())
+	t.Run("GetRecord() time types", testIpcGetRecordTimeTypesFunc())
+	t.Run("GetRecord() string types", testIpcGetRecordStringTypesFunc())
+	t.Run("GetRecord() null time", testIpcGetRecordNullTimeFunc())
+}
+
+func testIpcGetRecordFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewArrowIpcProcessor()
+		err := p.Init(
+			map[string]string{"time_selector": "timestamp"},
+			map[string]string{"symbol": "symbol"},
+			map[string]string{"close": "price", "volume": "volume"},
+			nil,
+			[]string{"side"})
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, int64(5), record.NumRows())
+		assert.Equal(t, 5, len(record.Schema().Fields()))
+
+		expectedColumns := map[string]string{
+			"time":           "[1605312000 1605313800 1605315600 1605317400 1605319200]",
+			"id.symbol":      `["BTC-USD" "BTC-USD" "BTC-USD" "BTC-USD" "BTC-USD"]`,
+			"measure.close":  "[16339.56 (null) 16100.5 16150 16200.25]",
+			"measure.volume": "[12 3 7 0 1]",
+			"tags":           `[["buy"] ["sell"] ["sell"] ["buy"] ["buy"]]`,
+		}
+		for name, expected := range expectedColumns {
+			indices := record.Schema().FieldIndices(name)
+			if assert.Len(t, indices, 1, "column %s", name) {
+				assert.Equal(t, expected, record.Column(indices[0]).String(), "column %s", name)
+			}
+		}
+	}
+}
+
+func testIpcGetRecordTwiceFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewArrowIpcProcessor()
+		err := p.Init(map[string]string{"time_selector": "timestamp"}, nil, map[string]string{"close": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		assert.NoError(t, err)
+		if assert.NotNil(t, record) {
+			record.Release()
+		}
+
+		record, err = p.GetRecord()
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+
+		// The same data isn't processed again
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+		record, err = p.GetRecord()
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	}
+}
+
+func testIpcGetRecordMissingColumnFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewArrowIpcProcessor()
+		err := p.Init(map[string]string{"time_selector": "timestamp"}, nil, map[string]string{"open": "open"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		_, err = p.GetRecord()
+		assert.Error(t, err)
+	}
+}
+
+func testIpcGetRecordInvalidDataFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		for _, data := range [][]byte{[]byte("not arrow"), []byte("ARROW1\x00\x00not a file")} {
+			p := NewArrowIpcProcessor()
+			err := p.Init(map[string]string{}, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			_, err = p.OnData(data)
+			assert.NoError(t, err)
+
+			_, err = p.GetRecord()
+			assert.Error(t, err)
+		}
+	}
+}
+
+// Tests the epoch times are read in the unit of the time_precision param
+func testIpcGetRecordTimePrecisionFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewArrowIpcProcessor()
+		err := p.Init(map[string]string{"time_selector": "timestamp", "time_precision": "s"}, nil, map[string]string{"close": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, apache_arrow.FixedWidthTypes.Timestamp_s, record.Schema().Field(0).Type)
+		assert.Equal(t, []apache_arrow.Timestamp{1605312000, 1605313800, 1605315600, 1605317400, 1605319200}, record.Column(0).(*array.Timestamp).TimestampValues())
+
+		err = p.Init(map[string]string{"time_precision": "seconds"}, nil, nil, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests numeric and boolean measurement columns are converted to float64 by default, or kept with "keep"
+func testIpcGetRecordMeasurementTypesFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		batch := getTypedMeasurementsBatch()
+		defer batch.Release()
+		data, err := writeIpcStream([]apache_arrow.Record{batch})
+		if !assert.NoError(t, err) {
+			return
+		}
+		measurements := map[string]string{"count": "count", "ratio": "ratio", "amount": "amount", "active": "active"}
+
+		getRecord := func(measurementType string) (apache_arrow.Record, error) {
+			p := NewArrowIpcProcessor()
+			err := p.Init(map[string]string{"time_selector": "timestamp", "measurement_type": measurementType}, nil, measurements, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.OnData(data)
+			assert.NoError(t, err)
+			return p.GetRecord()
+		}
+
+		expectedColumns := map[string]string{
+			"measure.count":  "[7 (null)]",
+			"measure.ratio":  "[0.5 1.25]",
+			"measure.amount": "[10.5 -0.25]",
+			"measure.active": "[1 0]",
+		}
+		expectedTypes := map[string]apache_arrow.DataType{
+			"measure.count":  apache_arrow.PrimitiveTypes.Int32,
+			"measure.ratio":  apache_arrow.PrimitiveTypes.Float32,
+			"measure.amount": &apache_arrow.Decimal128Type{Precision: 10, Scale: 2},
+			"measure.active": apache_arrow.PrimitiveTypes.Uint8,
+		}
+
+		record, err := getRecord("")
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			for name, expected := range expectedColumns {
+				indices := record.Schema().FieldIndices(name)
+				if assert.Len(t, indices, 1, "column %s", name) {
+					assert.Equal(t, apache_arrow.PrimitiveTypes.Float64, record.Schema().Field(indices[0]).Type, "column %s", name)
+					assert.Equal(t, expected, record.Column(indices[0]).String(), "column %s", name)
+				}
+			}
+			record.Release()
+		}
+
+		record, err = getRecord("keep")
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			for name, expected := range expectedTypes {
+				indices := record.Schema().FieldIndices(name)
+				if assert.Len(t, indices, 1, "column %s", name) {
+					assert.True(t, apache_arrow.TypeEqual(expected, record.Schema().Field(indices[0]).Type), "column %s", name)
+				}
+			}
+			record.Release()
+		}
+
+		// The decimal amounts have fractions
+		_, err = getRecord("int64")
+		assert.Error(t, err)
+
+		_, err = getRecord("double")
+		assert.Error(t, err)
+	}
+}
+
+// Tests timestamp, date and string time columns are converted to the output time unit
+func testIpcGetRecordTimeTypesFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		pool := memory.NewGoAllocator()
+		schema := apache_arrow.NewSchema([]apache_arrow.Field{
+			{Name: "ts_ms", Type: &apache_arrow.TimestampType{Unit: apache_arrow.Millisecond, TimeZone: "America/New_York"}},
+			{Name: "ts_us", Type: apache_arrow.FixedWidthTypes.Timestamp_us},
+			{Name: "day", Type: apache_arrow.FixedWidthTypes.Date32},
+			{Name: "day64", Type: apache_arrow.FixedWidthTypes.Date64},
+			{Name: "text", Type: apache_arrow.BinaryTypes.String},
+			{Name: "price", Type: apache_arrow.PrimitiveTypes.Float64},
+		}, nil)
+		recordBuilder := array.NewRecordBuilder(pool, schema)
+		defer recordBuilder.Release()
+		recordBuilder.Field(0).(*array.TimestampBuilder).AppendValues([]apache_arrow.Timestamp{1605312000123, 1605313800000}, nil)
+		recordBuilder.Field(1).(*array.TimestampBuilder).AppendValues([]apache_arrow.Timestamp{1605312000123456, 1605313800000000}, nil)
+		recordBuilder.Field(2).(*array.Date32Builder).AppendValues([]apache_arrow.Date32{18580, 18581}, nil)
+		recordBuilder.Field(3).(*array.Date64Builder).AppendValues([]apache_arrow.Date64{1605312000000, 1605398400000}, nil)
+		recordBuilder.Field(4).(*array.StringBuilder).AppendValues([]string{"2020-11-14 00:00", "2020-11-14 00:30"}, nil)
+		recordBuilder.Field(5).(*array.Float64Builder).AppendValues([]float64{16339.56, 16100.5}, nil)
+		batch := recordBuilder.NewRecord()
+		defer batch.Release()
+
+		data, err := writeIpcStream([]apache_arrow.Record{batch})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		getTimes := func(params map[string]string) (apache_arrow.Array, error) {
+			p := NewArrowIpcProcessor()
+			err := p.Init(params, nil, map[string]string{"price": "price"}, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.OnData(data)
+			assert.NoError(t, err)
+			record, err := p.GetRecord()
+			if err != nil {
+				return nil, err
+			}
+			return record.Column(0), nil
+		}
+
+		expectedTimes := map[string]string{
+			"ts_ms": "[1605312000 1605313800]",
+			"ts_us": "[1605312000 1605313800]",
+			"day":   "[1605312000 1605398400]",
+			"day64": "[1605312000 1605398400]",
+		}
+		for selector, expected := range expectedTimes {
+			times, err := getTimes(map[string]string{"time_selector": selector})
+			if assert.NoError(t, err, selector) {
+				assert.Equal(t, apache_arrow.PrimitiveTypes.Int64, times.DataType(), selector)
+				assert.Equal(t, expected, times.String(), selector)
+			}
+		}
+
+		// Timestamps keep sub-second times with the time_precision param
+		times, err := getTimes(map[string]string{"time_selector": "ts_ms", "time_precision": "ms"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []apache_arrow.Timestamp{1605312000123, 1605313800000}, times.(*array.Timestamp).TimestampValues())
+		}
+		times, err = getTimes(map[string]string{"time_selector": "ts_us", "time_precision": "us"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, apache_arrow.Timestamp(1605312000123456), times.(*array.Timestamp).Value(0))
+		}
+
+		// Strings are parsed with the time_format and time_zone params
+		times, err = getTimes(map[string]string{"time_selector": "text", "time_format": "2006-01-02 15:04", "time_zone": "America/New_York"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "[1605330000 1605331800]", times.String())
+		}
+		_, err = getTimes(map[string]string{"time_selector": "text"})
+		assert.Error(t, err)
+
+		_, err = getTimes(map[string]string{"time_selector": "price"})
+		assert.EqualError(t, err, "time column 'price' type mistmach: float64 is not supported")
+	}
+}
+
+func testIpcGetRecordNullTimeFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		pool := memory.NewGoAllocator()
+		schema := apache_arrow.NewSchema([]apache_arrow.Field{
+			{Name: "epoch", Type: apache_arrow.PrimitiveTypes.Int64, Nullable: true},
+			{Name: "ts", Type: apache_arrow.FixedWidthTypes.Timestamp_ms, Nullable: true},
+			{Name: "price", Type: apache_arrow.PrimitiveTypes.Float64},
+		}, nil)
+		recordBuilder := array.NewRecordBuilder(pool, schema)
+		defer recordBuilder.Release()
+		recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 0}, []bool{true, false})
+		recordBuilder.Field(1).(*array.TimestampBuilder).AppendValues([]apache_arrow.Timestamp{1605312000000, 0}, []bool{true, false})
+		recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{16339.56, 16100.5}, nil)
+		batch := recordBuilder.NewRecord()
+		defer batch.Release()
+
+		data, err := writeIpcStream([]apache_arrow.Record{batch})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// The time column can't be null, whether it is passed through or converted
+		for _, params := range []map[string]string{
+			{"time_selector": "epoch"},
+			{"time_selector": "epoch", "time_precision": "s"},
+			{"time_selector": "ts"},
+		} {
+			p := NewArrowIpcProcessor()
+			err := p.Init(params, nil, map[string]string{"price": "price"}, nil, nil)
+			assert.NoError(t, err)
+
+			_, err = p.OnData(data)
+			assert.NoError(t, err)
+
+			_, err = p.GetRecord()
+			assert.EqualError(t, err, fmt.Sprintf("time column '%s' has a null value", params["time_selector"]))
+		}
+	}
+}
+
+// Tests dictionary, large string and numeric identifier, category and tag columns
+func testIpcGetRecordStringTypesFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		pool := memory.NewGoAllocator()
+		dictionaryType := &apache_arrow.DictionaryType{IndexType: apache_arrow.PrimitiveTypes.Int32, ValueType: apache_arrow.BinaryTypes.String}
+		schema := apache_arrow.NewSchema([]apache_arrow.Field{
+			{Name: "timestamp", Type: apache_arrow.PrimitiveTypes.Int64},
+			{Name: "symbol", Type: dictionaryType, Nullable: true},
+			{Name: "exchange", Type: apache_arrow.BinaryTypes.LargeString},
+			{Name: "block", Type: apache_arrow.PrimitiveTypes.Uint64},
+			{Name: "fee", Type: apache_arrow.PrimitiveTypes.Float64},
+			{Name: "side", Type: dictionaryType},
+			{Name: "active", Type: apache_arrow.FixedWidthTypes.Boolean},
+		}, nil)
+		recordBuilder := array.NewRecordBuilder(pool, schema)
+		defer recordBuilder.Release()
+		recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800, 1605315600}, nil)
+		symbolBuilder := recordBuilder.Field(1).(*array.BinaryDictionaryBuilder)
+		assert.NoError(t, symbolBuilder.AppendString("BTC-USD"))
+		symbolBuilder.AppendNull()
+		assert.NoError(t, symbolBuilder.AppendString("BTC-USD"))
+		recordBuilder.Field(2).(*array.LargeStringBuilder).AppendValues([]string{"coinbase", "kraken", "coinbase"}, nil)
+		recordBuilder.Field(3).(*array.Uint64Builder).AppendValues([]uint64{15000000, 15000001, 18446744073709551615}, nil)
+		recordBuilder.Field(4).(*array.Float64Builder).AppendValues([]float64{0.25, 1, 1e21}, nil)
+		sideBuilder := recordBuilder.Field(5).(*array.BinaryDictionaryBuilder)
+		for _, side := range []string{"buy", "sell", "buy"} {
+			assert.NoError(t, sideBuilder.AppendString(side))
+		}
+		recordBuilder.Field(6).(*array.BooleanBuilder).AppendValues([]bool{true, false, true}, nil)
+		batch := recordBuilder.NewRecord()
+		defer batch.Release()
+
+		data, err := writeIpcStream([]apache_arrow.Record{batch})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		p := NewArrowIpcProcessor()
+		err = p.Init(
+			map[string]string{"time_selector": "timestamp"},
+			map[string]string{"symbol": "symbol", "block": "block"},
+			nil,
+			map[string]string{"exchange": "exchange", "fee": "fee"},
+			[]string{"side"})
+		assert.NoError(t, err)
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		expectedColumns := map[string]string{
+			"id.symbol": `{ dictionary: ["BTC-USD"]
+  indices: [0 (null) 0] }`,
+			"id.block":     `["15000000" "15000001" "18446744073709551615"]`,
+			"cat.exchange": `["coinbase" "kraken" "coinbase"]`,
+			"cat.fee":      `["0.25" "1" "1000000000000000000000"]`,
+			"tags":         `[["buy"] ["sell"] ["buy"]]`,
+		}
+		for name, expected := range expectedColumns {
+			indices := record.Schema().FieldIndices(name)
+			if assert.Len(t, indices, 1, "column %s", name) {
+				assert.Equal(t, expected, record.Column(indices[0]).String(), "column %s", name)
+			}
+		}
+
+		// Dictionaries of strings keep their encoding, and other types are strings
+		fields := record.Schema().Fields()
+		for i, field := range fields[1 : len(fields)-1] {
+			if field.Name == "id.symbol" {
+				assert.True(t, apache_arrow.TypeEqual(dictionaryType, field.Type), "column %s", field.Name)
+			} else {
+				assert.Equal(t, apache_arrow.BinaryTypes.String, field.Type, "column %s", field.Name)
+			}
+			assert.True(t, apache_arrow.TypeEqual(field.Type, record.Column(i+1).DataType()), "column %s", field.Name)
+		}
+
+		// Booleans are not identifiers
+		p = NewArrowIpcProcessor()
+		err = p.Init(map[string]string{"time_selector": "timestamp"}, map[string]string{"active": "active"}, nil, nil, nil)
+		assert.NoError(t, err)
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+		_, err = p.GetRecord()
+		assert.EqualError(t, err, "identifier column 'active' type mistmach: bool is not a string or numeric type")
+	}
+}
+
+// Returns a record batch of 2 rows with measurement columns of several types
+func getTypedMeasurementsBatch() apache_arrow.Record {
+	pool := memory.NewGoAllocator()
+	schema := apache_arrow.NewSchema([]apache_arrow.Field{
+		{Name: "timestamp", Type: apache_arrow.PrimitiveTypes.Int64},
+		{Name: "count", Type: apache_arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "ratio", Type: apache_arrow.PrimitiveTypes.Float32},
+		{Name: "amount", Type: &apache_arrow.Decimal128Type{Precision: 10, Scale: 2}},
+		{Name: "active", Type: apache_arrow.FixedWidthTypes.Boolean},
+	}, nil)
+
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800}, nil)
+	recordBuilder.Field(1).(*array.Int32Builder).AppendValues([]int32{7, 0}, []bool{true, false})
+	recordBuilder.Field(2).(*array.Float32Builder).AppendValues([]float32{0.5, 1.25}, nil)
+	recordBuilder.Field(3).(*array.Decimal128Builder).AppendValues([]decimal128.Num{decimal128.FromI64(1050), decimal128.FromI64(-25)}, nil)
+	recordBuilder.Field(4).(*array.BooleanBuilder).AppendValues([]bool{true, false}, nil)
+
+	return recordBuilder.NewRecord()
+}
+
+// Returns two record batches of 2 and 3 rows
+func getTestBatches() []apache_arrow.Record {
+	pool := memory.NewGoAllocator()
+	schema := apache_arrow.NewSchema([]apache_arrow.Field{
+		{Name: "timestamp", Type: apache_arrow.PrimitiveTypes.Int64},
+		{Name: "symbol", Type: apache_arrow.BinaryTypes.String},
+		{Name: "price", Type: apache_arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "volume", Type: apache_arrow.PrimitiveTypes.Int64},
+		{Name: "side", Type: apache_arrow.BinaryTypes.String},
+	}, nil)
+
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800}, nil)
+	recordBuilder.Field(1).(*array.StringBuilder).AppendValues([]string{"BTC-USD", "BTC-USD"}, nil)
+	recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{16339.56, 0}, []bool{true, false})
+	recordBuilder.Field(3).(*array.Int64Builder).AppendValues([]int64{12, 3}, nil)
+	recordBuilder.Field(4).(*array.StringBuilder).AppendValues([]string{"buy", "sell"}, nil)
+	first := recordBuilder.NewRecord()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605315600, 1605317400, 1605319200}, nil)
+	recordBuilder.Field(1).(*array.StringBuilder).AppendValues([]string{"BTC-USD", "BTC-USD", "BTC-USD"}, nil)
+	recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{16100.5, 16150, 16200.25}, nil)
+	recordBuilder.Field(3).(*array.Int64Builder).AppendValues([]int64{7, 0, 1}, nil)
+	recordBuilder.Field(4).(*array.StringBuilder).AppendValues([]string{"sell", "buy", "buy"}, nil)
+	second := recordBuilder.NewRecord()
+
+	return []apache_arrow.Record{first, second}
+}
+
+func writeIpcStream(batches []apache_arrow.Record) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := ipc.NewWriter(&buffer, ipc.WithSchema(batches[0].Schema()))
+	for _, batch := range batches {
+		if err := writer.Write(batch); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Writes an IPC file with a seekable in-memory writer
+func writeIpcFile(batches []apache_arrow.Record) ([]byte, error) {
+	var buffer seekBuffer
+	writer, err := ipc.NewFileWriter(&buffer, ipc.WithSchema(batches[0].Schema()))
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range batches {
+		if err := writer.Write(batch); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.data, nil
+}
+
+type seekBuffer struct {
+	data   []byte
+	offset int64
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	end := b.offset + int64(len(p))
+	if end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	copy(b.data[b.offset:], p)
+	b.offset = end
+	return len(p), nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		b.offset = offset
+	case io.SeekCurrent:
+		b.offset += offset
+	case io.SeekEnd:
+		b.offset = int64(len(b.data)) + offset
+	}
+	return b.offset, nil
+}
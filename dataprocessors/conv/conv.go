@@ -1,18 +1,17 @@
 package conv
 
 import (
-	"strconv"
-	"strings"
+	"github.com/apache/arrow/go/v10/arrow"
 )
 
+// ParseMeasurement parses a measurement of a text input as a float64, see ParseNumber for the supported formats
 func ParseMeasurement(measurement string) (float64, error) {
-	if strings.HasPrefix(measurement, "0x") {
-		val, err := strconv.ParseUint(measurement[2:], 16, 64)
-		if err != nil {
-			return 0, err
-		}
-		return float64(val), nil
+	n, err := ParseNumber(measurement)
+	if err != nil {
+		return 0, err
 	}
-
-	return strconv.ParseFloat(measurement, 64)
+	if n, err = n.Convert(arrow.PrimitiveTypes.Float64); err != nil {
+		return 0, err
+	}
+	return n.float64(), nil
 }
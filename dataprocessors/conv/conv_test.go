@@ -3,6 +3,7 @@ package conv
 import (
 	"testing"
 
+	"github.com/apache/arrow/go/v10/arrow"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,3 +50,50 @@ func TestParseMeasurement(t *testing.T) {
 		}
 	})
 }
+
+func TestParseNumber(t *testing.T) {
+	for value, expected := range map[string]string{
+		"0x1fdaae6df2":         "136812850674",
+		"-42":                  "-42",
+		"18446744073709551615": "18446744073709551615",
+		"1.5e3":                "1500",
+		"true":                 "1",
+		"false":                "0",
+	} {
+		n, err := ParseNumber(value)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, expected, n.String(), value)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "0xzz", "1,5"} {
+		_, err := ParseNumber(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseNumberConvert(t *testing.T) {
+	for value, expected := range map[string]float64{
+		"0x0":                 0,
+		"123456":              123456,
+		"123456789.123456789": 123456789.123456789,
+		"true":                1,
+	} {
+		n, err := ParseNumber(value)
+		if assert.NoError(t, err, value) {
+			converted, err := n.Convert(arrow.PrimitiveTypes.Float64)
+			if assert.NoError(t, err, value) {
+				assert.Equal(t, expected, converted.float64(), value)
+			}
+		}
+	}
+
+	n, err := ParseNumber("300")
+	if assert.NoError(t, err) {
+		_, err = n.Convert(arrow.PrimitiveTypes.Uint8)
+		assert.EqualError(t, err, "300 overflows uint8")
+
+		_, err = n.Convert(arrow.BinaryTypes.String)
+		assert.EqualError(t, err, "utf8 is not a measurement type")
+	}
+}
@@ -0,0 +1,359 @@
+package conv
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+)
+
+const measurementTypeKeep = "keep"
+
+var measurementTypes = map[string]arrow.DataType{
+	"float64": arrow.PrimitiveTypes.Float64,
+	"float32": arrow.PrimitiveTypes.Float32,
+	"int64":   arrow.PrimitiveTypes.Int64,
+	"int32":   arrow.PrimitiveTypes.Int32,
+	"int16":   arrow.PrimitiveTypes.Int16,
+	"int8":    arrow.PrimitiveTypes.Int8,
+	"uint64":  arrow.PrimitiveTypes.Uint64,
+	"uint32":  arrow.PrimitiveTypes.Uint32,
+	"uint16":  arrow.PrimitiveTypes.Uint16,
+	"uint8":   arrow.PrimitiveTypes.Uint8,
+}
+
+// MeasurementType is the type of the output measurement columns set by the measurement_type param. Without it,
+// measurements are Float64. With "keep", typed input columns keep their numeric type, booleans are Uint8 0/1
+// and text inputs are Float64.
+type MeasurementType struct {
+	// The output type, or nil to keep the input type
+	DataType arrow.DataType
+}
+
+func NewMeasurementType(params map[string]string) (*MeasurementType, error) {
+	name, ok := params["measurement_type"]
+	if !ok || name == "" {
+		return &MeasurementType{DataType: arrow.PrimitiveTypes.Float64}, nil
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == measurementTypeKeep {
+		return &MeasurementType{}, nil
+	}
+	if dataType, ok := measurementTypes[name]; ok {
+		return &MeasurementType{DataType: dataType}, nil
+	}
+	if strings.HasPrefix(name, "decimal128(") && strings.HasSuffix(name, ")") {
+		args := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, "decimal128("), ")"), ",")
+		if len(args) == 2 {
+			precision, precisionErr := strconv.Atoi(strings.TrimSpace(args[0]))
+			scale, scaleErr := strconv.Atoi(strings.TrimSpace(args[1]))
+			if precisionErr == nil && scaleErr == nil && precision >= 1 && precision <= 38 && scale >= 0 && scale <= precision {
+				return &MeasurementType{DataType: &arrow.Decimal128Type{Precision: int32(precision), Scale: int32(scale)}}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("invalid measurement_type '%s': must be float64, float32, int8, int16, int32, int64, uint8, uint16, uint32, uint64, decimal128(<precision>, <scale>) or keep", name)
+}
+
+// OutputType returns the type of the output column of a measurement column of the input type. Text inputs
+// have the type String.
+func (m *MeasurementType) OutputType(input arrow.DataType) (arrow.DataType, error) {
+	if !IsMeasurementInputType(input) {
+		return nil, fmt.Errorf("%s is not a numeric or boolean type", input)
+	}
+	if m.DataType != nil {
+		return m.DataType, nil
+	}
+
+	switch input.ID() {
+	case arrow.STRING:
+		return arrow.PrimitiveTypes.Float64, nil
+	case arrow.BOOL:
+		return arrow.PrimitiveTypes.Uint8, nil
+	}
+	return input, nil
+}
+
+// IsMeasurementInputType returns whether columns of the type can be converted to measurements
+func IsMeasurementInputType(dataType arrow.DataType) bool {
+	switch dataType.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64,
+		arrow.FLOAT32, arrow.FLOAT64, arrow.DECIMAL128, arrow.BOOL, arrow.STRING:
+		return true
+	}
+	return false
+}
+
+type numberKind int
+
+const (
+	intNumber numberKind = iota
+	uintNumber
+	floatNumber
+	decimalNumber
+)
+
+// Number is a measurement value, read as the integer, float or decimal of its input, and converted to the
+// output type by Convert before being appended with AppendNumber
+type Number struct {
+	kind    numberKind
+	i       int64
+	u       uint64
+	f       float64
+	d       decimal128.Num
+	scale   int32
+	literal string
+}
+
+// ParseNumber parses a measurement of a text input: an integer, a hexadecimal integer prefixed with 0x, a float
+// or true/false as 1/0
+func ParseNumber(str string) (Number, error) {
+	if strings.HasPrefix(str, "0x") {
+		u, err := strconv.ParseUint(str[2:], 16, 64)
+		if err != nil {
+			return Number{}, err
+		}
+		return Number{kind: uintNumber, u: u}, nil
+	}
+	switch str {
+	case "true":
+		return Number{kind: intNumber, i: 1}, nil
+	case "false":
+		return Number{kind: intNumber, i: 0}, nil
+	}
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return Number{kind: intNumber, i: i}, nil
+	}
+	if u, err := strconv.ParseUint(str, 10, 64); err == nil {
+		return Number{kind: uintNumber, u: u}, nil
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Number{}, err
+	}
+	return Number{kind: floatNumber, f: f, literal: str}, nil
+}
+
+// NumberAt returns the measurement at index i of the column, which must not be null
+func NumberAt(column arrow.Array, i int) (Number, error) {
+	switch c := column.(type) {
+	case *array.Int64:
+		return Number{kind: intNumber, i: c.Value(i)}, nil
+	case *array.Int32:
+		return Number{kind: intNumber, i: int64(c.Value(i))}, nil
+	case *array.Int16:
+		return Number{kind: intNumber, i: int64(c.Value(i))}, nil
+	case *array.Int8:
+		return Number{kind: intNumber, i: int64(c.Value(i))}, nil
+	case *array.Uint64:
+		return Number{kind: uintNumber, u: c.Value(i)}, nil
+	case *array.Uint32:
+		return Number{kind: uintNumber, u: uint64(c.Value(i))}, nil
+	case *array.Uint16:
+		return Number{kind: uintNumber, u: uint64(c.Value(i))}, nil
+	case *array.Uint8:
+		return Number{kind: uintNumber, u: uint64(c.Value(i))}, nil
+	case *array.Float64:
+		return Number{kind: floatNumber, f: c.Value(i)}, nil
+	case *array.Float32:
+		return Number{kind: floatNumber, f: float64(c.Value(i))}, nil
+	case *array.Decimal128:
+		return Number{kind: decimalNumber, d: c.Value(i), scale: c.DataType().(*arrow.Decimal128Type).Scale}, nil
+	case *array.Boolean:
+		if c.Value(i) {
+			return Number{kind: intNumber, i: 1}, nil
+		}
+		return Number{kind: intNumber, i: 0}, nil
+	case *array.String:
+		return ParseNumber(c.Value(i))
+	}
+	return Number{}, fmt.Errorf("%s is not a numeric or boolean type", column.DataType())
+}
+
+func (n Number) String() string {
+	switch n.kind {
+	case intNumber:
+		return strconv.FormatInt(n.i, 10)
+	case uintNumber:
+		return strconv.FormatUint(n.u, 10)
+	case decimalNumber:
+		return n.rat().FloatString(int(n.scale))
+	}
+	return strconv.FormatFloat(n.f, 'f', -1, 64)
+}
+
+// Convert returns the number converted to the type, or an error if it overflows the type, or has a fraction
+// and the type is an integer
+func (n Number) Convert(dataType arrow.DataType) (Number, error) {
+	switch dataType.ID() {
+	case arrow.FLOAT64:
+		return Number{kind: floatNumber, f: n.float64()}, nil
+	case arrow.FLOAT32:
+		f := n.float64()
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return Number{}, fmt.Errorf("%s overflows float32", n)
+		}
+		return Number{kind: floatNumber, f: f}, nil
+	case arrow.INT64, arrow.INT32, arrow.INT16, arrow.INT8:
+		bits := dataType.(arrow.FixedWidthDataType).BitWidth()
+		r, err := n.integer()
+		if err != nil {
+			return Number{}, err
+		}
+		if !r.IsInt64() || (bits < 64 && (r.Int64() < -1<<(bits-1) || r.Int64() > 1<<(bits-1)-1)) {
+			return Number{}, fmt.Errorf("%s overflows %s", n, dataType)
+		}
+		return Number{kind: intNumber, i: r.Int64()}, nil
+	case arrow.UINT64, arrow.UINT32, arrow.UINT16, arrow.UINT8:
+		bits := dataType.(arrow.FixedWidthDataType).BitWidth()
+		r, err := n.integer()
+		if err != nil {
+			return Number{}, err
+		}
+		if !r.IsUint64() || (bits < 64 && r.Uint64() > 1<<bits-1) {
+			return Number{}, fmt.Errorf("%s overflows %s", n, dataType)
+		}
+		return Number{kind: uintNumber, u: r.Uint64()}, nil
+	case arrow.DECIMAL128:
+		decimalType := dataType.(*arrow.Decimal128Type)
+		d, err := n.decimal(decimalType.Scale)
+		if err != nil || !d.FitsInPrecision(decimalType.Precision) {
+			return Number{}, fmt.Errorf("%s overflows %s", n, dataType)
+		}
+		return Number{kind: decimalNumber, d: d, scale: decimalType.Scale}, nil
+	}
+	return Number{}, fmt.Errorf("%s is not a measurement type", dataType)
+}
+
+// AppendNumber appends a number converted to the type of the builder
+func AppendNumber(builder array.Builder, n Number) {
+	switch b := builder.(type) {
+	case *array.Float64Builder:
+		b.Append(n.f)
+	case *array.Float32Builder:
+		b.Append(float32(n.f))
+	case *array.Int64Builder:
+		b.Append(n.i)
+	case *array.Int32Builder:
+		b.Append(int32(n.i))
+	case *array.Int16Builder:
+		b.Append(int16(n.i))
+	case *array.Int8Builder:
+		b.Append(int8(n.i))
+	case *array.Uint64Builder:
+		b.Append(n.u)
+	case *array.Uint32Builder:
+		b.Append(uint32(n.u))
+	case *array.Uint16Builder:
+		b.Append(uint16(n.u))
+	case *array.Uint8Builder:
+		b.Append(uint8(n.u))
+	case *array.Decimal128Builder:
+		b.Append(n.d)
+	}
+}
+
+// CastMeasurements returns the measurement column converted to the type. Columns of the type are returned as is,
+// retained. The returned array must be released.
+func CastMeasurements(column arrow.Array, dataType arrow.DataType, pool memory.Allocator) (arrow.Array, error) {
+	if arrow.TypeEqual(column.DataType(), dataType) {
+		column.Retain()
+		return column, nil
+	}
+	if !IsMeasurementInputType(column.DataType()) {
+		return nil, fmt.Errorf("%s is not a numeric or boolean type", column.DataType())
+	}
+
+	builder := array.NewBuilder(pool, dataType)
+	defer builder.Release()
+	builder.Reserve(column.Len())
+	for i := 0; i < column.Len(); i++ {
+		if column.IsNull(i) {
+			builder.AppendNull()
+			continue
+		}
+		n, err := NumberAt(column, i)
+		if err != nil {
+			return nil, err
+		}
+		if n, err = n.Convert(dataType); err != nil {
+			return nil, fmt.Errorf("value at row %d: %w", i, err)
+		}
+		AppendNumber(builder, n)
+	}
+
+	return builder.NewArray(), nil
+}
+
+func (n Number) float64() float64 {
+	switch n.kind {
+	case intNumber:
+		return float64(n.i)
+	case uintNumber:
+		return float64(n.u)
+	case decimalNumber:
+		return n.d.ToFloat64(n.scale)
+	}
+	return n.f
+}
+
+// Returns the exact value of the number
+func (n Number) rat() *big.Rat {
+	switch n.kind {
+	case intNumber:
+		return new(big.Rat).SetInt64(n.i)
+	case uintNumber:
+		return new(big.Rat).SetUint64(n.u)
+	case decimalNumber:
+		return new(big.Rat).SetFrac(n.d.BigInt(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n.scale)), nil))
+	}
+	if n.literal != "" {
+		// The parsed text is exact, unlike its float
+		if r, ok := new(big.Rat).SetString(n.literal); ok {
+			return r
+		}
+	}
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(n.f, 'g', -1, 64))
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+func (n Number) integer() (*big.Int, error) {
+	switch n.kind {
+	case intNumber:
+		return big.NewInt(n.i), nil
+	case uintNumber:
+		return new(big.Int).SetUint64(n.u), nil
+	}
+	r := n.rat()
+	if r == nil || !r.IsInt() {
+		return nil, fmt.Errorf("%s is not an integer", n)
+	}
+	return r.Num(), nil
+}
+
+func (n Number) decimal(scale int32) (decimal128.Num, error) {
+	r := n.rat()
+	if r == nil {
+		return decimal128.Num{}, fmt.Errorf("%s is not a decimal", n)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !r.IsInt() {
+		return decimal128.Num{}, fmt.Errorf("%s has more than %d decimals", n, scale)
+	}
+	if r.Num().BitLen() > 127 {
+		return decimal128.Num{}, fmt.Errorf("%s overflows decimal128", n)
+	}
+	return decimal128.FromBigInt(r.Num()), nil
+}
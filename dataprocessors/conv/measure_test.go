@@ -0,0 +1,205 @@
+package conv
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasurementType(t *testing.T) {
+	t.Run("Default float64", func(t *testing.T) {
+		m, err := NewMeasurementType(map[string]string{})
+		if assert.NoError(t, err) {
+			outputType, err := m.OutputType(arrow.PrimitiveTypes.Int32)
+			assert.NoError(t, err)
+			assert.Equal(t, arrow.PrimitiveTypes.Float64, outputType)
+		}
+	})
+
+	t.Run("Named types", func(t *testing.T) {
+		for name, expected := range map[string]arrow.DataType{
+			"int8":               arrow.PrimitiveTypes.Int8,
+			"UINT32":             arrow.PrimitiveTypes.Uint32,
+			"float32":            arrow.PrimitiveTypes.Float32,
+			"decimal128(10, 2)":  &arrow.Decimal128Type{Precision: 10, Scale: 2},
+			" decimal128(38,0) ": &arrow.Decimal128Type{Precision: 38, Scale: 0},
+			"decimal128(38, 38)": &arrow.Decimal128Type{Precision: 38, Scale: 38},
+		} {
+			m, err := NewMeasurementType(map[string]string{"measurement_type": name})
+			if assert.NoError(t, err, name) {
+				outputType, err := m.OutputType(arrow.BinaryTypes.String)
+				assert.NoError(t, err)
+				assert.True(t, arrow.TypeEqual(expected, outputType), name)
+			}
+		}
+	})
+
+	t.Run("Keep", func(t *testing.T) {
+		m, err := NewMeasurementType(map[string]string{"measurement_type": "keep"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		for input, expected := range map[arrow.DataType]arrow.DataType{
+			arrow.PrimitiveTypes.Int16:                    arrow.PrimitiveTypes.Int16,
+			arrow.PrimitiveTypes.Float32:                  arrow.PrimitiveTypes.Float32,
+			&arrow.Decimal128Type{Precision: 9, Scale: 3}: &arrow.Decimal128Type{Precision: 9, Scale: 3},
+			arrow.FixedWidthTypes.Boolean:                 arrow.PrimitiveTypes.Uint8,
+			arrow.BinaryTypes.String:                      arrow.PrimitiveTypes.Float64,
+		} {
+			outputType, err := m.OutputType(input)
+			if assert.NoError(t, err) {
+				assert.True(t, arrow.TypeEqual(expected, outputType), input.String())
+			}
+		}
+
+		_, err = m.OutputType(arrow.FixedWidthTypes.Date32)
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, name := range []string{"double", "int128", "decimal128", "decimal128(39, 2)", "decimal128(10, 11)", "decimal128(10)"} {
+			_, err := NewMeasurementType(map[string]string{"measurement_type": name})
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestNumberConvert(t *testing.T) {
+	t.Run("Integers", func(t *testing.T) {
+		n, err := ParseNumber("127")
+		if assert.NoError(t, err) {
+			converted, err := n.Convert(arrow.PrimitiveTypes.Int8)
+			assert.NoError(t, err)
+			assert.Equal(t, "127", converted.String())
+		}
+
+		for _, value := range []string{"128", "-129", "1.5", "NaN"} {
+			n, err := ParseNumber(value)
+			if assert.NoError(t, err, value) {
+				_, err = n.Convert(arrow.PrimitiveTypes.Int8)
+				assert.Error(t, err, value)
+			}
+		}
+
+		// Integers beyond the precision of float64 are exact
+		n, err = ParseNumber("18446744073709551615")
+		if assert.NoError(t, err) {
+			converted, err := n.Convert(arrow.PrimitiveTypes.Uint64)
+			assert.NoError(t, err)
+			assert.Equal(t, "18446744073709551615", converted.String())
+
+			_, err = n.Convert(arrow.PrimitiveTypes.Int64)
+			assert.Error(t, err)
+		}
+
+		n, err = ParseNumber("-1")
+		if assert.NoError(t, err) {
+			_, err = n.Convert(arrow.PrimitiveTypes.Uint8)
+			assert.Error(t, err)
+		}
+
+		// Floats without a fraction are integers
+		n, err = ParseNumber("2.0e3")
+		if assert.NoError(t, err) {
+			converted, err := n.Convert(arrow.PrimitiveTypes.Int16)
+			assert.NoError(t, err)
+			assert.Equal(t, "2000", converted.String())
+		}
+	})
+
+	t.Run("Hex and booleans", func(t *testing.T) {
+		for value, expected := range map[string]string{"0xff": "255", "true": "1", "false": "0"} {
+			n, err := ParseNumber(value)
+			if assert.NoError(t, err, value) {
+				converted, err := n.Convert(arrow.PrimitiveTypes.Uint8)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, converted.String())
+			}
+		}
+	})
+
+	t.Run("Decimals", func(t *testing.T) {
+		decimalType := &arrow.Decimal128Type{Precision: 10, Scale: 2}
+
+		n, err := ParseNumber("12345678.91")
+		if assert.NoError(t, err) {
+			converted, err := n.Convert(decimalType)
+			assert.NoError(t, err)
+			assert.Equal(t, "12345678.91", converted.String())
+		}
+
+		for _, value := range []string{"0.001", "123456789.1"} {
+			n, err := ParseNumber(value)
+			if assert.NoError(t, err, value) {
+				_, err = n.Convert(decimalType)
+				assert.Error(t, err, value)
+			}
+		}
+	})
+
+	t.Run("Float32", func(t *testing.T) {
+		n, err := ParseNumber("1e39")
+		if assert.NoError(t, err) {
+			_, err = n.Convert(arrow.PrimitiveTypes.Float32)
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestCastMeasurements(t *testing.T) {
+	pool := memory.NewGoAllocator()
+
+	int32Builder := array.NewInt32Builder(pool)
+	defer int32Builder.Release()
+	int32Builder.AppendValues([]int32{1, 0, -3}, []bool{true, false, true})
+	int32Column := int32Builder.NewArray()
+	defer int32Column.Release()
+
+	decimalType := &arrow.Decimal128Type{Precision: 10, Scale: 2}
+	decimalBuilder := array.NewDecimal128Builder(pool, decimalType)
+	defer decimalBuilder.Release()
+	decimalBuilder.AppendValues([]decimal128.Num{decimal128.FromI64(1050), decimal128.FromI64(-25)}, nil)
+	decimalColumn := decimalBuilder.NewArray()
+	defer decimalColumn.Release()
+
+	boolBuilder := array.NewBooleanBuilder(pool)
+	defer boolBuilder.Release()
+	boolBuilder.AppendValues([]bool{true, false}, nil)
+	boolColumn := boolBuilder.NewArray()
+	defer boolColumn.Release()
+
+	t.Run("To float64", func(t *testing.T) {
+		for column, expected := range map[arrow.Array]string{
+			int32Column:   "[1 (null) -3]",
+			decimalColumn: "[10.5 -0.25]",
+			boolColumn:    "[1 0]",
+		} {
+			cast, err := CastMeasurements(column, arrow.PrimitiveTypes.Float64, pool)
+			if assert.NoError(t, err) {
+				assert.Equal(t, arrow.PrimitiveTypes.Float64, cast.DataType())
+				assert.Equal(t, expected, cast.String())
+				cast.Release()
+			}
+		}
+	})
+
+	t.Run("Same type", func(t *testing.T) {
+		cast, err := CastMeasurements(int32Column, arrow.PrimitiveTypes.Int32, pool)
+		if assert.NoError(t, err) {
+			assert.Same(t, int32Column, cast)
+			cast.Release()
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		_, err := CastMeasurements(int32Column, arrow.PrimitiveTypes.Uint8, pool)
+		assert.EqualError(t, err, "value at row 2: -3 overflows uint8")
+
+		_, err = CastMeasurements(decimalColumn, arrow.PrimitiveTypes.Int64, pool)
+		assert.EqualError(t, err, "value at row 0: 10.50 is not an integer")
+	})
+}
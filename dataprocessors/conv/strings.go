@@ -0,0 +1,82 @@
+package conv
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+)
+
+// IsStringInputType returns whether identifier, category or tag columns of the type can be converted to strings
+func IsStringInputType(dataType arrow.DataType) bool {
+	switch dataType.ID() {
+	case arrow.STRING, arrow.LARGE_STRING,
+		arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64,
+		arrow.FLOAT32, arrow.FLOAT64, arrow.DECIMAL128:
+		return true
+	case arrow.DICTIONARY:
+		return IsStringInputType(dataType.(*arrow.DictionaryType).ValueType)
+	}
+	return false
+}
+
+// CastStrings returns the identifier, category or tag column as strings. String columns and dictionaries of
+// strings are returned as is, retained, to keep their encoding. Large strings and numbers are converted to String,
+// and dictionaries of them are decoded. The returned array must be released.
+func CastStrings(column arrow.Array, pool memory.Allocator) (arrow.Array, error) {
+	if !IsStringInputType(column.DataType()) {
+		return nil, fmt.Errorf("%s is not a string or numeric type", column.DataType())
+	}
+
+	switch c := column.(type) {
+	case *array.String:
+		c.Retain()
+		return c, nil
+	case *array.Dictionary:
+		if c.Dictionary().DataType().ID() == arrow.STRING {
+			c.Retain()
+			return c, nil
+		}
+	}
+
+	builder := array.NewStringBuilder(pool)
+	defer builder.Release()
+	builder.Reserve(column.Len())
+	for i := 0; i < column.Len(); i++ {
+		value, ok, err := stringAt(column, i)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			builder.Append(value)
+		} else {
+			builder.AppendNull()
+		}
+	}
+
+	return builder.NewArray(), nil
+}
+
+// Returns the value at index i of the column as a string, and false if it is null
+func stringAt(column arrow.Array, i int) (string, bool, error) {
+	if column.IsNull(i) {
+		return "", false, nil
+	}
+
+	switch c := column.(type) {
+	case *array.String:
+		return c.Value(i), true, nil
+	case *array.LargeString:
+		return c.Value(i), true, nil
+	case *array.Dictionary:
+		return stringAt(c.Dictionary(), c.GetValueIndex(i))
+	}
+
+	n, err := NumberAt(column, i)
+	if err != nil {
+		return "", false, err
+	}
+	return n.String(), true, nil
+}
@@ -0,0 +1,72 @@
+package conv
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCastStrings(t *testing.T) {
+	pool := memory.NewGoAllocator()
+
+	t.Run("Dictionary of strings", func(t *testing.T) {
+		dictionaryType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int8, ValueType: arrow.BinaryTypes.String}
+		builder := array.NewDictionaryBuilder(pool, dictionaryType).(*array.BinaryDictionaryBuilder)
+		defer builder.Release()
+		assert.NoError(t, builder.AppendString("a"))
+		builder.AppendNull()
+		column := builder.NewArray()
+		defer column.Release()
+
+		cast, err := CastStrings(column, pool)
+		if assert.NoError(t, err) {
+			assert.Same(t, column, cast)
+			cast.Release()
+		}
+	})
+
+	t.Run("Dictionary of integers", func(t *testing.T) {
+		dictionaryType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int16, ValueType: arrow.PrimitiveTypes.Int64}
+		builder := array.NewDictionaryBuilder(pool, dictionaryType).(*array.Int64DictionaryBuilder)
+		defer builder.Release()
+		assert.NoError(t, builder.Append(42))
+		builder.AppendNull()
+		assert.NoError(t, builder.Append(-1))
+		column := builder.NewArray()
+		defer column.Release()
+
+		cast, err := CastStrings(column, pool)
+		if assert.NoError(t, err) {
+			assert.Equal(t, arrow.BinaryTypes.String, cast.DataType())
+			assert.Equal(t, `["42" (null) "-1"]`, cast.String())
+			cast.Release()
+		}
+	})
+
+	t.Run("Large strings", func(t *testing.T) {
+		builder := array.NewLargeStringBuilder(pool)
+		defer builder.Release()
+		builder.AppendValues([]string{"a", "b"}, nil)
+		column := builder.NewArray()
+		defer column.Release()
+
+		cast, err := CastStrings(column, pool)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `["a" "b"]`, cast.String())
+			cast.Release()
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		builder := array.NewBooleanBuilder(pool)
+		defer builder.Release()
+		column := builder.NewArray()
+		defer column.Release()
+
+		_, err := CastStrings(column, pool)
+		assert.EqualError(t, err, "bool is not a string or numeric type")
+	})
+}
@@ -0,0 +1,117 @@
+package conv
+
+import (
+	"strings"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+)
+
+// Names and prefixes of the columns of the output records
+const (
+	TimeColumnName    = "time"
+	IdentifierPrefix  = "id."
+	MeasurementPrefix = "measure."
+	CategoryPrefix    = "cat."
+	TagsColumnName    = "tags"
+)
+
+// TagsType is the type of the tags column of the output records
+var TagsType = arrow.ListOf(arrow.BinaryTypes.String)
+
+// TagsBuilder builds the tags column of the output records, with the list of the tags of each row
+type TagsBuilder struct {
+	list   *array.ListBuilder
+	values *array.StringBuilder
+}
+
+func NewTagsBuilder(pool memory.Allocator) *TagsBuilder {
+	list := array.NewListBuilder(pool, arrow.BinaryTypes.String)
+	return &TagsBuilder{list: list, values: list.ValueBuilder().(*array.StringBuilder)}
+}
+
+// Field returns the field of the tags column
+func (b *TagsBuilder) Field() arrow.Field {
+	return arrow.Field{Name: TagsColumnName, Type: TagsType}
+}
+
+// NewRow starts the tags of the next row, which is empty until tags are appended
+func (b *TagsBuilder) NewRow() {
+	b.list.Append(true)
+}
+
+// Append appends a tag to the current row
+func (b *TagsBuilder) Append(tag string) {
+	b.values.Append(tag)
+}
+
+// AppendFields appends the space-delimited tags of the value to the current row
+func (b *TagsBuilder) AppendFields(value string) {
+	for _, tag := range strings.Fields(value) {
+		b.values.Append(tag)
+	}
+}
+
+// AppendValue appends the tags at index i of a tag column to the current row. Strings hold space-delimited
+// tags, and lists hold a tag per value. Null values have no tags.
+func (b *TagsBuilder) AppendValue(column arrow.Array, i int) error {
+	if column.IsNull(i) {
+		return nil
+	}
+
+	switch c := column.(type) {
+	case *array.List:
+		offsets := c.Offsets()
+		return b.appendValues(c.ListValues(), int(offsets[i]), int(offsets[i+1]))
+	case *array.LargeList:
+		offsets := c.Offsets()
+		return b.appendValues(c.ListValues(), int(offsets[i]), int(offsets[i+1]))
+	}
+
+	value, ok, err := stringAt(column, i)
+	if err != nil {
+		return err
+	}
+	if ok {
+		b.AppendFields(value)
+	}
+	return nil
+}
+
+func (b *TagsBuilder) appendValues(values arrow.Array, start int, end int) error {
+	for j := start; j < end; j++ {
+		value, ok, err := stringAt(values, j)
+		if err != nil {
+			return err
+		}
+		if ok {
+			b.values.Append(value)
+		}
+	}
+	return nil
+}
+
+func (b *TagsBuilder) Len() int {
+	return b.list.Len()
+}
+
+// NewArray returns the tags column of the appended rows and resets the builder
+func (b *TagsBuilder) NewArray() arrow.Array {
+	return b.list.NewArray()
+}
+
+func (b *TagsBuilder) Release() {
+	b.list.Release()
+}
+
+// IsTagType returns whether tag columns of the type can be appended by AppendValue
+func IsTagType(dataType arrow.DataType) bool {
+	switch t := dataType.(type) {
+	case *arrow.ListType:
+		return IsStringInputType(t.Elem())
+	case *arrow.LargeListType:
+		return IsStringInputType(t.Elem())
+	}
+	return IsStringInputType(dataType)
+}
@@ -0,0 +1,71 @@
+package conv
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagsBuilder(t *testing.T) {
+	pool := memory.NewGoAllocator()
+
+	stringBuilder := array.NewStringBuilder(pool)
+	defer stringBuilder.Release()
+	stringBuilder.AppendValues([]string{"a b", "", "c"}, []bool{true, true, false})
+	stringColumn := stringBuilder.NewArray()
+	defer stringColumn.Release()
+
+	listBuilder := array.NewListBuilder(pool, arrow.BinaryTypes.String)
+	defer listBuilder.Release()
+	listValuesBuilder := listBuilder.ValueBuilder().(*array.StringBuilder)
+	listBuilder.Append(true)
+	listValuesBuilder.AppendValues([]string{"l1", "l 2"}, nil)
+	listBuilder.AppendNull()
+	listBuilder.Append(true)
+	listValuesBuilder.AppendNull()
+	listColumn := listBuilder.NewArray()
+	defer listColumn.Release()
+
+	int32Builder := array.NewInt32Builder(pool)
+	defer int32Builder.Release()
+	int32Builder.AppendValues([]int32{1, 2, 3}, nil)
+	int32Column := int32Builder.NewArray()
+	defer int32Column.Release()
+
+	builder := NewTagsBuilder(pool)
+	defer builder.Release()
+	for i := 0; i < 3; i++ {
+		builder.NewRow()
+		for _, column := range []arrow.Array{stringColumn, listColumn, int32Column} {
+			assert.NoError(t, builder.AppendValue(column, i))
+		}
+	}
+	builder.NewRow()
+	builder.AppendFields(" x  y ")
+	builder.Append("z z")
+
+	assert.Equal(t, 4, builder.Len())
+	tags := builder.NewArray()
+	defer tags.Release()
+	assert.True(t, arrow.TypeEqual(TagsType, tags.DataType()))
+	// Strings are split, while list values are tags as is
+	assert.Equal(t, `[["a" "b" "l1" "l 2" "1"] ["2"] ["3"] ["x" "y" "z z"]]`, tags.String())
+	assert.Equal(t, 0, builder.Len())
+}
+
+func TestIsTagType(t *testing.T) {
+	for dataType, expected := range map[arrow.DataType]bool{
+		arrow.BinaryTypes.String:                      true,
+		arrow.BinaryTypes.LargeString:                 true,
+		arrow.PrimitiveTypes.Uint16:                   true,
+		arrow.ListOf(arrow.BinaryTypes.String):        true,
+		arrow.LargeListOf(arrow.PrimitiveTypes.Int64): true,
+		arrow.FixedWidthTypes.Boolean:                 false,
+		arrow.ListOf(arrow.FixedWidthTypes.Date32):    false,
+	} {
+		assert.Equal(t, expected, IsTagType(dataType), dataType.String())
+	}
+}
@@ -0,0 +1,192 @@
+package conv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	spice_time "github.com/spiceai/spiceai/pkg/time"
+)
+
+const rfc3339NoOffset = "2006-01-02T15:04:05.999999999"
+
+var namedTimeFormats = map[string]bool{
+	"":         true,
+	"hex":      true,
+	"rfc3339":  true,
+	"iso8601":  true,
+	"rfc1123z": true,
+	"rfc822z":  true,
+}
+
+var timeUnits = map[string]arrow.TimeUnit{
+	"s":  arrow.Second,
+	"ms": arrow.Millisecond,
+	"us": arrow.Microsecond,
+	"ns": arrow.Nanosecond,
+}
+
+// TimePrecision is the precision of the output time column set by the time_precision param. Without it, times are
+// Int64 Unix seconds. With it, times are UTC timestamps of its unit, and numeric epoch input times are in its unit.
+type TimePrecision struct {
+	Unit      arrow.TimeUnit
+	Timestamp bool
+}
+
+func NewTimePrecision(params map[string]string) (*TimePrecision, error) {
+	precision, ok := params["time_precision"]
+	if !ok || precision == "" {
+		return &TimePrecision{Unit: arrow.Second}, nil
+	}
+
+	unit, ok := timeUnits[precision]
+	if !ok {
+		return nil, fmt.Errorf("invalid time_precision '%s': must be s, ms, us or ns", precision)
+	}
+
+	return &TimePrecision{Unit: unit, Timestamp: true}, nil
+}
+
+// DataType returns the type of the output time column
+func (p *TimePrecision) DataType() arrow.DataType {
+	if !p.Timestamp {
+		return arrow.PrimitiveTypes.Int64
+	}
+	return &arrow.TimestampType{Unit: p.Unit, TimeZone: "UTC"}
+}
+
+func (p *TimePrecision) NewBuilder(pool memory.Allocator) array.Builder {
+	return array.NewBuilder(pool, p.DataType())
+}
+
+// Append appends the time to a builder created by NewBuilder
+func (p *TimePrecision) Append(builder array.Builder, t time.Time) {
+	switch b := builder.(type) {
+	case *array.Int64Builder:
+		b.Append(t.Unix())
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(p.FromTime(t)))
+	}
+}
+
+// AppendEpoch appends a numeric epoch time in the unit of the precision
+func (p *TimePrecision) AppendEpoch(builder array.Builder, epoch int64) {
+	switch b := builder.(type) {
+	case *array.Int64Builder:
+		b.Append(epoch)
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(epoch))
+	}
+}
+
+// FromTime returns the time as an epoch in the unit of the precision
+func (p *TimePrecision) FromTime(t time.Time) int64 {
+	switch p.Unit {
+	case arrow.Millisecond:
+		return t.UnixMilli()
+	case arrow.Microsecond:
+		return t.UnixMicro()
+	case arrow.Nanosecond:
+		return t.UnixNano()
+	}
+	return t.Unix()
+}
+
+// ToTime returns the time of an epoch in the unit of the precision
+func (p *TimePrecision) ToTime(epoch int64) time.Time {
+	return arrow.Timestamp(epoch).ToTime(p.Unit)
+}
+
+// AppendColumn appends the times of a time column. Integer epoch times are in the unit of the precision,
+// timestamps of any unit and zone and dates are converted to it, and strings are parsed with the format and
+// location like ParseTime. Null times are appended as nulls, so callers reject or skip them first.
+func (p *TimePrecision) AppendColumn(builder array.Builder, column arrow.Array, format string, location *time.Location) error {
+	if !IsTimeType(column.DataType()) {
+		return fmt.Errorf("%s is not supported", column.DataType())
+	}
+
+	for i := 0; i < column.Len(); i++ {
+		if column.IsNull(i) {
+			builder.AppendNull()
+			continue
+		}
+
+		switch c := column.(type) {
+		case *array.Int64:
+			p.AppendEpoch(builder, c.Value(i))
+		case *array.Int32:
+			p.AppendEpoch(builder, int64(c.Value(i)))
+		case *array.Timestamp:
+			p.Append(builder, c.Value(i).ToTime(c.DataType().(*arrow.TimestampType).Unit))
+		case *array.Date32:
+			p.Append(builder, c.Value(i).ToTime())
+		case *array.Date64:
+			p.Append(builder, c.Value(i).ToTime())
+		case *array.String, *array.LargeString:
+			value := c.(interface{ Value(int) string }).Value(i)
+			t, err := p.ParseTime(value, format, location)
+			if err != nil {
+				return err
+			}
+			p.Append(builder, t)
+		}
+	}
+
+	return nil
+}
+
+// IsTimeType returns whether time columns of the type can be appended by AppendColumn
+func IsTimeType(dataType arrow.DataType) bool {
+	switch dataType.ID() {
+	case arrow.INT64, arrow.INT32, arrow.TIMESTAMP, arrow.DATE32, arrow.DATE64, arrow.STRING, arrow.LARGE_STRING:
+		return true
+	}
+	return false
+}
+
+// ParseTime parses a time like spice_time.ParseTime, except numeric epochs are in the unit of the precision, and
+// times of custom layouts without a UTC offset or zone are in the location, when set.
+func (p *TimePrecision) ParseTime(str string, format string, location *time.Location) (time.Time, error) {
+	if format == "" && !strings.HasPrefix(str, "0x") {
+		if epoch, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return p.ToTime(epoch).UTC(), nil
+		}
+	}
+
+	// Named formats always include an offset
+	if location != nil && !namedTimeFormats[strings.ToLower(format)] {
+		t, err := time.ParseInLocation(format, str, location)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("incorrectly formatted time '%s' expecting format '%s': %s", str, format, err.Error())
+		}
+		return t.UTC(), nil
+	}
+
+	t, err := spice_time.ParseTime(str, format)
+	if err != nil && format == "" && location != nil {
+		// RFC3339 times without an offset
+		if t, zoneErr := time.ParseInLocation(rfc3339NoOffset, str, location); zoneErr == nil {
+			return t.UTC(), nil
+		}
+	}
+	return t, err
+}
+
+// NewTimeZone returns the location of the IANA time zone of the time_zone param, or nil when it isn't set
+func NewTimeZone(params map[string]string) (*time.Location, error) {
+	zone, ok := params["time_zone"]
+	if !ok || zone == "" {
+		return nil, nil
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time_zone '%s': %w", zone, err)
+	}
+
+	return location, nil
+}
@@ -0,0 +1,187 @@
+package conv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimePrecision(t *testing.T) {
+	t.Run("NewTimePrecision()", func(t *testing.T) {
+		p, err := NewTimePrecision(map[string]string{})
+		if assert.NoError(t, err) {
+			assert.False(t, p.Timestamp)
+			assert.Equal(t, arrow.PrimitiveTypes.Int64, p.DataType())
+		}
+
+		for precision, unit := range timeUnits {
+			p, err := NewTimePrecision(map[string]string{"time_precision": precision})
+			if assert.NoError(t, err) {
+				assert.True(t, p.Timestamp)
+				assert.Equal(t, &arrow.TimestampType{Unit: unit, TimeZone: "UTC"}, p.DataType())
+			}
+		}
+
+		_, err = NewTimePrecision(map[string]string{"time_precision": "m"})
+		assert.Error(t, err)
+	})
+
+	t.Run("FromTime()", func(t *testing.T) {
+		ts := time.Date(2020, 11, 14, 0, 0, 0, 123456789, time.UTC)
+		expected := map[string]int64{
+			"":   1605312000,
+			"s":  1605312000,
+			"ms": 1605312000123,
+			"us": 1605312000123456,
+			"ns": 1605312000123456789,
+		}
+		for precision, epoch := range expected {
+			p, err := NewTimePrecision(map[string]string{"time_precision": precision})
+			if assert.NoError(t, err) {
+				assert.Equal(t, epoch, p.FromTime(ts), precision)
+				assert.Equal(t, epoch, p.FromTime(p.ToTime(epoch)), precision)
+			}
+		}
+	})
+
+	t.Run("ParseTime()", func(t *testing.T) {
+		p, err := NewTimePrecision(map[string]string{"time_precision": "ms"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		expected := time.Date(2020, 11, 14, 0, 0, 0, 123000000, time.UTC)
+		for _, value := range []string{"1605312000123", "2020-11-14T00:00:00.123Z"} {
+			actual, err := p.ParseTime(value, "", nil)
+			if assert.NoError(t, err, value) {
+				assert.True(t, expected.Equal(actual), "%s: %s", value, actual)
+			}
+		}
+
+		actual, err := p.ParseTime("2020-11-14 00:00:00.123", "2006-01-02 15:04:05.000", nil)
+		if assert.NoError(t, err) {
+			assert.True(t, expected.Equal(actual), actual)
+		}
+
+		// Hex times are always in seconds
+		actual, err = p.ParseTime("0x5faf1e00", "", nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(1605312000), actual.Unix())
+		}
+	})
+
+	t.Run("Append()", func(t *testing.T) {
+		ts := time.Date(2020, 11, 14, 0, 0, 0, 123456789, time.UTC)
+		pool := memory.NewGoAllocator()
+
+		p := &TimePrecision{Unit: arrow.Second}
+		builder := p.NewBuilder(pool)
+		p.Append(builder, ts)
+		p.AppendEpoch(builder, 1605313800)
+		arr := builder.NewArray()
+		assert.Equal(t, []int64{1605312000, 1605313800}, arr.(*array.Int64).Int64Values())
+		arr.Release()
+		builder.Release()
+
+		p = &TimePrecision{Unit: arrow.Microsecond, Timestamp: true}
+		builder = p.NewBuilder(pool)
+		p.Append(builder, ts)
+		p.AppendEpoch(builder, 1605313800000001)
+		arr = builder.NewArray()
+		assert.Equal(t, []arrow.Timestamp{1605312000123456, 1605313800000001}, arr.(*array.Timestamp).TimestampValues())
+		arr.Release()
+		builder.Release()
+	})
+}
+
+func TestTimeZone(t *testing.T) {
+	location, err := NewTimeZone(map[string]string{})
+	assert.NoError(t, err)
+	assert.Nil(t, location)
+
+	_, err = NewTimeZone(map[string]string{"time_zone": "America/Nowhere"})
+	assert.Error(t, err)
+
+	location, err = NewTimeZone(map[string]string{"time_zone": "America/New_York"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	p := &TimePrecision{Unit: arrow.Second}
+	layout := "2006-01-02 15:04:05"
+
+	// Times around the DST transitions of 2021, from EST (-05:00) to EDT (-04:00) and back
+	expected := map[string]string{
+		"2021-01-15 09:30:00": "2021-01-15T14:30:00Z",
+		"2021-03-14 01:59:59": "2021-03-14T06:59:59Z",
+		"2021-03-14 03:00:00": "2021-03-14T07:00:00Z",
+		"2021-07-15 09:30:00": "2021-07-15T13:30:00Z",
+		"2021-11-07 00:59:59": "2021-11-07T04:59:59Z",
+		"2021-11-07 02:00:00": "2021-11-07T07:00:00Z",
+	}
+	for value, utc := range expected {
+		actual, err := p.ParseTime(value, layout, location)
+		if assert.NoError(t, err, value) {
+			assert.Equal(t, utc, actual.Format(time.RFC3339), value)
+		}
+	}
+
+	// Explicit offsets are respected
+	actual, err := p.ParseTime("2021-07-15 09:30:00+02:00", "2006-01-02 15:04:05-07:00", location)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2021-07-15T07:30:00Z", actual.Format(time.RFC3339))
+	}
+	actual, err = p.ParseTime("2021-07-15T09:30:00Z", "", location)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2021-07-15T09:30:00Z", actual.Format(time.RFC3339))
+	}
+
+	// RFC3339 times without an offset are in the location
+	actual, err = p.ParseTime("2021-07-15T09:30:00", "", location)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2021-07-15T13:30:00Z", actual.Format(time.RFC3339))
+	}
+	_, err = p.ParseTime("2021-07-15T09:30:00", "", nil)
+	assert.Error(t, err)
+
+	// Epoch times aren't affected
+	actual, err = p.ParseTime("1626355800", "", location)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1626355800), actual.Unix())
+	}
+}
+
+func TestAppendColumn(t *testing.T) {
+	pool := memory.NewGoAllocator()
+	precision := &TimePrecision{Unit: arrow.Millisecond, Timestamp: true}
+
+	dateBuilder := array.NewDate32Builder(pool)
+	defer dateBuilder.Release()
+	dateBuilder.AppendValues([]arrow.Date32{18580, 0}, []bool{true, false})
+	dates := dateBuilder.NewArray()
+	defer dates.Release()
+
+	builder := precision.NewBuilder(pool)
+	defer builder.Release()
+	assert.NoError(t, precision.AppendColumn(builder, dates, "", nil))
+	times := builder.NewArray()
+	defer times.Release()
+	assert.Equal(t, "[1605312000000 (null)]", times.String())
+
+	stringBuilder := array.NewLargeStringBuilder(pool)
+	defer stringBuilder.Release()
+	stringBuilder.AppendValues([]string{"2020-11-14T00:00:00.5Z", "not a time"}, nil)
+	strings := stringBuilder.NewArray()
+	defer strings.Release()
+	assert.Error(t, precision.AppendColumn(builder, strings, "", nil))
+
+	floatBuilder := array.NewFloat64Builder(pool)
+	defer floatBuilder.Release()
+	floats := floatBuilder.NewArray()
+	defer floats.Release()
+	assert.EqualError(t, precision.AppendColumn(builder, floats, "", nil), "float64 is not supported")
+}
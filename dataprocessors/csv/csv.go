@@ -5,28 +5,83 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
-	"log"
+	"strconv"
 	"strings"
-
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/apache/arrow/go/v10/arrow"
 	"github.com/apache/arrow/go/v10/arrow/array"
-	arrow_csv "github.com/apache/arrow/go/v10/arrow/csv"
 	"github.com/apache/arrow/go/v10/arrow/memory"
 
-	spice_time "github.com/spiceai/spiceai/pkg/time"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
 	"github.com/spiceai/spiceai/pkg/util"
 )
 
 const (
 	CsvProcessorName string = "csv"
-	tagsColumnName   string = "_tags"
+
+	onErrorSkip string = "skip"
+	onErrorNull string = "null"
+	onErrorFail string = "fail"
+
+	maxReportedLines = 10
 )
 
+// An output column and the input column it is read from
+type csvColumn struct {
+	index       int
+	field       arrow.Field
+	measurement bool
+	builder     array.Builder
+}
+
+// InvalidValue is a time or measurement value that failed to parse
+type InvalidValue struct {
+	Line   int
+	Column string
+	Value  string
+	Err    error
+}
+
+// InvalidRowsError reports the rows with invalid time or measurement values
+type InvalidRowsError struct {
+	// Lines of the rows with invalid values, starting at 1 for the first line of the data
+	Lines  []int
+	Values []InvalidValue
+}
+
+func (e *InvalidRowsError) Error() string {
+	var lines []string
+	for i, line := range e.Lines {
+		if i == maxReportedLines {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, strconv.Itoa(line))
+	}
+	first := e.Values[0]
+	return fmt.Sprintf("%d invalid rows at lines %s: invalid value '%s' of column '%s' at line %d: %s",
+		len(e.Lines), strings.Join(lines, ", "), first.Value, first.Column, first.Line, first.Err.Error())
+}
+
 type CsvProcessor struct {
-	timeFormat   string
-	timeSelector string
+	timeFormat    string
+	timeSelector  string
+	timePrecision *conv.TimePrecision
+	timeZone      *time.Location
+	onError       string
+
+	measurementType *conv.MeasurementType
+
+	delimiter        rune
+	comment          rune
+	lazyQuotes       bool
+	header           bool
+	columnNames      []string
+	skipLines        int
+	decimalSeparator string
 
 	identifiers  map[string]string
 	measurements map[string]string
@@ -37,7 +92,11 @@ type CsvProcessor struct {
 	data      []byte
 	dataHash  []byte
 
+	chunkSize int
+	allocator memory.Allocator
+
 	currentRecord arrow.Record
+	invalidRows   *InvalidRowsError
 }
 
 func NewCsvProcessor() *CsvProcessor {
@@ -53,6 +112,40 @@ func (p *CsvProcessor) Init(params map[string]string, identifiers map[string]str
 	} else {
 		p.timeSelector = "time"
 	}
+	timePrecision, err := conv.NewTimePrecision(params)
+	if err != nil {
+		return err
+	}
+	p.timePrecision = timePrecision
+	timeZone, err := conv.NewTimeZone(params)
+	if err != nil {
+		return err
+	}
+	p.timeZone = timeZone
+	measurementType, err := conv.NewMeasurementType(params)
+	if err != nil {
+		return err
+	}
+	p.measurementType = measurementType
+
+	p.onError = onErrorNull
+	if onError, ok := params["on_error"]; ok && onError != "" {
+		if onError != onErrorSkip && onError != onErrorNull && onError != onErrorFail {
+			return fmt.Errorf("invalid on_error '%s': must be skip, null or fail", onError)
+		}
+		p.onError = onError
+	}
+
+	if err := p.initFormat(params); err != nil {
+		return err
+	}
+
+	p.chunkSize = 0
+	if chunkSize, ok := params["chunk_size"]; ok && chunkSize != "" {
+		if p.chunkSize, err = strconv.Atoi(chunkSize); err != nil || p.chunkSize < 1 {
+			return fmt.Errorf("invalid chunk_size '%s': must be an integer >= 1", chunkSize)
+		}
+	}
 
 	p.identifiers = identifiers
 	p.measurements = measurements
@@ -62,6 +155,87 @@ func (p *CsvProcessor) Init(params map[string]string, identifiers map[string]str
 	return nil
 }
 
+// Initializes the CSV format params
+func (p *CsvProcessor) initFormat(params map[string]string) error {
+	var err error
+
+	p.delimiter = ','
+	if delimiter, ok := params["delimiter"]; ok && delimiter != "" {
+		if p.delimiter, err = parseRune("delimiter", delimiter); err != nil {
+			return err
+		}
+	}
+
+	p.comment = 0
+	if comment, ok := params["comment"]; ok && comment != "" {
+		if p.comment, err = parseRune("comment", comment); err != nil {
+			return err
+		}
+		if p.comment == p.delimiter {
+			return fmt.Errorf("invalid comment '%s': must be different from the delimiter", comment)
+		}
+	}
+
+	p.lazyQuotes = false
+	if lazyQuotes, ok := params["lazy_quotes"]; ok && lazyQuotes != "" {
+		if p.lazyQuotes, err = strconv.ParseBool(lazyQuotes); err != nil {
+			return fmt.Errorf("invalid lazy_quotes '%s': must be true or false", lazyQuotes)
+		}
+	}
+
+	p.header = true
+	if header, ok := params["header"]; ok && header != "" {
+		if p.header, err = strconv.ParseBool(header); err != nil {
+			return fmt.Errorf("invalid header '%s': must be true or false", header)
+		}
+	}
+	p.columnNames = nil
+	if columns, ok := params["columns"]; ok && columns != "" {
+		if p.header {
+			return errors.New("the columns param requires 'header: false'")
+		}
+		for _, column := range strings.Split(columns, ",") {
+			p.columnNames = append(p.columnNames, strings.TrimSpace(column))
+		}
+	} else if !p.header {
+		return errors.New("'header: false' requires the columns param")
+	}
+
+	p.skipLines = 0
+	if skipLines, ok := params["skip_lines"]; ok && skipLines != "" {
+		if p.skipLines, err = strconv.Atoi(skipLines); err != nil || p.skipLines < 0 {
+			return fmt.Errorf("invalid skip_lines '%s': must be an integer >= 0", skipLines)
+		}
+	}
+
+	p.decimalSeparator = "."
+	if separator, ok := params["decimal_separator"]; ok && separator != "" {
+		if separator != "." && separator != "," {
+			return fmt.Errorf("invalid decimal_separator '%s': must be . or ,", separator)
+		}
+		if separator == string(p.delimiter) {
+			return fmt.Errorf("invalid decimal_separator '%s': must be different from the delimiter", separator)
+		}
+		p.decimalSeparator = separator
+	}
+
+	return nil
+}
+
+// Parses a single character param. Tabs can also be set as "\t" or "tab".
+func parseRune(name string, value string) (rune, error) {
+	if value == "\\t" || strings.EqualFold(value, "tab") {
+		return '\t', nil
+	}
+
+	runes := []rune(value)
+	if len(runes) != 1 || runes[0] == '\r' || runes[0] == '\n' || runes[0] == '"' || runes[0] == utf8.RuneError {
+		return 0, fmt.Errorf("invalid %s '%s': must be a single character", name, value)
+	}
+
+	return runes[0], nil
+}
+
 func (p *CsvProcessor) OnData(data []byte) ([]byte, error) {
 	p.dataMutex.Lock()
 	defer p.dataMutex.Unlock()
@@ -80,205 +254,109 @@ func (p *CsvProcessor) OnData(data []byte) ([]byte, error) {
 	return data, nil
 }
 
+// GetRecord returns a record of all the rows of the new data, regardless of chunk_size. Use GetRecords to read
+// the data in records of up to chunk_size rows.
 func (p *CsvProcessor) GetRecord() (arrow.Record, error) {
-	if p.data == nil {
-		return nil, nil
-	}
-
 	p.dataMutex.Lock()
 	defer p.dataMutex.Unlock()
 
-	if p.data == nil {
+	if len(p.data) == 0 {
 		return nil, nil
 	}
 
-	if len(p.data) == 0 {
-		return nil, nil
+	if !p.hasMappings() {
+		p.data = nil
+		return p.currentRecord, nil
 	}
 
-	err := p.createRecord(p.data)
+	reader, err := p.newRecordReader(p.data, 0)
 	if err != nil {
 		return nil, err
 	}
-
 	p.data = nil
+	defer reader.Release()
+
+	record, err := reader.readChunk()
+	if err != nil {
+		return nil, err
+	}
+	p.setCurrentRecord(record, reader.InvalidRows())
 	return p.currentRecord, nil
 }
 
-func (p *CsvProcessor) createRecord(data []byte) error {
-	numTags := len(p.tags)
-
-	if len(p.identifiers)+len(p.measurements)+len(p.categories)+numTags == 0 {
-		return nil
-	}
+// GetRecords returns a reader of the records of the new data, of up to chunk_size rows each, or of all the
+// rows in a single record without it. It returns nil without new data. The reader must be released.
+func (p *CsvProcessor) GetRecords() (*RecordReader, error) {
+	p.dataMutex.Lock()
+	defer p.dataMutex.Unlock()
 
-	identifiersMap := make(map[string]string)
-	measurementsMap := make(map[string]string)
-	categoriesMap := make(map[string]string)
-	tagsMap := make(map[string]bool)
-	for key, colName := range p.identifiers {
-		identifiersMap[colName] = key
-	}
-	for key, colName := range p.measurements {
-		measurementsMap[colName] = key
-	}
-	for key, colName := range p.categories {
-		categoriesMap[colName] = key
-	}
-	for _, key := range p.tags {
-		tagsMap[key] = true
+	if len(p.data) == 0 || !p.hasMappings() {
+		return nil, nil
 	}
 
-	headers, err := getCsvHeader(data)
+	reader, err := p.newRecordReader(p.data, p.chunkSize)
 	if err != nil {
-		return fmt.Errorf("failed to get csv header: %s", err)
-	}
-
-	fields := make([]arrow.Field, len(headers))
-	var colToInclude []int
-	includeAllColumns := true
-	var tagColumns []int
-	timeCol := -1
-	for i, header := range headers {
-		// Allocate time column index upon finding timeSelector if not yet assigned
-		if timeCol < 0 && header == p.timeSelector {
-			timeCol = i
-			fields[i].Name = header
-			if p.timeFormat != "" {
-				fields[i].Type = arrow.BinaryTypes.String
-			} else {
-				fields[i].Type = arrow.PrimitiveTypes.Int64
-			}
-			colToInclude = append(colToInclude, i)
-		} else {
-			if key, ok := identifiersMap[header]; ok {
-				fields[i].Name = fmt.Sprintf("id.%s", key)
-				fields[i].Type = arrow.BinaryTypes.String
-				colToInclude = append(colToInclude, i)
-			} else if key, ok := measurementsMap[header]; ok {
-				fields[i].Name = fmt.Sprintf("measure.%s", key)
-				fields[i].Type = arrow.PrimitiveTypes.Float64
-				colToInclude = append(colToInclude, i)
-			} else if key, ok := categoriesMap[header]; ok {
-				fields[i].Name = fmt.Sprintf("cat.%s", key)
-				fields[i].Type = arrow.BinaryTypes.String
-				colToInclude = append(colToInclude, i)
-			} else if tagsMap[header] {
-				tagColumns = append(tagColumns, i)
-				fields[i].Name = fmt.Sprintf("tag.%s", header)
-				fields[i].Type = arrow.BinaryTypes.String
-				colToInclude = append(colToInclude, i)
-			} else {
-				fields[i].Name = fmt.Sprintf("ignore.%s", header)
-				fields[i].Type = arrow.BinaryTypes.String
-				includeAllColumns = false
-			}
-		}
-	}
-
-	if timeCol < 0 {
-		return fmt.Errorf("time header '%s' not found", p.timeSelector)
+		return nil, err
 	}
+	p.data = nil
 
-	schema := arrow.NewSchema(fields, nil)
-	arrowReader := arrow_csv.NewReader(
-		bytes.NewBuffer(data), schema, arrow_csv.WithHeader(true), arrow_csv.WithChunk(-1),
-		arrow_csv.WithNullReader(false))
-	defer arrowReader.Release()
+	return reader, nil
+}
 
-	arrowReader.Next()
+func (p *CsvProcessor) hasMappings() bool {
+	return len(p.identifiers)+len(p.measurements)+len(p.categories)+len(p.tags) > 0
+}
 
+func (p *CsvProcessor) setCurrentRecord(record arrow.Record, invalidRows *InvalidRowsError) {
 	if p.currentRecord != nil {
 		p.currentRecord.Release()
 	}
-	record := arrowReader.Record()
-	// Re allocating the name that were overwriten by the CSV reader
-	for i, field := range fields {
-		record.Schema().Fields()[i].Name = field.Name
-	}
-	if !includeAllColumns {
-		var newFields []arrow.Field
-		var newColumns []arrow.Array
-		var newTagColumns []int
-		for newIndex, oldIndex := range colToInclude {
-			if fields[oldIndex].Name[:4] == "tag." {
-				newTagColumns = append(newTagColumns, newIndex)
-			}
-			newFields = append(newFields, fields[oldIndex])
-			newColumns = append(newColumns, record.Column(oldIndex))
-		}
-		fields = newFields
-		tagColumns = newTagColumns
-		record = array.NewRecord(arrow.NewSchema(fields, nil), newColumns, record.NumRows())
-	}
+	p.currentRecord = record
+	p.invalidRows = invalidRows
+}
 
-	pool := memory.NewGoAllocator()
-	columns := record.Columns()
-	if p.timeFormat != "" {
-		timeBuilder := array.NewInt64Builder(pool)
-		tagCol := record.Columns()[timeCol].(*array.String)
-		for i := 0; i < int(record.NumRows()); i++ {
-			time, err := spice_time.ParseTime(tagCol.Value(i), p.timeFormat)
-			if err != nil {
-				log.Printf("ignoring invalid line %d (%v): %v", i+1, tagCol.Value(i), err)
-				timeBuilder.Append(0)
-				continue
-			} else {
-				timeBuilder.Append(time.Unix())
-			}
+// Returns a reader of the data after the skipped lines, with the delimiter, comment and quoting params
+func (p *CsvProcessor) newCsvReader(data []byte) (*csv.Reader, error) {
+	for i := 0; i < p.skipLines; i++ {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("failed to skip %d lines: the data has %d lines", p.skipLines, i)
 		}
-		fields = append([]arrow.Field{{Name: "time", Type: arrow.PrimitiveTypes.Int64}}, fields[1:]...)
-		columns = append([]arrow.Array{timeBuilder.NewArray()}, record.Columns()[1:]...)
-		record = array.NewRecord(arrow.NewSchema(fields, nil), columns, record.NumRows())
+		data = data[end+1:]
 	}
 
-	// Aggregating tags
-	tagListBuilder := array.NewListBuilder(pool, arrow.BinaryTypes.String)
-	tagValueBuilder := tagListBuilder.ValueBuilder().(*array.StringBuilder)
-	defer tagListBuilder.Release()
-
-	if len(tagColumns) > 0 {
-		for i := 0; i < int(record.NumRows()); i++ {
-			tagListBuilder.Append(true)
-			for _, colIndex := range tagColumns {
-				tagCol := record.Columns()[colIndex].(*array.String)
-				for _, tag := range strings.Fields(tagCol.Value(i)) {
-					tagValueBuilder.Append(tag)
-				}
-			}
-		}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = p.delimiter
+	reader.Comment = p.comment
+	reader.LazyQuotes = p.lazyQuotes
 
-		// Creating a new record without old tag columns but aggregated one
-		var newFields []arrow.Field
-		var newColumns []arrow.Array
-		for i, field := range fields {
-			if field.Name[:4] != "tag." {
-				newFields = append(newFields, field)
-				newColumns = append(newColumns, record.Column(i))
-			}
-		}
-		fields = newFields
-		columns = newColumns
-	} else {
-		for i := int64(0); i < record.NumRows(); i++ {
-			tagListBuilder.Append(true)
-		}
-	}
-	fields = append(fields, arrow.Field{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)})
-	columns = append(columns, tagListBuilder.NewArray())
-	record = array.NewRecord(arrow.NewSchema(fields, nil), columns, record.NumRows())
-
-	p.currentRecord = record
-	p.currentRecord.Retain()
-	return nil
+	return reader, nil
 }
 
-func getCsvHeader(input []byte) ([]string, error) {
-	reader := csv.NewReader(bytes.NewBuffer(input))
-	headers, err := reader.Read()
+// Removes the ., space and non-breaking space thousands separators of numbers with a , decimal separator, such as
+// 1.234,56 or 1 234,56, and replaces the decimal separator with a .
+var commaDecimalReplacer = strings.NewReplacer(".", "", " ", "", "\u00a0", "", "\u202f", "", ",", ".")
+
+// Parses a measurement converted to the type of its column
+func (p *CsvProcessor) parseMeasurement(value string, dataType arrow.DataType) (*conv.Number, error) {
+	if p.decimalSeparator == "," {
+		value = commaDecimalReplacer.Replace(value)
+	}
+	n, err := conv.ParseNumber(value)
 	if err != nil {
-		return nil, errors.New("failed to read header")
+		return nil, err
+	}
+	if n, err = n.Convert(dataType); err != nil {
+		return nil, err
 	}
+	return &n, nil
+}
+
+// InvalidRows returns the rows with invalid values of the last record, which were skipped or had the
+// invalid values set to null, depending on on_error
+func (p *CsvProcessor) InvalidRows() *InvalidRowsError {
+	p.dataMutex.RLock()
+	defer p.dataMutex.RUnlock()
 
-	return headers, nil
+	return p.invalidRows
 }
@@ -1,6 +1,8 @@
 package csv
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"sync"
 	"testing"
@@ -8,6 +10,7 @@ import (
 
 	"github.com/apache/arrow/go/v10/arrow"
 	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
 	"github.com/apache/arrow/go/v10/arrow/memory"
 	"github.com/bradleyjkemp/cupaloy"
 	"github.com/spiceai/data-components-contrib/dataconnectors/file"
@@ -35,6 +38,19 @@ func TestCsv(t *testing.T) {
 	t.Run("GetRecord() called twice", testGetRecordTwiceFunc(localData))
 	t.Run("GetRecord() updated with same data", testGetRecordSameDataFunc(localData))
 	t.Run("GetRecord() with partial column selection", testGetRecordPartialColumns(localData))
+	t.Run("GetRecord() time precision", testGetRecordTimePrecisionFunc())
+	t.Run("GetRecord() time zone", testGetRecordTimeZoneFunc())
+	t.Run("GetRecord() on_error", testGetRecordOnErrorFunc())
+	t.Run("GetRecord() delimiter and decimal separator", testGetRecordDelimiterFunc())
+	t.Run("GetRecord() comments and skipped lines", testGetRecordCommentsFunc())
+	t.Run("GetRecord() without header", testGetRecordNoHeaderFunc())
+	t.Run("GetRecord() lazy quotes", testGetRecordLazyQuotesFunc())
+	t.Run("Init() invalid format params", testInitInvalidFormatFunc())
+	t.Run("GetRecord() chunk_size", testGetRecordChunkSizeFunc())
+	t.Run("GetRecords()", testGetRecordsFunc())
+	t.Run("GetRecords() on_error", testGetRecordsOnErrorFunc())
+	t.Run("GetRecords() bounded memory", testGetRecordsMemoryFunc())
+	t.Run("GetRecord() measurement_type", testGetRecordMeasurementTypeFunc())
 
 }
 
@@ -46,6 +62,12 @@ func BenchmarkGetRecord(b *testing.B) {
 	b.Run("GetRecord()", benchGetRecordFunc(data))
 }
 
+func BenchmarkGetRecords(b *testing.B) {
+	data := generateCsvData(200000)
+	b.Run("GetRecords() chunk_size=0", benchGetRecordsFunc(data, ""))
+	b.Run("GetRecords() chunk_size=1024", benchGetRecordsFunc(data, "1024"))
+}
+
 // Tests "Init()"
 func testInitFunc() func(*testing.T) {
 	p := NewCsvProcessor()
@@ -522,6 +544,513 @@ func testGetRecordPartialColumns(data []byte) func(*testing.T) {
 	}
 }
 
+// Tests "GetRecord()" with the time_precision param
+func testGetRecordTimePrecisionFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		measurements := map[string]string{
+			"price": "price",
+		}
+
+		epochData := []byte("time,price\n1605312000123,16339.56\n1605312000456,16339.57\n")
+		formattedData := []byte("time,price\n2020-11-14 00:00:00.123,16339.56\n2020-11-14 00:00:00.456,16339.57\n")
+
+		for _, data := range [][]byte{epochData, formattedData} {
+			params := map[string]string{"time_precision": "ms"}
+			if bytes.Equal(data, formattedData) {
+				params["time_format"] = "2006-01-02 15:04:05.000"
+			}
+
+			dp := NewCsvProcessor()
+			err := dp.Init(params, nil, measurements, nil, nil)
+			assert.NoError(t, err)
+
+			_, err = dp.OnData(data)
+			assert.NoError(t, err)
+
+			record, err := dp.GetRecord()
+			if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+				return
+			}
+
+			assert.Equal(t, arrow.FixedWidthTypes.Timestamp_ms, record.Schema().Field(0).Type)
+			assert.Equal(t, []arrow.Timestamp{1605312000123, 1605312000456}, record.Column(0).(*array.Timestamp).TimestampValues())
+		}
+
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"time_precision": "minutes"}, nil, measurements, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with the time_zone param, across the DST transition
+func testGetRecordTimeZoneFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte("time,price\n2021-03-13 09:30:00,1\n2021-03-15 09:30:00,2\n")
+
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{
+			"time_format": "2006-01-02 15:04:05",
+			"time_zone":   "America/New_York",
+		}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+
+		// 14:30 UTC in EST, 13:30 UTC in EDT
+		assert.Equal(t, []int64{1615645800, 1615815000}, record.Column(0).(*array.Int64).Int64Values())
+
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"time_zone": "Mars/Olympus_Mons"}, nil, nil, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with the on_error param
+func testGetRecordOnErrorFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte("time,price,volume,_tags\n" +
+			"2020-11-14 00:00,16339.56,12,a\n" +
+			"2020-11-14 00:30,not-a-number,3,b\n" +
+			"2020-11-14 01:00,16100.5,,c\n" +
+			"2020-11-14 99:99,16150,0,d\n" +
+			"2020-11-14 02:00,16200.25,1,e\n")
+		params := map[string]string{"time_format": "2006-01-02 15:04"}
+		measurements := map[string]string{"price": "price", "volume": "volume"}
+
+		getRecord := func(onError string) (arrow.Record, *InvalidRowsError, error) {
+			dp := NewCsvProcessor()
+			params["on_error"] = onError
+			err := dp.Init(params, nil, measurements, nil, []string{"_tags"})
+			if !assert.NoError(t, err) {
+				return nil, nil, err
+			}
+			_, err = dp.OnData(data)
+			assert.NoError(t, err)
+			record, err := dp.GetRecord()
+			return record, dp.InvalidRows(), err
+		}
+
+		// Invalid rows are removed from all columns, the missing volume is not an error
+		record, invalidRows, err := getRecord("skip")
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, int64(3), record.NumRows())
+			assert.Equal(t, "[1605312000 1605315600 1605319200]", record.Column(0).String())
+			assert.Equal(t, "[16339.56 16100.5 16200.25]", record.Column(1).String())
+			assert.Equal(t, "[12 (null) 1]", record.Column(2).String())
+			assert.Equal(t, `[["a"] ["c"] ["e"]]`, record.Column(3).String())
+		}
+		if assert.NotNil(t, invalidRows) {
+			assert.Equal(t, []int{3, 5}, invalidRows.Lines)
+			if assert.Len(t, invalidRows.Values, 2) {
+				assert.Equal(t, "price", invalidRows.Values[0].Column)
+				assert.Equal(t, "not-a-number", invalidRows.Values[0].Value)
+				assert.Equal(t, "time", invalidRows.Values[1].Column)
+				assert.Equal(t, 5, invalidRows.Values[1].Line)
+			}
+		}
+
+		// Invalid measurement values are null, while rows with an invalid time are skipped
+		record, invalidRows, err = getRecord("null")
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, int64(4), record.NumRows())
+			assert.Equal(t, "[1605312000 1605313800 1605315600 1605319200]", record.Column(0).String())
+			assert.Equal(t, "[16339.56 (null) 16100.5 16200.25]", record.Column(1).String())
+			assert.Equal(t, `[["a"] ["b"] ["c"] ["e"]]`, record.Column(3).String())
+		}
+		if assert.NotNil(t, invalidRows) {
+			assert.Equal(t, []int{3, 5}, invalidRows.Lines)
+		}
+
+		// The default is null, and the time is never null
+		record, _, err = getRecord("")
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, int64(4), record.NumRows())
+			assert.Equal(t, 0, record.Column(0).NullN())
+			assert.Equal(t, 1, record.Column(1).NullN())
+		}
+
+		_, _, err = getRecord("fail")
+		var invalidRowsError *InvalidRowsError
+		if assert.ErrorAs(t, err, &invalidRowsError) {
+			assert.Equal(t, []int{3, 5}, invalidRowsError.Lines)
+			assert.Contains(t, err.Error(), "2 invalid rows at lines 3, 5: invalid value 'not-a-number' of column 'price' at line 3")
+		}
+
+		// Valid data has no invalid rows
+		dp := NewCsvProcessor()
+		err = dp.Init(map[string]string{"on_error": "fail"}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData([]byte("time,price,volume\n1605312000,16339.56,12\n"))
+		assert.NoError(t, err)
+		_, err = dp.GetRecord()
+		assert.NoError(t, err)
+		assert.Nil(t, dp.InvalidRows())
+
+		err = dp.Init(map[string]string{"on_error": "ignore"}, nil, measurements, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with the delimiter and decimal_separator params
+func testGetRecordDelimiterFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		measurements := map[string]string{"price": "price"}
+		categories := map[string]string{"side": "side"}
+
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"delimiter": "\\t"}, nil, measurements, categories, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData([]byte("time\tprice\tside\n1605312000\t16339.56\tbuy, limit\n"))
+		assert.NoError(t, err)
+		record, err := dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, "[16339.56]", record.Column(1).String())
+			assert.Equal(t, `["buy, limit"]`, record.Column(2).String())
+		}
+
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"delimiter": ";", "decimal_separator": ","}, nil, measurements, categories, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData([]byte("time;price;side\n1605312000;16339,56;buy\n1605313800;16100;sell\n"))
+		assert.NoError(t, err)
+		record, err = dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, "[1605312000 1605313800]", record.Column(0).String())
+			assert.Equal(t, "[16339.56 16100]", record.Column(1).String())
+		}
+
+		// Thousands separators are removed with the , decimal separator
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"delimiter": ";", "decimal_separator": ","}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData([]byte("time;price\n1605312000;16.339,56\n1605313800;1 234,5\n1605315600;1\u00a0000\n1605317400;1,2,3\n"))
+		assert.NoError(t, err)
+		record, err = dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, "[16339.56 1234.5 1000 (null)]", record.Column(1).String())
+		}
+		if assert.NotNil(t, dp.InvalidRows()) {
+			assert.Equal(t, []int{5}, dp.InvalidRows().Lines)
+		}
+	}
+}
+
+// Tests "GetRecord()" with the comment and skip_lines params
+func testGetRecordCommentsFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte("Exported prices\n\n" +
+			"time,price\n" +
+			"# Opening\n" +
+			"1605312000,16339.56\n" +
+			"1605313800,not-a-number\n")
+
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"comment": "#", "skip_lines": "2"}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		record, err := dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, int64(2), record.NumRows())
+			assert.Equal(t, "[16339.56 (null)]", record.Column(1).String())
+		}
+		// Line numbers include the skipped lines and comments
+		if assert.NotNil(t, dp.InvalidRows()) {
+			assert.Equal(t, []int{6}, dp.InvalidRows().Lines)
+		}
+
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"skip_lines": "10"}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		_, err = dp.GetRecord()
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with "header: false" and the columns param
+func testGetRecordNoHeaderFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"header": "false", "columns": "time, symbol, price"}, map[string]string{"symbol": "symbol"}, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData([]byte("1605312000,BTC-USD,16339.56\n1605313800,BTC-USD,16100\n"))
+		assert.NoError(t, err)
+		record, err := dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, int64(2), record.NumRows())
+			assert.Equal(t, "id.symbol", record.Schema().Field(1).Name)
+			assert.Equal(t, `["BTC-USD" "BTC-USD"]`, record.Column(1).String())
+			assert.Equal(t, "[16339.56 16100]", record.Column(2).String())
+		}
+
+		// Rows with a different number of fields than the columns are malformed
+		_, err = dp.OnData([]byte("1605312000,BTC-USD\n"))
+		assert.NoError(t, err)
+		_, err = dp.GetRecord()
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with the lazy_quotes param
+func testGetRecordLazyQuotesFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte("time,symbol\n1605312000,the \"BTC\" pair\n")
+
+		dp := NewCsvProcessor()
+		err := dp.Init(nil, map[string]string{"symbol": "symbol"}, nil, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		_, err = dp.GetRecord()
+		assert.Error(t, err)
+
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"lazy_quotes": "true"}, map[string]string{"symbol": "symbol"}, nil, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		record, err := dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, `["the \"BTC\" pair"]`, record.Column(1).String())
+		}
+	}
+}
+
+func testInitInvalidFormatFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		invalidParams := []map[string]string{
+			{"delimiter": ",,"},
+			{"delimiter": "\n"},
+			{"comment": ",", "delimiter": ","},
+			{"lazy_quotes": "sometimes"},
+			{"header": "no"},
+			{"header": "false"},
+			{"columns": "time,price"},
+			{"skip_lines": "-1"},
+			{"decimal_separator": "'"},
+			{"decimal_separator": ","},
+		}
+		for _, params := range invalidParams {
+			dp := NewCsvProcessor()
+			err := dp.Init(params, nil, nil, nil, nil)
+			assert.Error(t, err, "params %v", params)
+		}
+	}
+}
+
+// Tests "GetRecord()" returns all the rows of the new data with the chunk_size param, which only applies to
+// "GetRecords()"
+func testGetRecordChunkSizeFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"chunk_size": "2"}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(generateCsvData(5))
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, "[1605312000 1605312001 1605312002 1605312003 1605312004]", record.Column(0).String())
+		}
+
+		// New data is returned by the next call
+		_, err = dp.OnData(generateCsvData(3))
+		assert.NoError(t, err)
+		record, err = dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, int64(3), record.NumRows())
+		}
+
+		// The same data isn't processed again
+		_, err = dp.OnData(generateCsvData(3))
+		assert.NoError(t, err)
+		record, err = dp.GetRecord()
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+
+		err = dp.Init(map[string]string{"chunk_size": "0"}, nil, nil, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests iterating the records of "GetRecords()"
+func testGetRecordsFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"chunk_size": "3"}, map[string]string{"symbol": "symbol"}, map[string]string{"price": "price"}, nil, []string{"_tags"})
+		assert.NoError(t, err)
+		_, err = dp.OnData(generateCsvData(7))
+		assert.NoError(t, err)
+
+		reader, err := dp.GetRecords()
+		if !assert.NoError(t, err) || !assert.NotNil(t, reader) {
+			return
+		}
+		defer reader.Release()
+
+		assert.Equal(t, "time", reader.Schema().Field(0).Name)
+		assert.Equal(t, "tags", reader.Schema().Field(3).Name)
+
+		var numRows []int64
+		for reader.Next() {
+			assert.True(t, reader.Schema().Equal(reader.Record().Schema()))
+			numRows = append(numRows, reader.Record().NumRows())
+		}
+		assert.NoError(t, reader.Err())
+		assert.Equal(t, []int64{3, 3, 1}, numRows)
+
+		// The data is consumed
+		reader, err = dp.GetRecords()
+		assert.NoError(t, err)
+		assert.Nil(t, reader)
+
+		// Without chunk_size, all the rows are read into a single record
+		dp = NewCsvProcessor()
+		err = dp.Init(nil, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(generateCsvData(7))
+		assert.NoError(t, err)
+		reader, err = dp.GetRecords()
+		if assert.NoError(t, err) && assert.NotNil(t, reader) {
+			defer reader.Release()
+			assert.True(t, reader.Next())
+			assert.Equal(t, int64(7), reader.Record().NumRows())
+			assert.False(t, reader.Next())
+		}
+	}
+}
+
+// Tests on_error is applied to each record of "GetRecords()"
+func testGetRecordsOnErrorFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte("time,price\n" +
+			"1605312000,16339.56\n" +
+			"1605312001,16100.5\n" +
+			"1605312002,not-a-number\n" +
+			"1605312003,16150\n")
+
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"chunk_size": "2", "on_error": "skip"}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		reader, err := dp.GetRecords()
+		if !assert.NoError(t, err) || !assert.NotNil(t, reader) {
+			return
+		}
+		defer reader.Release()
+
+		assert.True(t, reader.Next())
+		assert.Nil(t, reader.InvalidRows())
+		assert.True(t, reader.Next())
+		assert.Equal(t, "[16150]", reader.Record().Column(1).String())
+		if assert.NotNil(t, reader.InvalidRows()) {
+			assert.Equal(t, []int{4}, reader.InvalidRows().Lines)
+		}
+		assert.False(t, reader.Next())
+		assert.NoError(t, reader.Err())
+
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"chunk_size": "2", "on_error": "fail"}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		reader, err = dp.GetRecords()
+		if !assert.NoError(t, err) || !assert.NotNil(t, reader) {
+			return
+		}
+		defer reader.Release()
+
+		assert.True(t, reader.Next())
+		assert.False(t, reader.Next())
+		var invalidRowsError *InvalidRowsError
+		assert.ErrorAs(t, reader.Err(), &invalidRowsError)
+	}
+}
+
+// Tests the memory allocated for records with chunk_size is bounded by the chunk size
+func testGetRecordsMemoryFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := generateCsvData(20000)
+
+		peak := func(chunkSize string) int64 {
+			allocator := newPeakAllocator()
+			dp := NewCsvProcessor()
+			dp.allocator = allocator
+			err := dp.Init(map[string]string{"chunk_size": chunkSize}, map[string]string{"symbol": "symbol"}, map[string]string{"price": "price"}, nil, []string{"_tags"})
+			assert.NoError(t, err)
+			_, err = dp.OnData(data)
+			assert.NoError(t, err)
+
+			reader, err := dp.GetRecords()
+			if !assert.NoError(t, err) || !assert.NotNil(t, reader) {
+				return 0
+			}
+			numRows := int64(0)
+			for reader.Next() {
+				numRows += reader.Record().NumRows()
+			}
+			reader.Release()
+			assert.NoError(t, reader.Err())
+			assert.Equal(t, int64(20000), numRows)
+			assert.Equal(t, int64(0), allocator.current)
+
+			return allocator.peak
+		}
+
+		chunkedPeak := peak("100")
+		assert.Less(t, chunkedPeak*20, peak(""))
+	}
+}
+
+// Tests "GetRecord()" with the measurement_type param
+func testGetRecordMeasurementTypeFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte("time,volume,price\n" +
+			"1605312000,9007199254740993,16339.56\n" +
+			"1605313800,0x10,16100.5\n" +
+			"1605315600,1.5,16150\n")
+		measurements := map[string]string{"volume": "volume"}
+
+		dp := NewCsvProcessor()
+		err := dp.Init(map[string]string{"measurement_type": "int64"}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		record, err := dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, arrow.PrimitiveTypes.Int64, record.Schema().Field(1).Type)
+			// Integers are exact, and fractions are invalid values
+			assert.Equal(t, "[9007199254740993 16 (null)]", record.Column(1).String())
+		}
+		if assert.NotNil(t, dp.InvalidRows()) {
+			assert.Equal(t, []int{4}, dp.InvalidRows().Lines)
+		}
+
+		dp = NewCsvProcessor()
+		err = dp.Init(map[string]string{"measurement_type": "decimal128(10, 2)", "delimiter": ";", "decimal_separator": ","}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData([]byte("time;price\n1605312000;16339,56\n"))
+		assert.NoError(t, err)
+		record, err = dp.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, record.Schema().Field(1).Type)
+			assert.Equal(t, decimal128.FromI64(1633956), record.Column(1).(*array.Decimal128).Value(0))
+		}
+
+		err = dp.Init(map[string]string{"measurement_type": "double"}, nil, measurements, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
 // Benchmark "GetRecord()"
 func benchGetRecordFunc(data []byte) func(*testing.B) {
 	return func(b *testing.B) {
@@ -549,3 +1078,80 @@ func benchGetRecordFunc(data []byte) func(*testing.B) {
 		}
 	}
 }
+
+// Benchmark "GetRecords()", reporting the peak memory allocated for records
+func benchGetRecordsFunc(data []byte, chunkSize string) func(*testing.B) {
+	return func(b *testing.B) {
+		b.ReportAllocs()
+		var peak int64
+		for i := 0; i < b.N; i++ {
+			allocator := newPeakAllocator()
+			dp := NewCsvProcessor()
+			dp.allocator = allocator
+			err := dp.Init(map[string]string{"chunk_size": chunkSize}, map[string]string{"symbol": "symbol"}, map[string]string{"price": "price"}, nil, []string{"_tags"})
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+			_, err = dp.OnData(data)
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+
+			reader, err := dp.GetRecords()
+			if err != nil {
+				b.Fatal(err.Error())
+			}
+			for reader.Next() {
+			}
+			reader.Release()
+			if reader.Err() != nil {
+				b.Fatal(reader.Err().Error())
+			}
+			peak = allocator.peak
+		}
+		b.ReportMetric(float64(peak), "peak-record-bytes")
+	}
+}
+
+// Returns CSV data of rows one second apart
+func generateCsvData(numRows int) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("time,symbol,price,_tags\n")
+	for i := 0; i < numRows; i++ {
+		fmt.Fprintf(&buffer, "%d,BTC-USD,%d.25,buy limit\n", 1605312000+i, 16000+i%1000)
+	}
+	return buffer.Bytes()
+}
+
+// An allocator tracking the current and peak allocated bytes
+type peakAllocator struct {
+	allocator memory.Allocator
+	current   int64
+	peak      int64
+}
+
+func newPeakAllocator() *peakAllocator {
+	return &peakAllocator{allocator: memory.NewGoAllocator()}
+}
+
+func (a *peakAllocator) Allocate(size int) []byte {
+	a.add(int64(size))
+	return a.allocator.Allocate(size)
+}
+
+func (a *peakAllocator) Reallocate(size int, b []byte) []byte {
+	a.add(int64(size - len(b)))
+	return a.allocator.Reallocate(size, b)
+}
+
+func (a *peakAllocator) Free(b []byte) {
+	a.add(-int64(len(b)))
+	a.allocator.Free(b)
+}
+
+func (a *peakAllocator) add(size int64) {
+	a.current += size
+	if a.current > a.peak {
+		a.peak = a.current
+	}
+}
@@ -0,0 +1,292 @@
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
+)
+
+// RecordReader reads the rows of CSV data as a sequence of records of up to chunk_size rows, so only
+// the rows of the current record are held in memory. It implements array.RecordReader.
+type RecordReader struct {
+	refCount int64
+
+	processor  *CsvProcessor
+	reader     *csv.Reader
+	pool       memory.Allocator
+	chunkSize  int
+	schema     *arrow.Schema
+	headers    []string
+	timeCol    int
+	columns    []*csvColumn
+	tagColumns []int
+
+	record      arrow.Record
+	invalidRows *InvalidRowsError
+	done        bool
+	err         error
+}
+
+var _ array.RecordReader = (*RecordReader)(nil)
+
+// Reads the header and maps its columns. A chunkSize of 0 reads all the rows into a single record.
+func (p *CsvProcessor) newRecordReader(data []byte, chunkSize int) (*RecordReader, error) {
+	identifiersMap := make(map[string]string)
+	measurementsMap := make(map[string]string)
+	categoriesMap := make(map[string]string)
+	tagsMap := make(map[string]bool)
+	for key, colName := range p.identifiers {
+		identifiersMap[colName] = key
+	}
+	for key, colName := range p.measurements {
+		measurementsMap[colName] = key
+	}
+	for key, colName := range p.categories {
+		categoriesMap[colName] = key
+	}
+	for _, key := range p.tags {
+		tagsMap[key] = true
+	}
+
+	reader, err := p.newCsvReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := p.columnNames
+	if p.header {
+		headers, err = reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get csv header: %w", err)
+		}
+		// The header row is reused by the next read
+		headers = append([]string(nil), headers...)
+	}
+	reader.FieldsPerRecord = len(headers)
+	// The returned rows are only read before reading the next one
+	reader.ReuseRecord = true
+
+	measurementType, err := p.measurementType.OutputType(arrow.BinaryTypes.String)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []*csvColumn
+	var tagColumns []int
+	timeCol := -1
+	for i, header := range headers {
+		// Allocate time column index upon finding timeSelector if not yet assigned
+		if timeCol < 0 && header == p.timeSelector {
+			timeCol = i
+		} else if key, ok := identifiersMap[header]; ok {
+			columns = append(columns, &csvColumn{index: i, field: arrow.Field{Name: conv.IdentifierPrefix + key, Type: arrow.BinaryTypes.String}})
+		} else if key, ok := measurementsMap[header]; ok {
+			columns = append(columns, &csvColumn{index: i, field: arrow.Field{Name: conv.MeasurementPrefix + key, Type: measurementType}, measurement: true})
+		} else if key, ok := categoriesMap[header]; ok {
+			columns = append(columns, &csvColumn{index: i, field: arrow.Field{Name: conv.CategoryPrefix + key, Type: arrow.BinaryTypes.String}})
+		} else if tagsMap[header] {
+			tagColumns = append(tagColumns, i)
+		}
+	}
+
+	if timeCol < 0 {
+		return nil, fmt.Errorf("time header '%s' not found", p.timeSelector)
+	}
+
+	fields := []arrow.Field{{Name: conv.TimeColumnName, Type: p.timePrecision.DataType()}}
+	for _, column := range columns {
+		fields = append(fields, column.field)
+	}
+	fields = append(fields, arrow.Field{Name: conv.TagsColumnName, Type: conv.TagsType})
+
+	pool := p.allocator
+	if pool == nil {
+		pool = memory.NewGoAllocator()
+	}
+
+	return &RecordReader{
+		refCount:   1,
+		processor:  p,
+		reader:     reader,
+		pool:       pool,
+		chunkSize:  chunkSize,
+		schema:     arrow.NewSchema(fields, nil),
+		headers:    headers,
+		timeCol:    timeCol,
+		columns:    columns,
+		tagColumns: tagColumns,
+	}, nil
+}
+
+func (r *RecordReader) Retain() {
+	atomic.AddInt64(&r.refCount, 1)
+}
+
+func (r *RecordReader) Release() {
+	if atomic.AddInt64(&r.refCount, -1) == 0 {
+		if r.record != nil {
+			r.record.Release()
+			r.record = nil
+		}
+		r.reader = nil
+	}
+}
+
+func (r *RecordReader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+// Next reads the next record, and returns false after the last record or an error
+func (r *RecordReader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.done || r.err != nil {
+		return false
+	}
+
+	record, err := r.readChunk()
+	if err != nil {
+		r.err = err
+		return false
+	}
+	if record.NumRows() == 0 {
+		// Chunks are only empty at the end of the data
+		record.Release()
+		return false
+	}
+
+	r.record = record
+	return true
+}
+
+// Record returns the current record, which is valid until the next call to Next
+func (r *RecordReader) Record() arrow.Record {
+	return r.record
+}
+
+// Err returns the error that stopped Next, such as malformed rows or an *InvalidRowsError with on_error fail
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// InvalidRows returns the rows with invalid values of the current record, which were skipped or had the
+// invalid values set to null, depending on on_error
+func (r *RecordReader) InvalidRows() *InvalidRowsError {
+	return r.invalidRows
+}
+
+// Reads up to chunkSize valid rows into a record
+func (r *RecordReader) readChunk() (arrow.Record, error) {
+	p := r.processor
+
+	timeBuilder := p.timePrecision.NewBuilder(r.pool)
+	defer timeBuilder.Release()
+	builders := []array.Builder{timeBuilder}
+	for _, column := range r.columns {
+		column.builder = array.NewBuilder(r.pool, column.field.Type)
+		defer column.builder.Release()
+		builders = append(builders, column.builder)
+	}
+	tagsBuilder := conv.NewTagsBuilder(r.pool)
+	defer tagsBuilder.Release()
+
+	invalidRows := &InvalidRowsError{}
+	measurements := make([]*conv.Number, len(r.columns))
+	for r.chunkSize == 0 || timeBuilder.Len() < r.chunkSize {
+		row, err := r.reader.Read()
+		if err == io.EOF {
+			r.done = true
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv data: %w", err)
+		}
+		line, _ := r.reader.FieldPos(0)
+		line += p.skipLines
+
+		// Parsing the time and measurement values before appending the row, so invalid rows can be skipped
+		var invalid []InvalidValue
+		value := row[r.timeCol]
+		t, err := p.timePrecision.ParseTime(value, p.timeFormat, p.timeZone)
+		timeValid := err == nil
+		if err != nil {
+			invalid = append(invalid, InvalidValue{Line: line, Column: r.headers[r.timeCol], Value: value, Err: err})
+		}
+		for i, column := range r.columns {
+			measurements[i] = nil
+			if !column.measurement {
+				continue
+			}
+			value := row[column.index]
+			if value == "" {
+				// Missing values are null
+				continue
+			}
+			measurement, err := p.parseMeasurement(value, column.field.Type)
+			if err != nil {
+				invalid = append(invalid, InvalidValue{Line: line, Column: r.headers[column.index], Value: value, Err: err})
+				continue
+			}
+			measurements[i] = measurement
+		}
+
+		if len(invalid) > 0 {
+			invalidRows.Lines = append(invalidRows.Lines, line)
+			invalidRows.Values = append(invalidRows.Values, invalid...)
+			// Rows without a valid time are skipped with null too, as the time column can't be null
+			if p.onError != onErrorNull || !timeValid {
+				continue
+			}
+		}
+
+		p.timePrecision.Append(timeBuilder, t)
+
+		for i, column := range r.columns {
+			if !column.measurement {
+				column.builder.(*array.StringBuilder).Append(row[column.index])
+			} else if measurements[i] == nil {
+				column.builder.AppendNull()
+			} else {
+				conv.AppendNumber(column.builder, *measurements[i])
+			}
+		}
+
+		// Aggregating tags
+		tagsBuilder.NewRow()
+		for _, colIndex := range r.tagColumns {
+			tagsBuilder.AppendFields(row[colIndex])
+		}
+	}
+
+	r.invalidRows = nil
+	if len(invalidRows.Lines) > 0 {
+		if p.onError == onErrorFail {
+			return nil, invalidRows
+		}
+		r.invalidRows = invalidRows
+		if p.onError == onErrorSkip {
+			log.Printf("csv processor skipped %s", invalidRows.Error())
+		} else {
+			log.Printf("csv processor set invalid values to null or skipped rows with an invalid time in %s", invalidRows.Error())
+		}
+	}
+
+	columnArrays := make([]arrow.Array, len(builders), len(builders)+1)
+	for i, builder := range builders {
+		columnArrays[i] = builder.NewArray()
+		defer columnArrays[i].Release()
+	}
+	columnArrays = append(columnArrays, tagsBuilder.NewArray())
+	defer columnArrays[len(builders)].Release()
+
+	return array.NewRecord(r.schema, columnArrays, int64(columnArrays[0].Len())), nil
+}
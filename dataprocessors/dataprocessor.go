@@ -8,6 +8,7 @@ import (
 	"github.com/spiceai/data-components-contrib/dataprocessors/csv"
 	"github.com/spiceai/data-components-contrib/dataprocessors/flux"
 	"github.com/spiceai/data-components-contrib/dataprocessors/json"
+	"github.com/spiceai/data-components-contrib/dataprocessors/parquet"
 )
 
 type DataProcessor interface {
@@ -20,12 +21,16 @@ func NewDataProcessor(name string) (DataProcessor, error) {
 	switch name {
 	case arrow_processor.ArrowProcessorName:
 		return arrow_processor.NewArrowProcessor(), nil
+	case arrow_processor.ArrowIpcProcessorName:
+		return arrow_processor.NewArrowIpcProcessor(), nil
 	case csv.CsvProcessorName:
 		return csv.NewCsvProcessor(), nil
 	case flux.FluxCsvProcessorName:
 		return flux.NewFluxCsvProcessor(), nil
 	case json.JsonProcessorName:
 		return json.NewJsonProcessor(), nil
+	case parquet.ParquetProcessorName:
+		return parquet.NewParquetProcessor(), nil
 	}
 
 	return nil, fmt.Errorf("unknown processor '%s'", name)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/influxdata/flux"
 	flux_array "github.com/influxdata/flux/array"
 	flux_csv "github.com/influxdata/flux/csv"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
 	"github.com/spiceai/spiceai/pkg/loggers"
 	"github.com/spiceai/spiceai/pkg/util"
 	"go.uber.org/zap"
@@ -28,16 +30,34 @@ const (
 )
 
 type FluxCsvProcessor struct {
+	timePrecision *conv.TimePrecision
+
 	data      []byte
 	dataMutex sync.RWMutex
 	dataHash  []byte
 }
 
+type fluxRow struct {
+	time int64
+	// The _measurement of the row, output as the cat.measurement column when the data has several measurements
+	measurement *string
+	tags        []string
+	// Values keyed by field index
+	values map[int]float64
+}
+
 func NewFluxCsvProcessor() *FluxCsvProcessor {
-	return &FluxCsvProcessor{}
+	// Init is optional, times are in seconds by default
+	return &FluxCsvProcessor{timePrecision: &conv.TimePrecision{Unit: arrow.Second}}
 }
 
 func (p *FluxCsvProcessor) Init(params map[string]string, identifiers map[string]string, measurements map[string]string, categories map[string]string, tags []string) error {
+	timePrecision, err := conv.NewTimePrecision(params)
+	if err != nil {
+		return err
+	}
+	p.timePrecision = timePrecision
+
 	return nil
 }
 
@@ -77,20 +97,12 @@ func (p *FluxCsvProcessor) GetRecord() (arrow.Record, error) {
 	}
 	defer results.Release()
 
-	arrow_fields := []arrow.Field{}
-
-	pool := memory.NewGoAllocator()
-
-	timeBuilder := array.NewInt64Builder(pool)
-	defer timeBuilder.Release()
-
-	valueBuilder := array.NewFloat64Builder(pool)
-	defer valueBuilder.Release()
-
-	tagListBuilder := array.NewListBuilder(pool, arrow.BinaryTypes.String)
-	defer tagListBuilder.Release()
-	tagValueBuilder := tagListBuilder.ValueBuilder().(*array.StringBuilder)
-	defer tagValueBuilder.Release()
+	// Rows are keyed by time, measurement and tags, with one measure column per field
+	var fieldNames []string
+	fieldIndices := make(map[string]int)
+	var rows []*fluxRow
+	rowIndices := make(map[string]int)
+	measurementNames := make(map[string]bool)
 
 	for results.More() {
 		result := results.Next()
@@ -102,9 +114,10 @@ func (p *FluxCsvProcessor) GetRecord() (arrow.Record, error) {
 				timeIndex := -1
 				fieldIndex := -1
 				valueIndex := -1
+				measurementIndex := -1
 				var tagIndices []int
 				for colIndex, colMeta := range colReader.Cols() {
-					// We currently only support one field and float for now
+					// We currently only support float values for now
 					switch {
 					case colMeta.Label == "_time":
 						timeIndex = colIndex
@@ -112,7 +125,9 @@ func (p *FluxCsvProcessor) GetRecord() (arrow.Record, error) {
 						fieldIndex = colIndex
 					case colMeta.Label == "_value":
 						valueIndex = colIndex
-					case colMeta.Label == "_measurement" || colMeta.Type.String() != "string":
+					case colMeta.Label == "_measurement":
+						measurementIndex = colIndex
+					case colMeta.Type.String() != "string":
 						continue
 					default:
 						tagIndices = append(tagIndices, colIndex)
@@ -140,35 +155,55 @@ func (p *FluxCsvProcessor) GetRecord() (arrow.Record, error) {
 				values := colReader.Floats(valueIndex)
 				defer values.Release()
 
+				var measurements *flux_array.String
+				if measurementIndex != -1 {
+					measurements = colReader.Strings(measurementIndex)
+					defer measurements.Release()
+				}
+
 				var tags []*flux_array.String
 				for _, tagIndex := range tagIndices {
 					tags = append(tags, colReader.Strings(tagIndex))
 				}
 
-				if len(arrow_fields) == 0 {
-					arrow_fields = []arrow.Field{
-						{Name: "time", Type: arrow.PrimitiveTypes.Int64},
-						{Name: fmt.Sprintf("measure.%s", fields.Value(0)), Type: arrow.PrimitiveTypes.Float64},
-						{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+				for i := 0; i < colReader.Len(); i++ {
+					if !times.IsValid(i) || times.IsNull(i) ||
+						!fields.IsValid(i) || fields.IsNull(i) ||
+						!values.IsValid(i) || values.IsNull(i) {
+						continue
 					}
-				}
 
-				secondScale := int64(time.Second)
-				for i := 0; i < colReader.Len(); i++ {
-					tagListBuilder.Append(true)
-					if times.IsValid(i) && !times.IsNull(i) &&
-						fields.IsValid(i) && !fields.IsNull(i) &&
-						values.IsValid(i) && !values.IsNull(i) {
-
-						for _, tagValue := range tags {
-							if tagValue.IsValid(i) && !tagValue.IsNull(i) {
-								tagValueBuilder.Append(tagValue.Value(i))
-							}
+					fieldName := fields.Value(i)
+					if _, ok := fieldIndices[fieldName]; !ok {
+						fieldIndices[fieldName] = len(fieldNames)
+						fieldNames = append(fieldNames, fieldName)
+					}
+
+					// Flux times are in nanoseconds
+					row := &fluxRow{time: p.timePrecision.FromTime(time.Unix(0, times.Value(i)))}
+					for _, tagValue := range tags {
+						if tagValue.IsValid(i) && !tagValue.IsNull(i) {
+							row.tags = append(row.tags, tagValue.Value(i))
 						}
+					}
 
-						timeBuilder.Append(times.Value(i) / secondScale)
-						valueBuilder.Append(values.Value(i))
+					keyParts := append([]string{fmt.Sprintf("%d", row.time), ""}, row.tags...)
+					if measurements != nil && measurements.IsValid(i) && !measurements.IsNull(i) {
+						measurement := measurements.Value(i)
+						row.measurement = &measurement
+						keyParts[1] = measurement
+						measurementNames[measurement] = true
 					}
+					key := strings.Join(keyParts, "\x00")
+
+					if rowIndex, ok := rowIndices[key]; ok {
+						row = rows[rowIndex]
+					} else {
+						row.values = make(map[int]float64)
+						rowIndices[key] = len(rows)
+						rows = append(rows, row)
+					}
+					row.values[fieldIndices[fieldName]] = values.Value(i)
 				}
 
 				for _, tagCol := range tags {
@@ -190,12 +225,61 @@ func (p *FluxCsvProcessor) GetRecord() (arrow.Record, error) {
 
 	p.data = nil
 
-	numRows := int64(timeBuilder.Len())
+	if len(fieldNames) == 0 {
+		return nil, nil
+	}
+
+	pool := memory.NewGoAllocator()
+
+	timeBuilder := p.timePrecision.NewBuilder(pool)
+	defer timeBuilder.Release()
+	valueBuilders := make([]*array.Float64Builder, len(fieldNames))
+	for fieldIndex := range fieldNames {
+		valueBuilders[fieldIndex] = array.NewFloat64Builder(pool)
+		defer valueBuilders[fieldIndex].Release()
+	}
+	measurementBuilder := array.NewStringBuilder(pool)
+	defer measurementBuilder.Release()
+	tagsBuilder := conv.NewTagsBuilder(pool)
+	defer tagsBuilder.Release()
+
+	for _, row := range rows {
+		p.timePrecision.AppendEpoch(timeBuilder, row.time)
+		for fieldIndex, valueBuilder := range valueBuilders {
+			if value, ok := row.values[fieldIndex]; ok {
+				valueBuilder.Append(value)
+			} else {
+				valueBuilder.AppendNull()
+			}
+		}
+		if row.measurement != nil {
+			measurementBuilder.Append(*row.measurement)
+		} else {
+			measurementBuilder.AppendNull()
+		}
+		tagsBuilder.NewRow()
+		for _, tag := range row.tags {
+			tagsBuilder.Append(tag)
+		}
+	}
 
-	record := array.NewRecord(
-		arrow.NewSchema(arrow_fields, nil),
-		[]arrow.Array{timeBuilder.NewArray(), valueBuilder.NewArray(), tagListBuilder.NewArray()},
-		numRows)
+	arrowFields := []arrow.Field{{Name: conv.TimeColumnName, Type: p.timePrecision.DataType()}}
+	columns := []arrow.Array{timeBuilder.NewArray()}
+	for fieldIndex, fieldName := range fieldNames {
+		arrowFields = append(arrowFields, arrow.Field{Name: conv.MeasurementPrefix + fieldName, Type: arrow.PrimitiveTypes.Float64})
+		columns = append(columns, valueBuilders[fieldIndex].NewArray())
+	}
+	// Rows of measurements with the same field names are told apart by their measurement, while the schema of
+	// data of a single measurement is unchanged
+	if len(measurementNames) > 1 {
+		arrowFields = append(arrowFields, arrow.Field{Name: conv.CategoryPrefix + "measurement", Type: arrow.BinaryTypes.String})
+		columns = append(columns, measurementBuilder.NewArray())
+	}
+	arrowFields = append(arrowFields, tagsBuilder.Field())
+	columns = append(columns, tagsBuilder.NewArray())
+	for _, column := range columns {
+		defer column.Release()
+	}
 
-	return record, nil
+	return array.NewRecord(arrow.NewSchema(arrowFields, nil), columns, int64(len(rows))), nil
 }
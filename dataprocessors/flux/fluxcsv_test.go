@@ -23,6 +23,21 @@ func TestFlux(t *testing.T) {
 	t.Run("GetRecord() -o observations.json", testGetRecordFunc(data))
 	t.Run("GetRecord() called twice -o observations.json", testGetRecordTwiceFunc(data))
 	t.Run("GetRecord() same data -o observations.json", testGetRecordSameDataFunc(data))
+
+	multiFieldData, err := os.ReadFile("../../test/assets/data/annotated-csv/multi_field_influxdb_annotated.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("GetRecord() multiple fields", testGetRecordMultipleFieldsFunc(multiFieldData))
+
+	multiMeasurementData, err := os.ReadFile("../../test/assets/data/annotated-csv/multi_measurement_influxdb_annotated.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("GetRecord() multiple measurements", testGetRecordMultipleMeasurementsFunc(multiMeasurementData))
+	t.Run("GetRecord() time precision", testGetRecordTimePrecisionFunc())
 }
 
 // Tests "Init()"
@@ -175,3 +190,122 @@ func testGetRecordSameDataFunc(data []byte) func(*testing.T) {
 		assert.Nil(t, actualRecord2)
 	}
 }
+
+// Tests "GetRecord()" with several fields
+func testGetRecordMultipleFieldsFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewFluxCsvProcessor()
+		err := dp.Init(nil, nil, nil, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		actualRecord, err := dp.GetRecord()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer actualRecord.Release()
+
+		fields := []arrow.Field{
+			{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+			{Name: "measure.usage_idle", Type: arrow.PrimitiveTypes.Float64},
+			{Name: "measure.usage_user", Type: arrow.PrimitiveTypes.Float64},
+			{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		}
+		pool := memory.NewGoAllocator()
+		recordBuilder := array.NewRecordBuilder(pool, arrow.NewSchema(fields, nil))
+		defer recordBuilder.Release()
+		recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1629159600, 1629160800, 1629159600, 1629160800}, nil)
+		recordBuilder.Field(1).(*array.Float64Builder).AppendValues([]float64{99.5, 98.5, 42.25, 0}, []bool{true, true, true, false})
+		recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{0.5, 1.5, 0, 57.75}, []bool{true, true, false, true})
+		listBuilder := recordBuilder.Field(3).(*array.ListBuilder)
+		valueBuilder := listBuilder.ValueBuilder().(*array.StringBuilder)
+		for _, tags := range [][]string{{"web-1", "us-east"}, {"web-1", "us-east"}, {"web-2", "us-west"}, {"web-2", "us-west"}} {
+			listBuilder.Append(true)
+			valueBuilder.AppendValues(tags, nil)
+		}
+
+		expectedRecord := recordBuilder.NewRecord()
+		defer expectedRecord.Release()
+
+		assert.True(t, expectedRecord.Schema().Equal(actualRecord.Schema()), "Schema not correct: %s", actualRecord.Schema())
+		assert.True(t, array.RecordEqual(expectedRecord, actualRecord), "Record not correct: %v", actualRecord)
+	}
+}
+
+// Tests "GetRecord()" keeps the rows of measurements with the same field name apart, with a cat.measurement
+// column only output for data of several measurements
+func testGetRecordMultipleMeasurementsFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewFluxCsvProcessor()
+		err := dp.Init(nil, nil, nil, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		expectedColumns := []string{"time", "measure.usage", "cat.measurement", "tags"}
+		if assert.Equal(t, len(expectedColumns), int(record.NumCols())) {
+			for i, name := range expectedColumns {
+				assert.Equal(t, name, record.ColumnName(i))
+			}
+			assert.Equal(t, "[1629159600 1629160800 1629159600 1629160800]", record.Column(0).String())
+			assert.Equal(t, "[12.5 13.5 64.25 65.75]", record.Column(1).String())
+			assert.Equal(t, `["cpu" "cpu" "mem" "mem"]`, record.Column(2).String())
+			assert.Equal(t, `[["web-1"] ["web-1"] ["web-1"] ["web-1"]]`, record.Column(3).String())
+		}
+	}
+}
+
+const subSecondData = `#group,false,false,false,false,true,true
+#datatype,string,long,dateTime:RFC3339Nano,double,string,string
+#default,_result,,,,,
+,result,table,_time,_value,_field,_measurement
+,,0,2021-08-17T00:20:00.123Z,99.5,usage_idle,cpu
+,,0,2021-08-17T00:20:00.456Z,98.5,usage_idle,cpu
+`
+
+// Tests "GetRecord()" keeps events of the same second apart with the time_precision param
+func testGetRecordTimePrecisionFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewFluxCsvProcessor()
+		err := dp.Init(map[string]string{"time_precision": "ms"}, nil, nil, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData([]byte(subSecondData))
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, arrow.FixedWidthTypes.Timestamp_ms, record.Schema().Field(0).Type)
+		assert.Equal(t, []arrow.Timestamp{1629159600123, 1629159600456}, record.Column(0).(*array.Timestamp).TimestampValues())
+		assert.Equal(t, "[99.5 98.5]", record.Column(1).String())
+
+		// Without it, the events are merged in the same row
+		dp = NewFluxCsvProcessor()
+		err = dp.Init(nil, nil, nil, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData([]byte(subSecondData))
+		assert.NoError(t, err)
+
+		record, err = dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, []int64{1629159600}, record.Column(0).(*array.Int64).Int64Values())
+	}
+}
@@ -1,11 +1,14 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,24 +23,37 @@ import (
 
 const (
 	JsonProcessorName string = "json"
+
+	formatJson   string = "json"
+	formatNdjson string = "ndjson"
+
+	onErrorFail string = "fail"
+	onErrorSkip string = "skip"
 )
 
 type JsonProcessor struct {
 	timeFormat   string
 	timeSelector string
-
-	timeBuilder     *array.Int64Builder
+	format       string
+	onError      string
+	skipped      int
+	recordsPath  *fieldPath
+	paths        map[string]*fieldPath
+
+	timePrecision   *conv.TimePrecision
+	timeZone        *time.Location
+	timeBuilder     array.Builder
 	idColNames      []string
 	idFields        map[string]arrow.Field
 	idBuilders      map[string]*array.StringBuilder
 	measureColNames []string
 	measureFields   map[string]arrow.Field
-	measureBuilders map[string]*array.Float64Builder
+	measureBuilders map[string]array.Builder
 	catColNames     []string
 	catFields       map[string]arrow.Field
 	catBuilders     map[string]*array.StringBuilder
 	tags            []string
-	tagBuilder      *array.ListBuilder
+	tagsBuilder     *conv.TagsBuilder
 
 	dataMutex    sync.RWMutex
 	data         [][]byte
@@ -58,30 +74,88 @@ func (p *JsonProcessor) Init(params map[string]string, identifiers map[string]st
 		p.timeSelector = "time"
 	}
 
+	timePrecision, err := conv.NewTimePrecision(params)
+	if err != nil {
+		return err
+	}
+	p.timePrecision = timePrecision
+	timeZone, err := conv.NewTimeZone(params)
+	if err != nil {
+		return err
+	}
+	p.timeZone = timeZone
+
+	p.format = params["format"]
+	if p.format != "" && p.format != formatJson && p.format != formatNdjson {
+		return fmt.Errorf("invalid format '%s': must be json or ndjson", p.format)
+	}
+
+	p.onError = onErrorFail
+	if onError, ok := params["on_error"]; ok && onError != "" {
+		if onError != onErrorFail && onError != onErrorSkip {
+			return fmt.Errorf("invalid on_error '%s': must be fail or skip", onError)
+		}
+		p.onError = onError
+	}
+
+	p.recordsPath = nil
+	if recordsPath, ok := params["records_path"]; ok && recordsPath != "" {
+		path, err := parsePath(recordsPath)
+		if err != nil {
+			return err
+		}
+		p.recordsPath = path
+	}
+
+	// JSON measurements are text, without an input type to keep
+	outputMeasurementType, err := conv.NewMeasurementType(params)
+	if err != nil {
+		return err
+	}
+	measurementType, err := outputMeasurementType.OutputType(arrow.BinaryTypes.String)
+	if err != nil {
+		return err
+	}
+
 	p.idFields = make(map[string]arrow.Field)
 	p.idBuilders = make(map[string]*array.StringBuilder)
 	p.measureFields = make(map[string]arrow.Field)
-	p.measureBuilders = make(map[string]*array.Float64Builder)
+	p.measureBuilders = make(map[string]array.Builder)
 	p.catFields = make(map[string]arrow.Field)
 	p.catBuilders = make(map[string]*array.StringBuilder)
 
 	for fieldName, colName := range identifiers {
 		p.idColNames = append(p.idColNames, colName)
-		p.idFields[colName] = arrow.Field{Name: fmt.Sprintf("id.%s", fieldName), Type: arrow.BinaryTypes.String}
+		p.idFields[colName] = arrow.Field{Name: conv.IdentifierPrefix + fieldName, Type: arrow.BinaryTypes.String}
 	}
 	sort.Strings(p.idColNames)
 	for fieldName, colName := range measurements {
 		p.measureColNames = append(p.measureColNames, colName)
-		p.measureFields[colName] = arrow.Field{Name: fmt.Sprintf("measure.%s", fieldName), Type: arrow.PrimitiveTypes.Float64}
+		p.measureFields[colName] = arrow.Field{Name: conv.MeasurementPrefix + fieldName, Type: measurementType}
 	}
 	sort.Strings(p.measureColNames)
 	for fieldName, colName := range categories {
 		p.catColNames = append(p.catColNames, colName)
-		p.catFields[colName] = arrow.Field{Name: fmt.Sprintf("cat.%s", fieldName), Type: arrow.BinaryTypes.String}
+		p.catFields[colName] = arrow.Field{Name: conv.CategoryPrefix + fieldName, Type: arrow.BinaryTypes.String}
 	}
 	sort.Strings(p.catColNames)
 	p.tags = tags
 
+	// Selectors may be nested paths
+	p.paths = make(map[string]*fieldPath)
+	selectors := []string{p.timeSelector}
+	selectors = append(selectors, p.idColNames...)
+	selectors = append(selectors, p.measureColNames...)
+	selectors = append(selectors, p.catColNames...)
+	selectors = append(selectors, p.tags...)
+	for _, selector := range selectors {
+		path, err := parsePath(selector)
+		if err != nil {
+			return err
+		}
+		p.paths[selector] = path
+	}
+
 	return nil
 }
 
@@ -119,55 +193,35 @@ func (p *JsonProcessor) GetRecord() (arrow.Record, error) {
 	// Builders creation
 	pool := memory.NewGoAllocator()
 
-	fields := []arrow.Field{{Name: "time", Type: arrow.PrimitiveTypes.Int64}}
+	fields := []arrow.Field{{Name: conv.TimeColumnName, Type: p.timePrecision.DataType()}}
 
-	p.timeBuilder = array.NewInt64Builder(pool)
+	p.timeBuilder = p.timePrecision.NewBuilder(pool)
 	defer p.timeBuilder.Release()
 	for _, colName := range p.idColNames {
 		p.idBuilders[colName] = array.NewStringBuilder(pool)
 		fields = append(fields, p.idFields[colName])
 	}
 	for _, colName := range p.measureColNames {
-		p.measureBuilders[colName] = array.NewFloat64Builder(pool)
+		p.measureBuilders[colName] = array.NewBuilder(pool, p.measureFields[colName].Type)
 		fields = append(fields, p.measureFields[colName])
 	}
 	for _, colName := range p.catColNames {
 		p.catBuilders[colName] = array.NewStringBuilder(pool)
 		fields = append(fields, p.catFields[colName])
 	}
-	p.tagBuilder = array.NewListBuilder(pool, arrow.BinaryTypes.String)
-	fields = append(fields, arrow.Field{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)})
-	defer p.tagBuilder.Release()
+	p.tagsBuilder = conv.NewTagsBuilder(pool)
+	fields = append(fields, p.tagsBuilder.Field())
+	defer p.tagsBuilder.Release()
 
+	p.skipped = 0
 	for _, data := range p.data {
-		firstChar := string(data[:1])
-		if firstChar == "{" {
-			var item map[string]json.RawMessage
-
-			err := json.Unmarshal(data, &item)
-			if err != nil {
-				return nil, err
-			}
-			err = p.newObservationFromJson(0, item)
-			if err != nil {
-				return nil, fmt.Errorf("error unmarshaling item: %s", err.Error())
-			}
-		} else {
-			var items []map[string]json.RawMessage
-
-			err := json.Unmarshal(data, &items)
-			if err != nil {
-				return nil, err
-			}
-
-			for index, item := range items {
-				err = p.newObservationFromJson(index, item)
-				if err != nil {
-					return nil, fmt.Errorf("error unmarshaling item %d: %s", index, err.Error())
-				}
-			}
+		if err := p.processData(data); err != nil {
+			return nil, err
 		}
 	}
+	if p.skipped > 0 {
+		log.Printf("json processor skipped %d invalid items\n", p.skipped)
+	}
 
 	p.data = nil
 
@@ -181,7 +235,7 @@ func (p *JsonProcessor) GetRecord() (arrow.Record, error) {
 	for _, colName := range p.catColNames {
 		cols = append(cols, p.catBuilders[colName].NewArray())
 	}
-	cols = append(cols, p.tagBuilder.NewArray())
+	cols = append(cols, p.tagsBuilder.NewArray())
 
 	record := array.NewRecord(arrow.NewSchema(fields, nil), cols, int64(cols[0].Len()))
 	record.Retain()
@@ -200,90 +254,287 @@ func (p *JsonProcessor) GetRecord() (arrow.Record, error) {
 	return record, nil
 }
 
-func (p *JsonProcessor) newObservationFromJson(index int, item map[string]json.RawMessage) error {
-	timeEntry, ok := item[p.timeSelector]
-	if !ok {
-		return fmt.Errorf("time field with selector '%s' does not exist in the message", p.timeSelector)
+// A parsed item, appended to the builders only once the whole item is valid
+type observation struct {
+	time     time.Time
+	ids      []*string
+	measures []*conv.Number
+	cats     []*string
+	tags     []string
+}
+
+// Processes a single object, an array of objects or newline-delimited objects
+func (p *JsonProcessor) processData(data []byte) error {
+	format := p.format
+	if format == "" {
+		format = detectFormat(data)
+	}
+
+	if format == formatNdjson {
+		return p.processNdjson(data)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	records, isArray, err := p.getRecords(data)
+	if err != nil {
+		return err
+	}
+
+	for index, record := range records {
+		if err = p.newObservationFromJson(record); err != nil {
+			if isArray {
+				err = fmt.Errorf("error unmarshaling item %d: %s", index, err.Error())
+			} else {
+				err = fmt.Errorf("error unmarshaling item: %s", err.Error())
+			}
+			if err = p.handleError(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Processes one object per line. Empty lines are ignored.
+func (p *JsonProcessor) processNdjson(data []byte) error {
+	for index, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		records, isArray, err := p.getRecords(line)
+		if err != nil {
+			if err = p.handleError(fmt.Errorf("error unmarshaling line %d: %s", index+1, err.Error())); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for recordIndex, record := range records {
+			if err = p.newObservationFromJson(record); err != nil {
+				if isArray {
+					err = fmt.Errorf("error unmarshaling line %d item %d: %s", index+1, recordIndex, err.Error())
+				} else {
+					err = fmt.Errorf("error unmarshaling line %d: %s", index+1, err.Error())
+				}
+				if err = p.handleError(err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the records of the value, either the value itself or the array or object at records_path
+func (p *JsonProcessor) getRecords(data []byte) ([]json.RawMessage, bool, error) {
+	value := json.RawMessage(data)
+	if p.recordsPath != nil {
+		if !json.Valid(data) {
+			return nil, false, errors.New("invalid JSON")
+		}
+		records, ok, err := p.recordsPath.lookupValue(value)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok || isNull(records) {
+			return nil, false, fmt.Errorf("records_path '%s' does not exist in the message", p.recordsPath.selector)
+		}
+		value = bytes.TrimSpace(records)
+	}
+
+	if len(value) > 0 && value[0] == '[' {
+		var records []json.RawMessage
+		if err := json.Unmarshal(value, &records); err != nil {
+			return nil, false, err
+		}
+		return records, true, nil
+	}
+
+	if p.recordsPath != nil && (len(value) == 0 || value[0] != '{') {
+		return nil, false, fmt.Errorf("records_path '%s' is not an array or object", p.recordsPath.selector)
+	}
+
+	return []json.RawMessage{value}, false, nil
+}
+
+// Returns the error unless invalid items are skipped
+func (p *JsonProcessor) handleError(err error) error {
+	if p.onError == onErrorSkip {
+		log.Printf("json processor skipping invalid data: %s\n", err.Error())
+		p.skipped++
+		return nil
+	}
+	return err
+}
+
+// Data starting with an object followed by more data after it is newline-delimited JSON
+func detectFormat(data []byte) string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return formatJson
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		// Invalid first line, reported with its line number
+		if bytes.Contains(data, []byte("\n")) {
+			return formatNdjson
+		}
+		return formatJson
+	}
+	if decoder.InputOffset() < int64(len(data)) {
+		return formatNdjson
+	}
+	return formatJson
+}
+
+func (p *JsonProcessor) newObservationFromJson(data json.RawMessage) error {
+	var item map[string]json.RawMessage
+	err := json.Unmarshal(data, &item)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return errors.New("item is not an object")
 	}
 
-	timeValue, err := unmarshalTime(p.timeFormat, timeEntry)
+	o, err := p.parseObservation(item)
 	if err != nil {
 		return err
 	}
-	p.timeBuilder.Append(timeValue.Unix())
+
+	p.timePrecision.Append(p.timeBuilder, o.time)
+	for i, colName := range p.idColNames {
+		appendString(p.idBuilders[colName], o.ids[i])
+	}
+	for i, colName := range p.measureColNames {
+		if o.measures[i] == nil {
+			p.measureBuilders[colName].AppendNull()
+		} else {
+			conv.AppendNumber(p.measureBuilders[colName], *o.measures[i])
+		}
+	}
+	for i, colName := range p.catColNames {
+		appendString(p.catBuilders[colName], o.cats[i])
+	}
+
+	p.tagsBuilder.NewRow()
+	for _, tag := range o.tags {
+		p.tagsBuilder.Append(tag)
+	}
+
+	return nil
+}
+
+func appendString(builder *array.StringBuilder, value *string) {
+	if value == nil {
+		builder.AppendNull()
+	} else {
+		builder.Append(*value)
+	}
+}
+
+func (p *JsonProcessor) parseObservation(item map[string]json.RawMessage) (*observation, error) {
+	timeEntry, ok, err := p.lookup(item, p.timeSelector)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("time field with selector '%s' does not exist in the message", p.timeSelector)
+	}
+
+	timeValue, err := unmarshalTime(p.timeFormat, p.timePrecision, p.timeZone, timeEntry)
+	if err != nil {
+		return nil, err
+	}
+	o := &observation{time: *timeValue}
 
 	for _, colName := range p.idColNames {
-		if val, ok := item[colName]; ok { // Field name starts with "id."
+		val, ok, err := p.lookup(item, colName)
+		if err != nil {
+			return nil, err
+		}
+		if ok { // Field name starts with "id."
 			var jsonVal interface{}
 			err = json.Unmarshal(val, &jsonVal)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			if stringValue, ok := jsonVal.(string); ok {
-				p.idBuilders[colName].Append(stringValue)
+				o.ids = append(o.ids, &stringValue)
 			} else if numValue, ok := jsonVal.(float64); ok {
-				p.idBuilders[colName].Append(strconv.FormatFloat(numValue, 'f', -1, 64))
+				stringValue := strconv.FormatFloat(numValue, 'f', -1, 64)
+				o.ids = append(o.ids, &stringValue)
 			} else {
-				return fmt.Errorf("identifier field '%s' is not a a valid id (string or number)", colName)
+				return nil, fmt.Errorf("identifier field '%s' is not a a valid id (string or number)", colName)
 			}
 		} else {
-			p.idBuilders[colName].AppendNull()
+			o.ids = append(o.ids, nil)
 		}
 	}
 
 	for _, colName := range p.measureColNames {
-		if val, ok := item[colName]; ok { // Field name starts with "measure."
-			var numValue float64
-			err = json.Unmarshal(val, &numValue)
+		val, ok, err := p.lookup(item, colName)
+		if err != nil {
+			return nil, err
+		}
+		if ok { // Field name starts with "measure."
+			numValue, err := unmarshalMeasurement(val, p.measureFields[colName].Type)
 			if err != nil {
-				var str string
-				strErr := json.Unmarshal(val, &str)
-				if strErr != nil {
-					return err
-				}
-				numValue, err = conv.ParseMeasurement(str)
-				if err != nil {
-					return err
-				}
+				return nil, err
 			}
-			p.measureBuilders[colName].Append(numValue)
+			o.measures = append(o.measures, numValue)
 		} else {
-			p.measureBuilders[colName].AppendNull()
+			o.measures = append(o.measures, nil)
 		}
 	}
 
 	for _, colName := range p.catColNames {
-		if val, ok := item[colName]; ok { // Field name starts with "cat."
+		val, ok, err := p.lookup(item, colName)
+		if err != nil {
+			return nil, err
+		}
+		if ok { // Field name starts with "cat."
 			stringValue, err := unmarshalString(val)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			p.catBuilders[colName].Append(stringValue)
+			o.cats = append(o.cats, &stringValue)
 		} else {
-			p.catBuilders[colName].AppendNull()
+			o.cats = append(o.cats, nil)
 		}
 	}
 
-	p.tagBuilder.Append(true)
 	tagAdded := make(map[string]bool)
-	tagValueBuilder := p.tagBuilder.ValueBuilder().(*array.StringBuilder)
-
 	for _, colName := range p.tags {
-		tagEntry, ok := item[colName]
+		tagEntry, ok, err := p.lookup(item, colName)
+		if err != nil {
+			return nil, err
+		}
 		if !ok {
 			continue
 		}
 
-		if colName == "_tags" || colName == "tags" {
+		// Tags fields and any other arrays, such as nested tag lists, hold lists of tags
+		if colName == "_tags" || colName == "tags" || bytes.HasPrefix(bytes.TrimSpace(tagEntry), []byte("[")) {
 			var stringList []string
 			err = json.Unmarshal(tagEntry, &stringList)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			for _, stringValue := range stringList {
 				// Avoid duplicate entries
 				if _, ok := tagAdded[stringValue]; !ok {
-					tagValueBuilder.Append(stringValue)
+					o.tags = append(o.tags, stringValue)
 					tagAdded[stringValue] = true
 				}
 			}
@@ -293,18 +544,28 @@ func (p *JsonProcessor) newObservationFromJson(index int, item map[string]json.R
 		var stringValue string
 		err = json.Unmarshal(tagEntry, &stringValue)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if _, ok := tagAdded[stringValue]; !ok {
-			tagValueBuilder.Append(stringValue)
+			o.tags = append(o.tags, stringValue)
 			tagAdded[stringValue] = true
 		}
 	}
 
-	return nil
+	return o, nil
 }
 
-func unmarshalTime(timeFormat string, data []byte) (*time.Time, error) {
+// Returns the value of the field selected by the top-level key or nested path selector
+func (p *JsonProcessor) lookup(item map[string]json.RawMessage, selector string) (json.RawMessage, bool, error) {
+	path, ok := p.paths[selector]
+	if !ok {
+		value, ok := item[selector]
+		return value, ok, nil
+	}
+	return path.lookup(item)
+}
+
+func unmarshalTime(timeFormat string, timePrecision *conv.TimePrecision, timeZone *time.Location, data []byte) (*time.Time, error) {
 	st := spice_time.Time{}
 	err := st.UnmarshalJSON(data)
 	if err != nil {
@@ -312,13 +573,13 @@ func unmarshalTime(timeFormat string, data []byte) (*time.Time, error) {
 	}
 
 	if st.Integer != nil {
-		t := time.Unix(*st.Integer, 0)
+		t := timePrecision.ToTime(*st.Integer)
 		return &t, nil
 	}
 
 	if st.String != nil {
 		var t time.Time
-		t, err = spice_time.ParseTime(*st.String, timeFormat)
+		t, err = timePrecision.ParseTime(*st.String, timeFormat, timeZone)
 		if err != nil {
 			return nil, fmt.Errorf("time format is invalid: %s", *st.String)
 		}
@@ -328,6 +589,31 @@ func unmarshalTime(timeFormat string, data []byte) (*time.Time, error) {
 	return nil, errors.New("did not include a time component")
 }
 
+// Parses a number, a numeric string or a boolean as 1/0, converted to the measurement type
+func unmarshalMeasurement(val json.RawMessage, dataType arrow.DataType) (*conv.Number, error) {
+	str := string(bytes.TrimSpace(val))
+	switch {
+	case str == "null":
+		// Nulls are 0, like when unmarshaled into a float
+		str = "0"
+	case strings.HasPrefix(str, "\""):
+		if err := json.Unmarshal(val, &str); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(str, "{") || strings.HasPrefix(str, "["):
+		return nil, fmt.Errorf("value %s is not a number", str)
+	}
+
+	n, err := conv.ParseNumber(str)
+	if err != nil {
+		return nil, err
+	}
+	if n, err = n.Convert(dataType); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
 func unmarshalString(val json.RawMessage) (string, error) {
 	var str string
 	err := json.Unmarshal(val, &str)
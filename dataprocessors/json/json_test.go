@@ -39,6 +39,35 @@ func TestJson(t *testing.T) {
 	t.Run("GetRecord() called before Init()", testGetRecordNoInitFunc())
 	t.Run("GetRecord() called twice", testGetRecordTwiceFunc(puppies))
 	t.Run("GetRecord() updated with same data", testGetRecordSameDataFunc(puppies))
+	t.Run("GetRecord() - newline-delimited", testGetRecordNdjsonFunc(puppies))
+	t.Run("GetRecord() - newline-delimited invalid line", testGetRecordNdjsonInvalidLineFunc())
+	t.Run("GetRecord() - newline-delimited skip invalid lines", testGetRecordNdjsonSkipFunc())
+	t.Run("GetRecord() - array skip invalid items", testGetRecordSkipInvalidItemsFunc(tweets))
+	t.Run("GetRecord() - nested selectors", testGetRecordNestedFunc())
+	t.Run("GetRecord() - records_path not found", testGetRecordRecordsPathNotFoundFunc())
+	t.Run("GetRecord() - time precision", testGetRecordTimePrecisionFunc())
+	t.Run("GetRecord() - time zone", testGetRecordTimeZoneFunc())
+	t.Run("GetRecord() - measurement type", testGetRecordMeasurementTypeFunc())
+}
+
+// Tests "Init()" with invalid params
+func TestJsonInitInvalidParams(t *testing.T) {
+	for _, params := range []map[string]string{
+		{"format": "xml"},
+		{"on_error": "ignore"},
+	} {
+		dp := NewJsonProcessor()
+		assert.Error(t, dp.Init(params, nil, nil, nil, nil), "params %v", params)
+	}
+
+	dp := NewJsonProcessor()
+	assert.Error(t, dp.Init(map[string]string{"records_path": "data["}, nil, nil, nil, nil))
+
+	dp = NewJsonProcessor()
+	assert.Error(t, dp.Init(nil, nil, map[string]string{"price": "data.price[usd]"}, nil, nil))
+
+	dp = NewJsonProcessor()
+	assert.NoError(t, dp.Init(map[string]string{"format": "ndjson", "on_error": "skip"}, nil, nil, nil, nil))
 }
 
 // Tests "Init()"
@@ -409,6 +438,375 @@ func testGetRecordSameDataFunc(data []byte) func(*testing.T) {
 	}
 }
 
+// Tests "GetRecord()" with the items of the array, one per line, produces the same record as the array
+func testGetRecordNdjsonFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		var items []json.RawMessage
+		err := json.Unmarshal(data, &items)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		ndjson := new(bytes.Buffer)
+		for i, item := range items {
+			if err := json.Compact(ndjson, item); err != nil {
+				t.Fatal(err.Error())
+			}
+			// Windows line endings and blank lines are ignored
+			if i%2 == 0 {
+				ndjson.WriteString("\r\n\n")
+			} else {
+				ndjson.WriteString("\n")
+			}
+		}
+
+		measurements := map[string]string{
+			"ave_weight": "ave_weight",
+			"population": "population",
+		}
+		categories := map[string]string{
+			"city": "city",
+		}
+		tags := []string{
+			"tags",
+		}
+
+		for _, params := range []map[string]string{nil, {"format": "ndjson"}} {
+			arrayProcessor := NewJsonProcessor()
+			err = arrayProcessor.Init(nil, nil, measurements, categories, tags)
+			assert.NoError(t, err)
+			_, err = arrayProcessor.OnData(data)
+			assert.NoError(t, err)
+			expectedRecord, err := arrayProcessor.GetRecord()
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			dp := NewJsonProcessor()
+			err = dp.Init(params, nil, measurements, categories, tags)
+			assert.NoError(t, err)
+
+			_, err = dp.OnData(ndjson.Bytes())
+			assert.NoError(t, err)
+
+			actualRecord, err := dp.GetRecord()
+			if !assert.NoError(t, err) || !assert.NotNil(t, actualRecord) {
+				return
+			}
+
+			assert.Equal(t, int64(len(items)), actualRecord.NumRows())
+			assert.True(t, array.RecordEqual(expectedRecord, actualRecord), "Record not correct: %v", actualRecord)
+
+			expectedRecord.Release()
+			actualRecord.Release()
+		}
+	}
+}
+
+const ndjsonWithInvalidLine = `{"time": 1605312000, "price": 16339.56, "tags": ["a"]}
+
+{"time": 1605313800, "price": "not a number"}
+{"time": 1605315600, "price": 16100.5, "tags": ["b"]}
+{"time": 1605317400, "price": 16150
+{"time": 1605319200, "price": 16200.25}
+`
+
+// Tests "GetRecord()" fails with the line number of an invalid line
+func testGetRecordNdjsonInvalidLineFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewJsonProcessor()
+		err := dp.Init(nil, nil, map[string]string{"price": "price"}, nil, []string{"tags"})
+		assert.NoError(t, err)
+
+		_, err = dp.OnData([]byte(ndjsonWithInvalidLine))
+		assert.NoError(t, err)
+
+		_, err = dp.GetRecord()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "error unmarshaling line 3:")
+		}
+	}
+}
+
+// Tests "GetRecord()" skips invalid lines with on_error: skip
+func testGetRecordNdjsonSkipFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dp := NewJsonProcessor()
+		err := dp.Init(map[string]string{"on_error": "skip"}, nil, map[string]string{"price": "price"}, nil, []string{"tags"})
+		assert.NoError(t, err)
+
+		_, err = dp.OnData([]byte(ndjsonWithInvalidLine))
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, int64(3), record.NumRows())
+		assert.Equal(t, []int64{1605312000, 1605315600, 1605319200}, record.Column(0).(*array.Int64).Int64Values())
+		assert.Equal(t, "[16339.56 16100.5 16200.25]", record.Column(1).String())
+		assert.Equal(t, `[["a"] ["b"] []]`, record.Column(2).String())
+	}
+}
+
+// Tests "GetRecord()" skips invalid items of an array with on_error: skip
+func testGetRecordSkipInvalidItemsFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		measurements := map[string]string{
+			"retweet_count": "retweet_count",
+			"reply_count":   "reply_count",
+		}
+		categories := map[string]string{
+			"favorited": "favorited",
+		}
+
+		var items []json.RawMessage
+		err := json.Unmarshal(data, &items)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		dp := NewJsonProcessor()
+		err = dp.Init(map[string]string{"on_error": "skip"}, nil, measurements, categories, []string{"tags"})
+		assert.NoError(t, err)
+
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Less(t, record.NumRows(), int64(len(items)))
+		for _, column := range record.Columns() {
+			assert.Equal(t, int(record.NumRows()), column.Len())
+		}
+	}
+}
+
+const nestedEnvelope = `{
+	"status": "ok",
+	"result": {
+		"records": [
+			{"meta": {"ts": 1605312000, "symbol": "BTC-USD", "tags": ["a", "b"]}, "data": {"price": {"usd": 16339.56}}, "metrics": [{"value": 12}], "side": {"name": "buy"}},
+			{"meta": {"ts": 1605313800, "symbol": "ETH-USD", "tags": []}, "data": {"price": {"usd": "460.1"}}, "metrics": [], "side": {"name": "sell"}},
+			{"meta": {"ts": 1605315600, "symbol": "BTC-USD"}, "data": {"price": null}, "metrics": [{"value": 7}, {"value": 8}]}
+		]
+	}
+}`
+
+// Tests "GetRecord()" with nested selectors and records in an envelope object
+func testGetRecordNestedFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		params := map[string]string{
+			"time_selector": "meta.ts",
+			"records_path":  "$.result.records",
+		}
+		identifiers := map[string]string{
+			"symbol": "meta.symbol",
+		}
+		measurements := map[string]string{
+			"price":  "data.price.usd",
+			"volume": "$.metrics[0].value",
+		}
+		categories := map[string]string{
+			"side": "side['name']",
+		}
+
+		for _, data := range []string{nestedEnvelope, compactJson(t, nestedEnvelope) + "\n" + compactJson(t, nestedEnvelope)} {
+			dp := NewJsonProcessor()
+			err := dp.Init(params, identifiers, measurements, categories, []string{"meta.tags"})
+			assert.NoError(t, err)
+
+			_, err = dp.OnData([]byte(data))
+			assert.NoError(t, err)
+
+			record, err := dp.GetRecord()
+			if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+				return
+			}
+
+			repeat := int(record.NumRows()) / 3
+			assert.Equal(t, 3*repeat, int(record.NumRows()))
+
+			expectedColumns := map[string]string{
+				"time":           "[1605312000 1605313800 1605315600]",
+				"id.symbol":      `["BTC-USD" "ETH-USD" "BTC-USD"]`,
+				"measure.price":  "[16339.56 460.1 (null)]",
+				"measure.volume": "[12 (null) 7]",
+				"cat.side":       `["buy" "sell" (null)]`,
+				"tags":           `[["a" "b"] [] []]`,
+			}
+			for name, expected := range expectedColumns {
+				indices := record.Schema().FieldIndices(name)
+				if !assert.Len(t, indices, 1, "column %s", name) {
+					continue
+				}
+				column := record.Column(indices[0])
+				slice := array.NewSlice(column, 0, 3)
+				assert.Equal(t, expected, slice.String(), "column %s", name)
+				slice.Release()
+			}
+
+			record.Release()
+		}
+	}
+}
+
+// Tests "GetRecord()" fails when records_path doesn't select an array or object
+func testGetRecordRecordsPathNotFoundFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		for _, recordsPath := range []string{"result.missing", "status"} {
+			dp := NewJsonProcessor()
+			err := dp.Init(map[string]string{"records_path": recordsPath}, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			_, err = dp.OnData([]byte(nestedEnvelope))
+			assert.NoError(t, err)
+
+			_, err = dp.GetRecord()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), recordsPath)
+			}
+		}
+	}
+}
+
+// Tests "GetRecord()" with the time_precision param
+func testGetRecordTimePrecisionFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte(`[
+			{"time": 1605312000123, "price": 16339.56},
+			{"time": "1605312000456", "price": 16339.57},
+			{"time": "2020-11-14T00:00:00.789Z", "price": 16339.58}
+		]`)
+
+		dp := NewJsonProcessor()
+		err := dp.Init(map[string]string{"time_precision": "ms"}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, arrow.FixedWidthTypes.Timestamp_ms, record.Schema().Field(0).Type)
+		assert.Equal(t, []arrow.Timestamp{1605312000123, 1605312000456, 1605312000789}, record.Column(0).(*array.Timestamp).TimestampValues())
+
+		dp = NewJsonProcessor()
+		err = dp.Init(map[string]string{"time_precision": "ps"}, nil, nil, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with the time_zone param, across the DST transition
+func testGetRecordTimeZoneFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		// Explicit offsets are respected
+		dp := NewJsonProcessor()
+		err := dp.Init(map[string]string{
+			"time_format": "2006-01-02 15:04:05 -0700",
+			"time_zone":   "Europe/London",
+		}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData([]byte(`[{"time": "2021-03-15 09:30:00 -0400", "price": 1}]`))
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		assert.Equal(t, []int64{1615815000}, record.Column(0).(*array.Int64).Int64Values())
+		record.Release()
+
+		dp = NewJsonProcessor()
+		err = dp.Init(map[string]string{
+			"time_format": "2006-01-02 15:04:05",
+			"time_zone":   "America/New_York",
+		}, nil, map[string]string{"price": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = dp.OnData([]byte(`[
+			{"time": "2021-03-13 09:30:00", "price": 1},
+			{"time": "2021-03-15 09:30:00", "price": 2},
+			{"time": 1615815000, "price": 3}
+		]`))
+		assert.NoError(t, err)
+
+		record, err = dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		// 14:30 UTC in EST, 13:30 UTC in EDT, and epoch times are not affected
+		assert.Equal(t, []int64{1615645800, 1615815000, 1615815000}, record.Column(0).(*array.Int64).Int64Values())
+
+		dp = NewJsonProcessor()
+		err = dp.Init(map[string]string{"time_zone": "EST5EDT6"}, nil, nil, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+// Tests "GetRecord()" with the measurement_type param
+func testGetRecordMeasurementTypeFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		data := []byte(`[
+			{"time": 1605312000, "volume": 9007199254740993, "active": true},
+			{"time": 1605313800, "volume": "0x10", "active": false},
+			{"time": 1605315600, "active": true}
+		]`)
+		measurements := map[string]string{"volume": "volume", "active": "active"}
+
+		dp := NewJsonProcessor()
+		err := dp.Init(map[string]string{"measurement_type": "uint64"}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		// Integers are exact, and booleans are 1/0
+		assert.Equal(t, "measure.active", record.Schema().Field(1).Name)
+		assert.Equal(t, arrow.PrimitiveTypes.Uint64, record.Schema().Field(1).Type)
+		assert.Equal(t, "[1 0 1]", record.Column(1).String())
+		assert.Equal(t, "[9007199254740993 16 (null)]", record.Column(2).String())
+
+		// Values overflowing the type are invalid
+		dp = NewJsonProcessor()
+		err = dp.Init(map[string]string{"measurement_type": "int8"}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+		_, err = dp.GetRecord()
+		assert.EqualError(t, err, "error unmarshaling item 0: 9007199254740993 overflows int8")
+
+		err = dp.Init(map[string]string{"measurement_type": "double"}, nil, measurements, nil, nil)
+		assert.Error(t, err)
+	}
+}
+
+func compactJson(t *testing.T, data string) string {
+	buffer := new(bytes.Buffer)
+	if err := json.Compact(buffer, []byte(data)); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buffer.String()
+}
+
 func TestUnmarshalString(t *testing.T) {
 	t.Run("string value", testUnmarshalStringFunc("test-string", "test-string"))
 	t.Run("int value", testUnmarshalStringFunc(int(123), "123"))
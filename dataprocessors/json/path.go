@@ -0,0 +1,130 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A step of a field path, either an object key or an array index
+type pathStep struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// A parsed field selector, such as "data.price.usd", "metrics[0].value" or the JSONPath "$.metrics[0]['value']"
+type fieldPath struct {
+	selector string
+	steps    []pathStep
+}
+
+func parsePath(selector string) (*fieldPath, error) {
+	path := &fieldPath{selector: selector}
+
+	s := strings.TrimPrefix(selector, "$")
+	expectKey := len(s) == len(selector)
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			expectKey = true
+			continue
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid selector '%s': missing ']'", selector)
+			}
+			inner := s[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path.steps = append(path.steps, pathStep{key: inner[1 : len(inner)-1], isKey: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid selector '%s': '%s' is not an array index", selector, inner)
+				}
+				path.steps = append(path.steps, pathStep{index: index})
+			}
+			s = s[end+1:]
+			expectKey = false
+			continue
+		}
+
+		if !expectKey {
+			return nil, fmt.Errorf("invalid selector '%s': expected '.' or '[' before '%s'", selector, s)
+		}
+		end := strings.IndexAny(s, ".[")
+		if end < 0 {
+			end = len(s)
+		}
+		path.steps = append(path.steps, pathStep{key: s[:end], isKey: true})
+		s = s[end:]
+		expectKey = false
+	}
+
+	if len(path.steps) == 0 && selector != "$" {
+		return nil, fmt.Errorf("invalid selector '%s'", selector)
+	}
+
+	return path, nil
+}
+
+// Returns the value at the path and whether it exists. A top-level key equal to the whole selector
+// takes precedence, so flat fields with dots in their names are still selected as before.
+func (f *fieldPath) lookup(item map[string]json.RawMessage) (json.RawMessage, bool, error) {
+	if value, ok := item[f.selector]; ok {
+		return value, true, nil
+	}
+	if len(f.steps) == 0 {
+		value, err := json.Marshal(item)
+		return value, err == nil, err
+	}
+
+	value, ok := item[f.steps[0].key]
+	if !f.steps[0].isKey || !ok {
+		return nil, false, nil
+	}
+	return f.walk(value, f.steps[1:])
+}
+
+// Returns the value at the path from the root value
+func (f *fieldPath) lookupValue(root json.RawMessage) (json.RawMessage, bool, error) {
+	return f.walk(root, f.steps)
+}
+
+func (f *fieldPath) walk(value json.RawMessage, steps []pathStep) (json.RawMessage, bool, error) {
+	for _, step := range steps {
+		if isNull(value) {
+			return nil, false, nil
+		}
+
+		if step.isKey {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(value, &object); err != nil {
+				return nil, false, fmt.Errorf("selector '%s': '%s' is not in an object", f.selector, step.key)
+			}
+			var ok bool
+			if value, ok = object[step.key]; !ok {
+				return nil, false, nil
+			}
+			continue
+		}
+
+		var list []json.RawMessage
+		if err := json.Unmarshal(value, &list); err != nil {
+			return nil, false, fmt.Errorf("selector '%s': [%d] is not in an array", f.selector, step.index)
+		}
+		if step.index >= len(list) {
+			return nil, false, nil
+		}
+		value = list[step.index]
+	}
+
+	return value, true, nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return string(bytes.TrimSpace(value)) == "null"
+}
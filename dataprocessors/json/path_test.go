@@ -0,0 +1,88 @@
+package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	key := func(k string) pathStep { return pathStep{key: k, isKey: true} }
+	index := func(i int) pathStep { return pathStep{index: i} }
+
+	valid := map[string][]pathStep{
+		"price":                  {key("price")},
+		"data.price.usd":         {key("data"), key("price"), key("usd")},
+		"metrics[0].value":       {key("metrics"), index(0), key("value")},
+		"$.metrics[12]['value']": {key("metrics"), index(12), key("value")},
+		`$["a.b"][1]`:            {key("a.b"), index(1)},
+		"$[0].data":              {index(0), key("data")},
+		"$":                      nil,
+	}
+	for selector, expected := range valid {
+		path, err := parsePath(selector)
+		if assert.NoError(t, err, selector) {
+			assert.Equal(t, expected, path.steps, selector)
+		}
+	}
+
+	for _, selector := range []string{"", "data[", "data[x]", "data[-1]", "data[0]value", "$value"} {
+		_, err := parsePath(selector)
+		assert.Error(t, err, selector)
+	}
+}
+
+func TestPathLookup(t *testing.T) {
+	var item map[string]json.RawMessage
+	err := json.Unmarshal([]byte(`{
+		"data": {"price": {"usd": 16339.56}, "empty": null},
+		"metrics": [{"value": 1}, {"value": 2}],
+		"id.symbol": "BTC-USD",
+		"id": {"symbol": "ETH-USD"}
+	}`), &item)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	found := map[string]string{
+		"data.price.usd":      "16339.56",
+		"metrics[1].value":    "2",
+		"$.metrics[0].value":  "1",
+		"$['data']['price']":  `{"usd": 16339.56}`,
+		"data.empty":          "null",
+		"id.symbol":           `"BTC-USD"`, // The top-level key takes precedence
+		"$.id.symbol":         `"ETH-USD"`,
+		"$['id']['symbol']":   `"ETH-USD"`,
+		"metrics[0]['value']": "1",
+	}
+	for selector, expected := range found {
+		path, err := parsePath(selector)
+		if !assert.NoError(t, err, selector) {
+			continue
+		}
+		value, ok, err := path.lookup(item)
+		assert.NoError(t, err, selector)
+		assert.True(t, ok, selector)
+		assert.Equal(t, expected, string(value), selector)
+	}
+
+	for _, selector := range []string{"missing", "data.missing", "data.empty.value", "metrics[2].value"} {
+		path, err := parsePath(selector)
+		if !assert.NoError(t, err, selector) {
+			continue
+		}
+		_, ok, err := path.lookup(item)
+		assert.NoError(t, err, selector)
+		assert.False(t, ok, selector)
+	}
+
+	for _, selector := range []string{"data[0]", "metrics.value", "data.price.usd.value"} {
+		path, err := parsePath(selector)
+		if !assert.NoError(t, err, selector) {
+			continue
+		}
+		_, _, err = path.lookup(item)
+		assert.Error(t, err, selector)
+	}
+}
@@ -0,0 +1,211 @@
+package parquet
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
+	"github.com/spiceai/spiceai/pkg/util"
+)
+
+const (
+	ParquetProcessorName string = "parquet"
+
+	defaultBatchSize = 64 * 1024
+)
+
+type ParquetProcessor struct {
+	timeFormat      string
+	timeSelector    string
+	timePrecision   *conv.TimePrecision
+	measurementType *conv.MeasurementType
+	batchSize       int64
+
+	identifiers  map[string]string
+	measurements map[string]string
+	categories   map[string]string
+	tags         []string
+
+	dataMutex sync.RWMutex
+	data      []byte
+	dataHash  []byte
+}
+
+// An output column and the input column it is read from
+type outputColumn struct {
+	name      string
+	inputName string
+	dataType  arrow.DataType
+	// Builder of the current batch
+	builder array.Builder
+}
+
+func NewParquetProcessor() *ParquetProcessor {
+	return &ParquetProcessor{}
+}
+
+func (p *ParquetProcessor) Init(params map[string]string, identifiers map[string]string, measurements map[string]string, categories map[string]string, tags []string) error {
+	if format, ok := params["time_format"]; ok {
+		p.timeFormat = format
+	}
+	if selector, ok := params["time_selector"]; ok && selector != "" {
+		p.timeSelector = selector
+	} else {
+		p.timeSelector = "time"
+	}
+	timePrecision, err := conv.NewTimePrecision(params)
+	if err != nil {
+		return err
+	}
+	p.timePrecision = timePrecision
+	measurementType, err := conv.NewMeasurementType(params)
+	if err != nil {
+		return err
+	}
+	p.measurementType = measurementType
+
+	p.batchSize = defaultBatchSize
+	if batchSize, ok := params["batch_size"]; ok {
+		bs, err := strconv.ParseInt(batchSize, 10, 64)
+		if err != nil || bs < 1 {
+			return fmt.Errorf("invalid batch_size '%s': must be an integer >= 1", batchSize)
+		}
+		p.batchSize = bs
+	}
+
+	p.identifiers = identifiers
+	p.measurements = measurements
+	p.categories = categories
+	p.tags = tags
+
+	return nil
+}
+
+func (p *ParquetProcessor) OnData(data []byte) ([]byte, error) {
+	p.dataMutex.Lock()
+	defer p.dataMutex.Unlock()
+
+	newDataHash, err := util.ComputeNewHash(p.data, p.dataHash, data)
+	if err != nil {
+		return nil, fmt.Errorf("error computing new data hash in parquet processor: %w", err)
+	}
+
+	if newDataHash != nil {
+		// Only update data if new
+		p.data = data
+		p.dataHash = newDataHash
+	}
+
+	return data, nil
+}
+
+// GetRecord returns a record of all the rows of the new data, read batch_size rows at a time. The returned
+// record must be released. Use GetRecords to read the data in records of up to batch_size rows.
+func (p *ParquetProcessor) GetRecord() (arrow.Record, error) {
+	p.dataMutex.Lock()
+	defer p.dataMutex.Unlock()
+
+	if len(p.data) == 0 {
+		return nil, nil
+	}
+
+	reader, err := p.newRecordReader(p.data)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+	p.data = nil
+
+	return reader.readAll()
+}
+
+// GetRecords returns a reader of the records of the new data, of up to batch_size rows each. It returns nil
+// without new data. The reader must be released.
+func (p *ParquetProcessor) GetRecords() (*RecordReader, error) {
+	p.dataMutex.Lock()
+	defer p.dataMutex.Unlock()
+
+	if len(p.data) == 0 {
+		return nil, nil
+	}
+
+	reader, err := p.newRecordReader(p.data)
+	if err != nil {
+		return nil, err
+	}
+	p.data = nil
+
+	return reader, nil
+}
+
+// Appends the times of the column. Numeric epoch times are in the unit of the precision.
+func (p *ParquetProcessor) appendTimes(builder array.Builder, column arrow.Array) error {
+	for i := 0; i < column.Len(); i++ {
+		if column.IsNull(i) {
+			return fmt.Errorf("time column '%s' has a null value", p.timeSelector)
+		}
+	}
+
+	if !conv.IsTimeType(column.DataType()) {
+		return fmt.Errorf("time column '%s' type mistmach: %s is not supported", p.timeSelector, column.DataType())
+	}
+	if err := p.timePrecision.AppendColumn(builder, column, p.timeFormat, nil); err != nil {
+		return fmt.Errorf("invalid time in column '%s': %w", p.timeSelector, err)
+	}
+
+	return nil
+}
+
+func appendValues(column *outputColumn, values arrow.Array) error {
+	switch builder := column.builder.(type) {
+	case *array.StringBuilder:
+		c, ok := values.(*array.String)
+		if !ok {
+			return fmt.Errorf("column '%s' type mistmach: %s is not a string", column.inputName, values.DataType())
+		}
+		for i := 0; i < c.Len(); i++ {
+			if c.IsNull(i) {
+				builder.AppendNull()
+			} else {
+				builder.Append(c.Value(i))
+			}
+		}
+	default:
+		for i := 0; i < values.Len(); i++ {
+			if values.IsNull(i) {
+				builder.AppendNull()
+				continue
+			}
+			value, err := conv.NumberAt(values, i)
+			if err != nil {
+				return fmt.Errorf("measurement column '%s' type mistmach: %w", column.inputName, err)
+			}
+			if value, err = value.Convert(builder.Type()); err != nil {
+				return fmt.Errorf("measurement column '%s': %w", column.inputName, err)
+			}
+			conv.AppendNumber(builder, value)
+		}
+	}
+	return nil
+}
+
+// Appends the tags of each row. String tag columns hold space-delimited tags like in the csv processor.
+func appendTags(builder *conv.TagsBuilder, tagColumns []string, columns map[string]arrow.Array, numRows int) error {
+	for _, tagColumn := range tagColumns {
+		if !conv.IsTagType(columns[tagColumn].DataType()) {
+			return fmt.Errorf("tag column '%s' type mistmach: %s is not supported", tagColumn, columns[tagColumn].DataType())
+		}
+	}
+	for i := 0; i < numRows; i++ {
+		builder.NewRow()
+		for _, tagColumn := range tagColumns {
+			if err := builder.AppendValue(columns[tagColumn], i); err != nil {
+				return fmt.Errorf("tag column '%s': %w", tagColumn, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package parquet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	parquet_go "github.com/apache/arrow/go/v10/parquet"
+	"github.com/apache/arrow/go/v10/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParquet(t *testing.T) {
+	data, err := getTestParquetData()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	t.Run("Init()", testInitFunc())
+	t.Run("GetRecord()", testGetRecordFunc(data, "1"))
+	t.Run("GetRecord() one batch", testGetRecordFunc(data, "100"))
+	t.Run("GetRecord() custom time format", testGetRecordCustomTimeFunc(data))
+	t.Run("GetRecord() time precision", testGetRecordTimePrecisionFunc(data))
+	t.Run("GetRecord() measurement type", testGetRecordMeasurementTypeFunc(data))
+	t.Run("GetRecord() called twice", testGetRecordTwiceFunc(data))
+	t.Run("GetRecord() missing columns", testGetRecordMissingColumnsFunc(data))
+	t.Run("GetRecord() type mismatch", testGetRecordTypeMismatchFunc(data))
+	t.Run("GetRecord() invalid data", testGetRecordInvalidDataFunc())
+	t.Run("GetRecords()", testGetRecordsFunc(data))
+}
+
+// Tests "Init()"
+func testInitFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		assert.NoError(t, p.Init(map[string]string{}, nil, nil, nil, nil))
+		assert.NoError(t, p.Init(map[string]string{"batch_size": "1024"}, nil, nil, nil, nil))
+		assert.Error(t, p.Init(map[string]string{"batch_size": "0"}, nil, nil, nil, nil))
+	}
+}
+
+// Tests "GetRecord()" reading the row groups in batches of batchSize rows
+func testGetRecordFunc(data []byte, batchSize string) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(
+			map[string]string{"batch_size": batchSize},
+			map[string]string{"symbol": "symbol"},
+			map[string]string{"close": "price", "volume": "volume"},
+			map[string]string{"side": "side"},
+			[]string{"labels", "_tags"})
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		// All the batches are returned in a single record
+		record, err := p.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		expectedRecord := getExpectedRecord()
+		defer expectedRecord.Release()
+
+		assert.True(t, expectedRecord.Schema().Equal(record.Schema()), "Schema not correct: %s", record.Schema())
+		assert.True(t, array.RecordEqual(expectedRecord, record), "Record not correct: %v", record)
+
+		record, err = p.GetRecord()
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	}
+}
+
+// Tests iterating the records of "GetRecords()", of up to batch_size rows each
+func testGetRecordsFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{"batch_size": "2"}, nil, map[string]string{"close": "price"}, nil, []string{"_tags"})
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		reader, err := p.GetRecords()
+		if !assert.NoError(t, err) || !assert.NotNil(t, reader) {
+			return
+		}
+		defer reader.Release()
+
+		assert.Equal(t, "time", reader.Schema().Field(0).Name)
+		assert.Equal(t, "tags", reader.Schema().Field(2).Name)
+
+		var numRows int64
+		numRecords := 0
+		for reader.Next() {
+			assert.True(t, reader.Schema().Equal(reader.Record().Schema()))
+			assert.LessOrEqual(t, reader.Record().NumRows(), int64(2))
+			numRows += reader.Record().NumRows()
+			numRecords++
+		}
+		assert.NoError(t, reader.Err())
+		assert.Equal(t, int64(5), numRows)
+		assert.Greater(t, numRecords, 2)
+
+		// The data is consumed
+		reader, err = p.GetRecords()
+		assert.NoError(t, err)
+		assert.Nil(t, reader)
+	}
+}
+
+// Tests "GetRecord()" with a string time column
+func testGetRecordCustomTimeFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{"time_selector": "date", "time_format": "2006-01-02 15:04"}, nil, map[string]string{"close": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, []int64{1605312000, 1605313800, 1605315600, 1605317400, 1605319200}, record.Column(0).(*array.Int64).Int64Values())
+		assert.Equal(t, "measure.close", record.Schema().Field(1).Name)
+	}
+}
+
+// Tests "GetRecord()" with the time_precision param
+func testGetRecordTimePrecisionFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{"time_precision": "ms"}, nil, map[string]string{"close": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.Equal(t, arrow.FixedWidthTypes.Timestamp_ms, record.Schema().Field(0).Type)
+		assert.Equal(t, []arrow.Timestamp{1605312000000, 1605313800000, 1605315600000, 1605317400000, 1605319200000}, record.Column(0).(*array.Timestamp).TimestampValues())
+	}
+}
+
+// Tests "GetRecord()" returns nil when there is no new data
+func testGetRecordMeasurementTypeFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		measurements := map[string]string{"unmapped": "unmapped", "volume": "volume"}
+
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{"measurement_type": "keep"}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, arrow.PrimitiveTypes.Float32, record.Schema().Field(1).Type)
+			assert.Equal(t, arrow.PrimitiveTypes.Int64, record.Schema().Field(2).Type)
+			record.Release()
+		}
+
+		p = NewParquetProcessor()
+		err = p.Init(map[string]string{"measurement_type": "int8"}, nil, measurements, nil, nil)
+		assert.NoError(t, err)
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err = p.GetRecord()
+		if assert.NoError(t, err) && assert.NotNil(t, record) {
+			assert.Equal(t, "[0 1 2 3 4]", record.Column(1).String())
+			assert.Equal(t, "[12 3 7 0 1]", record.Column(2).String())
+			record.Release()
+		}
+	}
+}
+
+func testGetRecordTwiceFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{}, nil, map[string]string{"close": "price"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := p.GetRecord()
+		assert.NoError(t, err)
+		assert.NotNil(t, record)
+		record.Release()
+
+		record, err = p.GetRecord()
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	}
+}
+
+func testGetRecordMissingColumnsFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		mappings := []struct {
+			params       map[string]string
+			identifiers  map[string]string
+			measurements map[string]string
+			categories   map[string]string
+			tags         []string
+		}{
+			{params: map[string]string{"time_selector": "timestamp"}},
+			{identifiers: map[string]string{"id": "missing"}},
+			{measurements: map[string]string{"open": "open"}},
+			{categories: map[string]string{"kind": "kind"}},
+			{tags: []string{"missing"}},
+		}
+
+		for _, mapping := range mappings {
+			p := NewParquetProcessor()
+			err := p.Init(mapping.params, mapping.identifiers, mapping.measurements, mapping.categories, mapping.tags)
+			assert.NoError(t, err)
+
+			_, err = p.OnData(data)
+			assert.NoError(t, err)
+
+			_, err = p.GetRecord()
+			assert.Error(t, err)
+		}
+	}
+}
+
+func testGetRecordTypeMismatchFunc(data []byte) func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{}, nil, map[string]string{"symbol": "symbol"}, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData(data)
+		assert.NoError(t, err)
+
+		_, err = p.GetRecord()
+		assert.Error(t, err)
+	}
+}
+
+func testGetRecordInvalidDataFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		p := NewParquetProcessor()
+		err := p.Init(map[string]string{}, nil, nil, nil, nil)
+		assert.NoError(t, err)
+
+		_, err = p.OnData([]byte("time,price\n1605312000,16339.56\n"))
+		assert.NoError(t, err)
+
+		_, err = p.GetRecord()
+		assert.Error(t, err)
+	}
+}
+
+// Returns parquet data written in two row groups, with an unmapped column that isn't read
+func getTestParquetData() ([]byte, error) {
+	pool := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+		{Name: "date", Type: arrow.BinaryTypes.String},
+		{Name: "symbol", Type: arrow.BinaryTypes.String},
+		{Name: "price", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "side", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "unmapped", Type: arrow.PrimitiveTypes.Float32},
+		{Name: "volume", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "_tags", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "labels", Type: arrow.ListOf(arrow.BinaryTypes.String), Nullable: true},
+	}, nil)
+
+	var buffer bytes.Buffer
+	writer, err := pqarrow.NewFileWriter(schema, &buffer, parquet_go.NewWriterProperties(), pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	rows := []struct {
+		time   int64
+		date   string
+		price  float64
+		side   string
+		volume int64
+		tags   string
+		labels []string
+	}{
+		{1605312000000, "2020-11-14 00:00", 16339.56, "buy", 12, "a b", []string{"l1"}},
+		{1605313800000, "2020-11-14 00:30", 0, "", 3, "", nil},
+		{1605315600000, "2020-11-14 01:00", 16100.5, "sell", 7, "c", []string{"l2", "l3"}},
+		{1605317400000, "2020-11-14 01:30", 16150, "buy", 0, "", []string{}},
+		{1605319200000, "2020-11-14 02:00", 16200.25, "sell", 1, "d", nil},
+	}
+
+	for i, row := range rows {
+		recordBuilder.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(row.time))
+		recordBuilder.Field(1).(*array.StringBuilder).Append(row.date)
+		recordBuilder.Field(2).(*array.StringBuilder).Append("BTC-USD")
+		if row.price == 0 {
+			recordBuilder.Field(3).(*array.Float64Builder).AppendNull()
+		} else {
+			recordBuilder.Field(3).(*array.Float64Builder).Append(row.price)
+		}
+		if row.side == "" {
+			recordBuilder.Field(4).(*array.StringBuilder).AppendNull()
+		} else {
+			recordBuilder.Field(4).(*array.StringBuilder).Append(row.side)
+		}
+		recordBuilder.Field(5).(*array.Float32Builder).Append(float32(i))
+		recordBuilder.Field(6).(*array.Int64Builder).Append(row.volume)
+		if row.tags == "" {
+			recordBuilder.Field(7).(*array.StringBuilder).AppendNull()
+		} else {
+			recordBuilder.Field(7).(*array.StringBuilder).Append(row.tags)
+		}
+		listBuilder := recordBuilder.Field(8).(*array.ListBuilder)
+		if row.labels == nil {
+			listBuilder.AppendNull()
+		} else {
+			listBuilder.Append(true)
+			listBuilder.ValueBuilder().(*array.StringBuilder).AppendValues(row.labels, nil)
+		}
+
+		// Two row groups, of 2 and 3 rows
+		if i == 1 || i == len(rows)-1 {
+			record := recordBuilder.NewRecord()
+			err = writer.Write(record)
+			record.Release()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func getExpectedRecord() arrow.Record {
+	pool := memory.NewGoAllocator()
+	fields := []arrow.Field{
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "id.symbol", Type: arrow.BinaryTypes.String},
+		{Name: "measure.close", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "cat.side", Type: arrow.BinaryTypes.String},
+		{Name: "measure.volume", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}
+	recordBuilder := array.NewRecordBuilder(pool, arrow.NewSchema(fields, nil))
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800, 1605315600, 1605317400, 1605319200}, nil)
+	recordBuilder.Field(1).(*array.StringBuilder).AppendValues([]string{"BTC-USD", "BTC-USD", "BTC-USD", "BTC-USD", "BTC-USD"}, nil)
+	recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{16339.56, 0, 16100.5, 16150, 16200.25}, []bool{true, false, true, true, true})
+	recordBuilder.Field(3).(*array.StringBuilder).AppendValues([]string{"buy", "", "sell", "buy", "sell"}, []bool{true, false, true, true, true})
+	recordBuilder.Field(4).(*array.Float64Builder).AppendValues([]float64{12, 3, 7, 0, 1}, nil)
+	listBuilder := recordBuilder.Field(5).(*array.ListBuilder)
+	valueBuilder := listBuilder.ValueBuilder().(*array.StringBuilder)
+	for _, tags := range [][]string{{"l1", "a", "b"}, {}, {"l2", "l3", "c"}, {}, {"d"}} {
+		listBuilder.Append(true)
+		valueBuilder.AppendValues(tags, nil)
+	}
+
+	return recordBuilder.NewRecord()
+}
@@ -0,0 +1,287 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/apache/arrow/go/v10/parquet/file"
+	"github.com/apache/arrow/go/v10/parquet/pqarrow"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
+)
+
+// RecordReader reads the mapped columns of the file one batch of up to batch_size rows at a time, and converts
+// each batch to a record, so only the rows of the current record are held as Arrow data. It implements
+// array.RecordReader.
+type RecordReader struct {
+	refCount int64
+
+	processor     *ParquetProcessor
+	parquetReader *file.Reader
+	batches       pqarrow.RecordReader
+	pool          memory.Allocator
+	schema        *arrow.Schema
+	columns       []*outputColumn
+
+	record arrow.Record
+	err    error
+}
+
+var _ array.RecordReader = (*RecordReader)(nil)
+
+// Maps the columns of the file and projects its leaf columns of the mapped fields
+func (p *ParquetProcessor) newRecordReader(data []byte) (*RecordReader, error) {
+	parquetReader, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet data: %w", err)
+	}
+
+	reader, err := p.mapColumns(parquetReader)
+	if err != nil {
+		parquetReader.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (p *ParquetProcessor) mapColumns(parquetReader *file.Reader) (*RecordReader, error) {
+	pool := memory.NewGoAllocator()
+	fileReader, err := pqarrow.NewFileReader(parquetReader, pqarrow.ArrowReadProperties{BatchSize: p.batchSize}, pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet data: %w", err)
+	}
+
+	fieldIndices := make(map[string]int)
+	for i, field := range fileReader.Manifest.Fields {
+		fieldIndices[field.Field.Name] = i
+	}
+
+	if _, ok := fieldIndices[p.timeSelector]; !ok {
+		return nil, fmt.Errorf("time column '%s' not found", p.timeSelector)
+	}
+	columns := []*outputColumn{{name: conv.TimeColumnName, inputName: p.timeSelector, dataType: p.timePrecision.DataType()}}
+
+	addColumns := func(kind string, prefix string, mapping map[string]string, outputType func(inputType arrow.DataType) (arrow.DataType, error)) error {
+		for outputName, inputName := range mapping {
+			index, ok := fieldIndices[inputName]
+			if !ok {
+				return fmt.Errorf("%s column '%s' not found", kind, inputName)
+			}
+			dataType, err := outputType(fileReader.Manifest.Fields[index].Field.Type)
+			if err != nil {
+				return fmt.Errorf("%s column '%s' type mistmach: %w", kind, inputName, err)
+			}
+			columns = append(columns, &outputColumn{name: prefix + outputName, inputName: inputName, dataType: dataType})
+		}
+		return nil
+	}
+	stringType := func(arrow.DataType) (arrow.DataType, error) { return arrow.BinaryTypes.String, nil }
+	if err := addColumns("identifier", conv.IdentifierPrefix, p.identifiers, stringType); err != nil {
+		return nil, err
+	}
+	if err := addColumns("measurement", conv.MeasurementPrefix, p.measurements, p.measurementType.OutputType); err != nil {
+		return nil, err
+	}
+	if err := addColumns("category", conv.CategoryPrefix, p.categories, stringType); err != nil {
+		return nil, err
+	}
+	// Columns are output in the order of the file, like the csv processor
+	mapped := columns[1:]
+	sort.Slice(mapped, func(i, j int) bool {
+		a, b := fieldIndices[mapped[i].inputName], fieldIndices[mapped[j].inputName]
+		return a < b || (a == b && mapped[i].name < mapped[j].name)
+	})
+
+	for _, tag := range p.tags {
+		if _, ok := fieldIndices[tag]; !ok {
+			return nil, fmt.Errorf("tag column '%s' not found", tag)
+		}
+	}
+
+	// Projecting the leaf columns of the mapped fields
+	inputNames := make(map[string]bool)
+	for _, tag := range p.tags {
+		inputNames[tag] = true
+	}
+	for _, column := range columns {
+		inputNames[column.inputName] = true
+	}
+	var leaves []int
+	parquetSchema := parquetReader.MetaData().Schema
+	for i := 0; i < parquetSchema.NumColumns(); i++ {
+		if inputNames[parquetSchema.ColumnRoot(i).Name()] {
+			leaves = append(leaves, i)
+		}
+	}
+
+	batches, err := fileReader.GetRecordReader(context.Background(), leaves, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parquet data: %w", err)
+	}
+
+	fields := make([]arrow.Field, 0, len(columns)+1)
+	for _, column := range columns {
+		fields = append(fields, arrow.Field{Name: column.name, Type: column.dataType})
+	}
+	fields = append(fields, arrow.Field{Name: conv.TagsColumnName, Type: conv.TagsType})
+
+	return &RecordReader{
+		refCount:      1,
+		processor:     p,
+		parquetReader: parquetReader,
+		batches:       batches,
+		pool:          pool,
+		schema:        arrow.NewSchema(fields, nil),
+		columns:       columns,
+	}, nil
+}
+
+func (r *RecordReader) Retain() {
+	atomic.AddInt64(&r.refCount, 1)
+}
+
+func (r *RecordReader) Release() {
+	if atomic.AddInt64(&r.refCount, -1) == 0 {
+		if r.record != nil {
+			r.record.Release()
+			r.record = nil
+		}
+		r.batches.Release()
+		r.parquetReader.Close()
+	}
+}
+
+func (r *RecordReader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+// Next reads the next record, and returns false after the last record or an error
+func (r *RecordReader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.err != nil {
+		return false
+	}
+
+	batch, err := r.batches.Read()
+	if errors.Is(err, io.EOF) {
+		return false
+	}
+	if err != nil {
+		r.err = fmt.Errorf("failed to read parquet data: %w", err)
+		return false
+	}
+
+	record, err := r.convertBatch(batch)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	r.record = record
+	return true
+}
+
+// Record returns the current record, which is valid until the next call to Next
+func (r *RecordReader) Record() arrow.Record {
+	return r.record
+}
+
+// Err returns the error that stopped Next
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// Converts the values of a batch of the projected columns to a record of the output columns
+func (r *RecordReader) convertBatch(batch arrow.Record) (arrow.Record, error) {
+	p := r.processor
+
+	batchColumns := make(map[string]arrow.Array)
+	for i, field := range batch.Schema().Fields() {
+		batchColumns[field.Name] = batch.Column(i)
+	}
+
+	for i, column := range r.columns {
+		if i == 0 {
+			column.builder = p.timePrecision.NewBuilder(r.pool)
+		} else {
+			column.builder = array.NewBuilder(r.pool, column.dataType)
+		}
+		defer column.builder.Release()
+	}
+	tagsBuilder := conv.NewTagsBuilder(r.pool)
+	defer tagsBuilder.Release()
+
+	if err := p.appendTimes(r.columns[0].builder, batchColumns[p.timeSelector]); err != nil {
+		return nil, err
+	}
+	for _, column := range r.columns[1:] {
+		if err := appendValues(column, batchColumns[column.inputName]); err != nil {
+			return nil, err
+		}
+	}
+	if err := appendTags(tagsBuilder, p.tags, batchColumns, int(batch.NumRows())); err != nil {
+		return nil, err
+	}
+
+	arrays := make([]arrow.Array, 0, len(r.columns)+1)
+	for _, column := range r.columns {
+		arr := column.builder.NewArray()
+		defer arr.Release()
+		arrays = append(arrays, arr)
+	}
+	tagsArray := tagsBuilder.NewArray()
+	defer tagsArray.Release()
+	arrays = append(arrays, tagsArray)
+
+	return array.NewRecord(r.schema, arrays, batch.NumRows()), nil
+}
+
+// Reads all the remaining records, and concatenates them into a single record
+func (r *RecordReader) readAll() (arrow.Record, error) {
+	var records []arrow.Record
+	defer func() {
+		for _, record := range records {
+			record.Release()
+		}
+	}()
+	var numRows int64
+	for r.Next() {
+		r.record.Retain()
+		records = append(records, r.record)
+		numRows += r.record.NumRows()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	fields := r.schema.Fields()
+	columns := make([]arrow.Array, len(fields))
+	for i, field := range fields {
+		var column arrow.Array
+		if len(records) == 0 {
+			column = array.MakeArrayOfNull(r.pool, field.Type, 0)
+		} else {
+			chunks := make([]arrow.Array, len(records))
+			for j, record := range records {
+				chunks[j] = record.Column(i)
+			}
+			var err error
+			if column, err = array.Concatenate(chunks, r.pool); err != nil {
+				return nil, fmt.Errorf("failed to concatenate parquet batches: %w", err)
+			}
+		}
+		defer column.Release()
+		columns[i] = column
+	}
+
+	return array.NewRecord(r.schema, columns, numRows), nil
+}
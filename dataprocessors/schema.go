@@ -0,0 +1,91 @@
+package dataprocessors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/spiceai/data-components-contrib/dataprocessors/conv"
+)
+
+// Names and prefixes of the columns of the records output by all processors
+const (
+	TimeColumnName    = conv.TimeColumnName
+	IdentifierPrefix  = conv.IdentifierPrefix
+	MeasurementPrefix = conv.MeasurementPrefix
+	CategoryPrefix    = conv.CategoryPrefix
+	TagsColumnName    = conv.TagsColumnName
+)
+
+// TagsType is the type of the tags column, with the list of the tags of each row
+var TagsType = conv.TagsType
+
+// ValidateSchema returns an error if the schema of a record output by a processor does not follow the
+// output schema contract:
+//   - The first column is "time", as Int64 epochs or a Timestamp. Its values are never null, which is up to the
+//     processors as it isn't part of the schema.
+//   - Then "id.<name>" String columns, "measure.<name>" numeric columns and "cat.<name>" String columns.
+//     Identifier and category columns may also be dictionaries of strings.
+//   - The last column is "tags", a list of strings
+func ValidateSchema(schema *arrow.Schema) error {
+	fields := schema.Fields()
+	if len(fields) < 2 {
+		return fmt.Errorf("schema has %d columns, expected at least '%s' and '%s'", len(fields), TimeColumnName, TagsColumnName)
+	}
+
+	timeField := fields[0]
+	if timeField.Name != TimeColumnName {
+		return fmt.Errorf("first column is '%s', expected '%s'", timeField.Name, TimeColumnName)
+	}
+	if timeField.Type.ID() != arrow.INT64 && timeField.Type.ID() != arrow.TIMESTAMP {
+		return fmt.Errorf("column '%s' type mistmach: %s is not int64 or a timestamp", timeField.Name, timeField.Type)
+	}
+
+	tagsField := fields[len(fields)-1]
+	if tagsField.Name != TagsColumnName {
+		return fmt.Errorf("last column is '%s', expected '%s'", tagsField.Name, TagsColumnName)
+	}
+	if !arrow.TypeEqual(tagsField.Type, TagsType) {
+		return fmt.Errorf("column '%s' type mistmach: %s is not %s", tagsField.Name, tagsField.Type, TagsType)
+	}
+
+	names := make(map[string]bool)
+	for _, field := range fields[1 : len(fields)-1] {
+		if names[field.Name] {
+			return fmt.Errorf("duplicate column '%s'", field.Name)
+		}
+		names[field.Name] = true
+
+		switch {
+		case strings.HasPrefix(field.Name, IdentifierPrefix), strings.HasPrefix(field.Name, CategoryPrefix):
+			if !isStringType(field.Type) {
+				return fmt.Errorf("column '%s' type mistmach: %s is not a string", field.Name, field.Type)
+			}
+		case strings.HasPrefix(field.Name, MeasurementPrefix):
+			if !isMeasurementType(field.Type) {
+				return fmt.Errorf("column '%s' type mistmach: %s is not numeric", field.Name, field.Type)
+			}
+		default:
+			return fmt.Errorf("unexpected column '%s'", field.Name)
+		}
+	}
+
+	return nil
+}
+
+func isStringType(dataType arrow.DataType) bool {
+	if dictionaryType, ok := dataType.(*arrow.DictionaryType); ok {
+		dataType = dictionaryType.ValueType
+	}
+	return dataType.ID() == arrow.STRING
+}
+
+func isMeasurementType(dataType arrow.DataType) bool {
+	switch dataType.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64,
+		arrow.FLOAT32, arrow.FLOAT64, arrow.DECIMAL128:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package dataprocessors
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/flight"
+	"github.com/apache/arrow/go/v10/arrow/ipc"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	parquet_go "github.com/apache/arrow/go/v10/parquet"
+	"github.com/apache/arrow/go/v10/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Tests the records of every processor follow the output schema contract
+func TestProcessorsSchema(t *testing.T) {
+	csvData, err := os.ReadFile("../test/assets/data/csv/local_tag_data.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := os.ReadFile("../test/assets/data/json/puppies_valid.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fluxData, err := os.ReadFile("../test/assets/data/annotated-csv/cpu_metrics_influxdb_annotated.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := getTestBatch()
+	defer batch.Release()
+	ipcData, err := writeIpcStream(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parquetData, err := writeParquet(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flightData, err := getFlightStream(t, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batchMapping := processorMapping{
+		params:       map[string]string{"time_selector": "timestamp"},
+		identifiers:  map[string]string{"symbol": "symbol"},
+		measurements: map[string]string{"price": "price"},
+		categories:   map[string]string{"exchange": "exchange"},
+		tags:         []string{"side", "labels"},
+	}
+
+	t.Run("csv", testProcessorSchemaFunc("csv", csvData, processorMapping{
+		measurements: map[string]string{"open": "open", "close": "close"},
+		tags:         []string{"_tags", "tag1"},
+	}, 4))
+	t.Run("json", testProcessorSchemaFunc("json", jsonData, processorMapping{
+		identifiers:  map[string]string{"city": "city"},
+		measurements: map[string]string{"population": "population"},
+		tags:         []string{"tags"},
+	}, 4))
+	t.Run("flux-csv", testProcessorSchemaFunc("flux-csv", fluxData, processorMapping{}, 3))
+	t.Run("parquet", testProcessorSchemaFunc("parquet", parquetData, batchMapping, 5))
+	t.Run("arrow-ipc", testProcessorSchemaFunc("arrow-ipc", ipcData, batchMapping, 5))
+	t.Run("arrow", testProcessorSchemaFunc("arrow", flightData, batchMapping, 5))
+}
+
+func TestValidateSchema(t *testing.T) {
+	timeField := arrow.Field{Name: TimeColumnName, Type: arrow.PrimitiveTypes.Int64}
+	tagsField := arrow.Field{Name: TagsColumnName, Type: TagsType}
+	dictionaryType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+
+	valid := [][]arrow.Field{
+		{timeField, tagsField},
+		{
+			{Name: TimeColumnName, Type: arrow.FixedWidthTypes.Timestamp_ms},
+			{Name: "id.symbol", Type: dictionaryType},
+			{Name: "measure.price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}},
+			{Name: "measure.volume", Type: arrow.PrimitiveTypes.Uint32},
+			{Name: "cat.exchange", Type: arrow.BinaryTypes.String},
+			tagsField,
+		},
+	}
+	for _, fields := range valid {
+		assert.NoError(t, ValidateSchema(arrow.NewSchema(fields, nil)))
+	}
+
+	for expected, fields := range map[string][]arrow.Field{
+		"schema has 1 columns, expected at least 'time' and 'tags'":           {timeField},
+		"first column is 'timestamp', expected 'time'":                        {{Name: "timestamp", Type: arrow.PrimitiveTypes.Int64}, tagsField},
+		"column 'time' type mistmach: utf8 is not int64 or a timestamp":       {{Name: TimeColumnName, Type: arrow.BinaryTypes.String}, tagsField},
+		"last column is 'tag.side', expected 'tags'":                          {timeField, {Name: "tag.side", Type: arrow.BinaryTypes.String}},
+		"column 'tags' type mistmach: utf8 is not list<item: utf8, nullable>": {timeField, {Name: TagsColumnName, Type: arrow.BinaryTypes.String}},
+		"column 'id.block' type mistmach: uint64 is not a string":             {timeField, {Name: "id.block", Type: arrow.PrimitiveTypes.Uint64}, tagsField},
+		"column 'measure.price' type mistmach: utf8 is not numeric":           {timeField, {Name: "measure.price", Type: arrow.BinaryTypes.String}, tagsField},
+		"unexpected column 'price'":                                           {timeField, {Name: "price", Type: arrow.PrimitiveTypes.Float64}, tagsField},
+		"duplicate column 'cat.side'":                                         {timeField, {Name: "cat.side", Type: arrow.BinaryTypes.String}, {Name: "cat.side", Type: arrow.BinaryTypes.String}, tagsField},
+	} {
+		assert.EqualError(t, ValidateSchema(arrow.NewSchema(fields, nil)), expected)
+	}
+}
+
+type processorMapping struct {
+	params       map[string]string
+	identifiers  map[string]string
+	measurements map[string]string
+	categories   map[string]string
+	tags         []string
+}
+
+func testProcessorSchemaFunc(name string, data []byte, mapping processorMapping, numColumns int) func(*testing.T) {
+	return func(t *testing.T) {
+		dp, err := NewDataProcessor(name)
+		if !assert.NoError(t, err) {
+			return
+		}
+		params := mapping.params
+		if params == nil {
+			params = map[string]string{}
+		}
+		err = dp.Init(params, mapping.identifiers, mapping.measurements, mapping.categories, mapping.tags)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = dp.OnData(data)
+		assert.NoError(t, err)
+
+		record, err := dp.GetRecord()
+		if !assert.NoError(t, err) || !assert.NotNil(t, record) {
+			return
+		}
+		defer record.Release()
+
+		assert.NoError(t, ValidateSchema(record.Schema()))
+		assert.Equal(t, numColumns, int(record.NumCols()))
+		assert.Greater(t, record.NumRows(), int64(0))
+	}
+}
+
+// Returns a record batch with identifier, measurement, category and tag columns
+func getTestBatch() arrow.Record {
+	pool := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "timestamp", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "symbol", Type: arrow.BinaryTypes.String},
+		{Name: "price", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "exchange", Type: arrow.BinaryTypes.String},
+		{Name: "side", Type: arrow.BinaryTypes.String},
+		{Name: "labels", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}, nil)
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800}, nil)
+	recordBuilder.Field(1).(*array.StringBuilder).AppendValues([]string{"BTC-USD", "BTC-USD"}, nil)
+	recordBuilder.Field(2).(*array.Float64Builder).AppendValues([]float64{16339.56, 16100.5}, nil)
+	recordBuilder.Field(3).(*array.StringBuilder).AppendValues([]string{"coinbase", "kraken"}, nil)
+	recordBuilder.Field(4).(*array.StringBuilder).AppendValues([]string{"buy", "sell"}, nil)
+	labelsBuilder := recordBuilder.Field(5).(*array.ListBuilder)
+	labelValuesBuilder := labelsBuilder.ValueBuilder().(*array.StringBuilder)
+	labelsBuilder.Append(true)
+	labelValuesBuilder.AppendValues([]string{"l1", "l2"}, nil)
+	labelsBuilder.Append(true)
+
+	return recordBuilder.NewRecord()
+}
+
+func writeIpcStream(batch arrow.Record) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := ipc.NewWriter(&buffer, ipc.WithSchema(batch.Schema()))
+	if err := writer.Write(batch); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Serves the batch from a local flight server, and returns the DoGet stream passed to the arrow processor the
+// same way as the flight connector
+func getFlightStream(t *testing.T, batch arrow.Record) ([]byte, error) {
+	server := flight.NewFlightServer()
+	if err := server.Init("localhost:0"); err != nil {
+		return nil, err
+	}
+	server.RegisterFlightService(&batchFlightServer{batch: batch})
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(server.Shutdown)
+
+	client, err := flight.NewClientWithMiddleware(server.Addr().String(), nil, nil, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { client.Close() })
+
+	stream, err := client.DoGet(context.Background(), &flight.Ticket{})
+	if err != nil {
+		return nil, err
+	}
+	// The bytes don't reference the stream for the garbage collector, which keeps it until the end of the test
+	t.Cleanup(func() { _ = stream.CloseSend() })
+	// Padding the stream to the size of a slice, so the conversion stays within a single allocation
+	padded := struct {
+		stream flight.FlightService_DoGetClient
+		_      uintptr
+	}{stream: stream}
+	return *(*[]byte)(unsafe.Pointer(&padded)), nil
+}
+
+type batchFlightServer struct {
+	flight.BaseFlightServer
+	batch arrow.Record
+}
+
+func (s *batchFlightServer) DoGet(_ *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	writer := flight.NewRecordWriter(stream, ipc.WithSchema(s.batch.Schema()))
+	defer writer.Close()
+	return writer.Write(s.batch)
+}
+
+func writeParquet(batch arrow.Record) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := pqarrow.NewFileWriter(batch.Schema(), &buffer, parquet_go.NewWriterProperties(), pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Write(batch); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
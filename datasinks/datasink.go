@@ -0,0 +1,27 @@
+package datasinks
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/spiceai/data-components-contrib/datasinks/file"
+	"github.com/spiceai/data-components-contrib/datasinks/influxdb"
+)
+
+type DataSink interface {
+	Init(params map[string]string) error
+	Write(record arrow.Record) error
+	Flush() error
+	Close() error
+}
+
+func NewDataSink(name string) (DataSink, error) {
+	switch name {
+	case file.FileSinkName:
+		return file.NewFileSink(), nil
+	case influxdb.InfluxDbSinkName:
+		return influxdb.NewInfluxDbSink(), nil
+	}
+
+	return nil, fmt.Errorf("unknown data sink '%s'", name)
+}
@@ -0,0 +1,30 @@
+package datasinks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDataSink(t *testing.T) {
+	t.Run("NewDataSink() - Invalid sink", testNewDataSinkUnknownFunc())
+}
+
+func testNewDataSinkUnknownFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		_, err := NewDataSink("does-not-exist")
+		assert.Error(t, err)
+	}
+}
+
+func TestFileFactory(t *testing.T) {
+	s, err := NewDataSink("file")
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+}
+
+func TestInfluxDbFactory(t *testing.T) {
+	s, err := NewDataSink("influxdb")
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+}
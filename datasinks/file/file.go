@@ -0,0 +1,281 @@
+package file
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
+	"github.com/apache/arrow/go/v10/arrow/ipc"
+)
+
+const (
+	FileSinkName string = "file"
+
+	csvFormat   string = "csv"
+	jsonFormat  string = "json"
+	arrowFormat string = "arrow"
+)
+
+// Formats of the file extensions, when the format param isn't set
+var extensionFormats = map[string]string{
+	".csv":     csvFormat,
+	".json":    jsonFormat,
+	".jsonl":   jsonFormat,
+	".ndjson":  jsonFormat,
+	".arrow":   arrowFormat,
+	".arrows":  arrowFormat,
+	".ipc":     arrowFormat,
+	".feather": arrowFormat,
+}
+
+type FileSink struct {
+	path   string
+	format string
+
+	mutex     sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	csvWriter *csv.Writer
+	ipcWriter *ipc.FileWriter
+	schema    *arrow.Schema
+}
+
+func NewFileSink() *FileSink {
+	return &FileSink{}
+}
+
+func (s *FileSink) Init(params map[string]string) error {
+	path := params["path"]
+	if path == "" {
+		return errors.New("the file sink requires the 'path' param")
+	}
+	appDir := params["appDirectory"]
+	if !filepath.IsAbs(path) {
+		path = filepath.Clean(filepath.Join(appDir, path))
+	}
+	s.path = path
+
+	s.format = strings.ToLower(params["format"])
+	if s.format == "" {
+		s.format = extensionFormats[strings.ToLower(filepath.Ext(path))]
+		if s.format == "" {
+			return fmt.Errorf("unable to infer the format of file '%s', set the 'format' param to csv, json or arrow", path)
+		}
+	}
+	if s.format != csvFormat && s.format != jsonFormat && s.format != arrowFormat {
+		return fmt.Errorf("invalid format '%s': must be csv, json or arrow", s.format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%s': %w", path, err)
+	}
+	s.file = file
+
+	switch s.format {
+	case csvFormat:
+		s.writer = bufio.NewWriter(file)
+		s.csvWriter = csv.NewWriter(s.writer)
+	case jsonFormat:
+		s.writer = bufio.NewWriter(file)
+	}
+
+	return nil
+}
+
+// Write appends the record to the file. All records must have the schema of the first one.
+func (s *FileSink) Write(record arrow.Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return errors.New("file sink is not initialized or closed")
+	}
+
+	if s.schema == nil {
+		if err := s.writeSchema(record.Schema()); err != nil {
+			return err
+		}
+	} else if !s.schema.Equal(record.Schema()) {
+		return fmt.Errorf("record schema %s does not match the schema of the file '%s': %s", record.Schema(), s.path, s.schema)
+	}
+
+	switch s.format {
+	case csvFormat:
+		return s.writeCsv(record)
+	case jsonFormat:
+		// One JSON object per row and line
+		return array.RecordToJSON(record, s.writer)
+	default:
+		return s.ipcWriter.Write(record)
+	}
+}
+
+// Flush writes buffered rows to the file. Arrow IPC files are only valid once closed.
+func (s *FileSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.flush()
+}
+
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.flush()
+	if s.ipcWriter != nil {
+		if closeErr := s.ipcWriter.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := s.file.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	s.file = nil
+
+	return err
+}
+
+func (s *FileSink) flush() error {
+	if s.csvWriter != nil {
+		s.csvWriter.Flush()
+		if err := s.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	if s.writer != nil {
+		return s.writer.Flush()
+	}
+	return nil
+}
+
+func (s *FileSink) writeSchema(schema *arrow.Schema) error {
+	switch s.format {
+	case csvFormat:
+		header := make([]string, len(schema.Fields()))
+		for i, field := range schema.Fields() {
+			header[i] = field.Name
+		}
+		if err := s.csvWriter.Write(header); err != nil {
+			return err
+		}
+	case arrowFormat:
+		ipcWriter, err := ipc.NewFileWriter(s.file, ipc.WithSchema(schema))
+		if err != nil {
+			return fmt.Errorf("failed to create arrow writer for file '%s': %w", s.path, err)
+		}
+		s.ipcWriter = ipcWriter
+	}
+
+	s.schema = schema
+	return nil
+}
+
+func (s *FileSink) writeCsv(record arrow.Record) error {
+	row := make([]string, record.NumCols())
+	for i := 0; i < int(record.NumRows()); i++ {
+		for j, column := range record.Columns() {
+			value, err := formatValue(column, i)
+			if err != nil {
+				return fmt.Errorf("failed to write column '%s': %w", record.ColumnName(j), err)
+			}
+			row[j] = value
+		}
+		if err := s.csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Formats the value as a CSV cell. Lists, such as tags, are space-delimited like the tag columns read by the csv processor.
+func formatValue(column arrow.Array, i int) (string, error) {
+	if column.IsNull(i) {
+		return "", nil
+	}
+
+	switch c := column.(type) {
+	case *array.Int8:
+		return strconv.FormatInt(int64(c.Value(i)), 10), nil
+	case *array.Int16:
+		return strconv.FormatInt(int64(c.Value(i)), 10), nil
+	case *array.Int32:
+		return strconv.FormatInt(int64(c.Value(i)), 10), nil
+	case *array.Int64:
+		return strconv.FormatInt(c.Value(i), 10), nil
+	case *array.Uint8:
+		return strconv.FormatUint(uint64(c.Value(i)), 10), nil
+	case *array.Uint16:
+		return strconv.FormatUint(uint64(c.Value(i)), 10), nil
+	case *array.Uint32:
+		return strconv.FormatUint(uint64(c.Value(i)), 10), nil
+	case *array.Uint64:
+		return strconv.FormatUint(c.Value(i), 10), nil
+	case *array.Float32:
+		return strconv.FormatFloat(float64(c.Value(i)), 'f', -1, 32), nil
+	case *array.Float64:
+		return strconv.FormatFloat(c.Value(i), 'f', -1, 64), nil
+	case *array.Boolean:
+		return strconv.FormatBool(c.Value(i)), nil
+	case *array.Decimal128:
+		return formatDecimal(c.Value(i), c.DataType().(*arrow.Decimal128Type).Scale), nil
+	case *array.Dictionary:
+		return formatValue(c.Dictionary(), c.GetValueIndex(i))
+	case *array.String:
+		return c.Value(i), nil
+	case *array.LargeString:
+		return c.Value(i), nil
+	case *array.Timestamp:
+		unit := c.DataType().(*arrow.TimestampType).Unit
+		return c.Value(i).ToTime(unit).UTC().Format(time.RFC3339Nano), nil
+	case *array.Date32:
+		return c.Value(i).ToTime().Format("2006-01-02"), nil
+	case *array.Date64:
+		return c.Value(i).ToTime().Format("2006-01-02"), nil
+	case *array.List:
+		offsets := c.Offsets()
+		values := make([]string, 0, offsets[i+1]-offsets[i])
+		for j := int(offsets[i]); j < int(offsets[i+1]); j++ {
+			value, err := formatValue(c.ListValues(), j)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+		return strings.Join(values, " "), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", column.DataType())
+}
+
+// Formats the decimal with the digits of its scale, e.g. 1050 with a scale of 2 as 10.50
+func formatDecimal(num decimal128.Num, scale int32) string {
+	digits := num.BigInt().String()
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-scale))
+	}
+	if len(digits) <= int(scale) {
+		digits = strings.Repeat("0", int(scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
@@ -0,0 +1,226 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
+	"github.com/apache/arrow/go/v10/arrow/ipc"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/spiceai/data-components-contrib/datasinks/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+	t.Run("Write() - csv", testWriteFunc("results.csv", map[string]string{}, "time,measure.price,cat.side,tags\n"+
+		"1605312000,16339.56,buy,a b\n"+
+		"1605313800,,sell,\n"+
+		"1605315600,16100.5,,c\n"))
+	t.Run("Write() - json", testWriteFunc("results.ndjson", map[string]string{}, `{"cat.side":"buy","measure.price":16339.56,"tags":["a","b"],"time":1605312000}`+"\n"+
+		`{"cat.side":"sell","measure.price":null,"tags":[],"time":1605313800}`+"\n"+
+		`{"cat.side":null,"measure.price":16100.5,"tags":["c"],"time":1605315600}`+"\n"))
+	t.Run("Write() - format param", testWriteFunc("results.out", map[string]string{"format": "csv"}, "time,measure.price,cat.side,tags\n"+
+		"1605312000,16339.56,buy,a b\n"+
+		"1605313800,,sell,\n"+
+		"1605315600,16100.5,,c\n"))
+	t.Run("Write() - arrow", testWriteArrowFunc())
+	t.Run("Write() - decimal and dictionary columns", testWriteDecimalDictionaryFunc())
+	t.Run("Write() - schema mismatch", testWriteSchemaMismatchFunc())
+	t.Run("Init() - invalid params", testInitInvalidFunc())
+}
+
+func testWriteFunc(fileName string, params map[string]string, expected string) func(*testing.T) {
+	return func(t *testing.T) {
+		params["path"] = fileName
+		params["appDirectory"] = t.TempDir()
+
+		s := file.NewFileSink()
+		err := s.Init(params)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		records := getTestRecords()
+		for _, record := range records {
+			defer record.Release()
+			assert.NoError(t, s.Write(record))
+		}
+
+		assert.NoError(t, s.Flush())
+		data, err := os.ReadFile(filepath.Join(params["appDirectory"], fileName))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+
+		assert.NoError(t, s.Close())
+		assert.Error(t, s.Write(records[0]))
+	}
+}
+
+func testWriteArrowFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "results.arrow")
+
+		s := file.NewFileSink()
+		err := s.Init(map[string]string{"path": path})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		records := getTestRecords()
+		for _, record := range records {
+			defer record.Release()
+			assert.NoError(t, s.Write(record))
+		}
+		assert.NoError(t, s.Close())
+
+		f, err := os.Open(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer f.Close()
+
+		reader, err := ipc.NewFileReader(f)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer reader.Close()
+
+		assert.Equal(t, len(records), reader.NumRecords())
+		for i, expected := range records {
+			record, err := reader.Record(i)
+			if assert.NoError(t, err) {
+				assert.True(t, array.RecordEqual(expected, record), "Record not correct: %v", record)
+			}
+		}
+	}
+}
+
+func testWriteSchemaMismatchFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		s := file.NewFileSink()
+		err := s.Init(map[string]string{"path": filepath.Join(t.TempDir(), "results.csv")})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Close()
+
+		records := getTestRecords()
+		defer records[0].Release()
+		defer records[1].Release()
+		assert.NoError(t, s.Write(records[0]))
+
+		otherRecord := records[1].NewSlice(0, 1)
+		defer otherRecord.Release()
+		otherRecord = array.NewRecord(arrow.NewSchema(otherRecord.Schema().Fields()[:3], nil), otherRecord.Columns()[:3], 1)
+		defer otherRecord.Release()
+		assert.Error(t, s.Write(otherRecord))
+	}
+}
+
+func testInitInvalidFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := t.TempDir()
+		invalidParams := []map[string]string{
+			{},
+			{"path": filepath.Join(dir, "results.txt")},
+			{"path": filepath.Join(dir, "results.csv"), "format": "parquet"},
+			{"path": filepath.Join(dir, "missing", "results.csv")},
+		}
+		for _, params := range invalidParams {
+			s := file.NewFileSink()
+			assert.Error(t, s.Init(params), "params %v", params)
+		}
+	}
+}
+
+// Tests decimal measurements and dictionary identifiers, like with measurement_type decimal128 and arrow dictionaries
+func testWriteDecimalDictionaryFunc() func(*testing.T) {
+	return func(t *testing.T) {
+		appDirectory := t.TempDir()
+		s := file.NewFileSink()
+		err := s.Init(map[string]string{"path": "results.csv", "appDirectory": appDirectory})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		record := getDecimalDictionaryRecord(t)
+		defer record.Release()
+		assert.NoError(t, s.Write(record))
+		assert.NoError(t, s.Close())
+
+		data, err := os.ReadFile(filepath.Join(appDirectory, "results.csv"))
+		assert.NoError(t, err)
+		assert.Equal(t, "time,id.symbol,measure.price,tags\n"+
+			"1605312000,BTC-USD,16339.56,\n"+
+			"1605313800,,-0.05,\n"+
+			"1605315600,ETH-USD,7.00,\n", string(data))
+	}
+}
+
+// Returns two records with the schema of the data processors output
+func getTestRecords() []arrow.Record {
+	pool := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "measure.price", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "cat.side", Type: arrow.BinaryTypes.String},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}, nil)
+
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	var records []arrow.Record
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800}, nil)
+	recordBuilder.Field(1).(*array.Float64Builder).AppendValues([]float64{16339.56, 0}, []bool{true, false})
+	recordBuilder.Field(2).(*array.StringBuilder).AppendValues([]string{"buy", "sell"}, nil)
+	listBuilder := recordBuilder.Field(3).(*array.ListBuilder)
+	valueBuilder := listBuilder.ValueBuilder().(*array.StringBuilder)
+	listBuilder.Append(true)
+	valueBuilder.AppendValues([]string{"a", "b"}, nil)
+	listBuilder.Append(true)
+	records = append(records, recordBuilder.NewRecord())
+
+	recordBuilder.Field(0).(*array.Int64Builder).Append(1605315600)
+	recordBuilder.Field(1).(*array.Float64Builder).Append(16100.5)
+	recordBuilder.Field(2).(*array.StringBuilder).AppendNull()
+	listBuilder.Append(true)
+	valueBuilder.Append("c")
+	records = append(records, recordBuilder.NewRecord())
+
+	return records
+}
+
+// Returns a record with a dictionary identifier and a decimal measurement
+func getDecimalDictionaryRecord(t *testing.T) arrow.Record {
+	pool := memory.NewGoAllocator()
+	dictionaryType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "id.symbol", Type: dictionaryType, Nullable: true},
+		{Name: "measure.price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}, nil)
+
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800, 1605315600}, nil)
+	symbolBuilder := recordBuilder.Field(1).(*array.BinaryDictionaryBuilder)
+	assert.NoError(t, symbolBuilder.AppendString("BTC-USD"))
+	symbolBuilder.AppendNull()
+	assert.NoError(t, symbolBuilder.AppendString("ETH-USD"))
+	recordBuilder.Field(2).(*array.Decimal128Builder).AppendValues([]decimal128.Num{
+		decimal128.FromI64(1633956), decimal128.FromI64(-5), decimal128.FromI64(700),
+	}, nil)
+	listBuilder := recordBuilder.Field(3).(*array.ListBuilder)
+	for i := 0; i < 3; i++ {
+		listBuilder.Append(true)
+	}
+
+	return recordBuilder.NewRecord()
+}
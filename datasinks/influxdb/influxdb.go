@@ -0,0 +1,292 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	influxdb2 "github.com/influxdata/influxdb-client-go"
+	"github.com/influxdata/influxdb-client-go/api"
+	"github.com/influxdata/influxdb-client-go/api/write"
+)
+
+const (
+	InfluxDbSinkName string = "influxdb"
+
+	defaultBatchSize = 5000
+	// Points pending after failed writes, by default 20 batches
+	defaultMaxPendingPoints = 20 * defaultBatchSize
+)
+
+var precisions = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+type InfluxDbSink struct {
+	client           influxdb2.Client
+	writeAPI         api.WriteAPIBlocking
+	measurement      string
+	batchSize        int
+	maxPendingPoints int
+
+	mutex  sync.Mutex
+	points []*write.Point
+}
+
+func NewInfluxDbSink() *InfluxDbSink {
+	return &InfluxDbSink{}
+}
+
+func (s *InfluxDbSink) Init(params map[string]string) error {
+	for _, param := range []string{"url", "token", "bucket", "measurement"} {
+		if params[param] == "" {
+			return fmt.Errorf("the influxdb sink requires the '%s' param", param)
+		}
+	}
+	s.measurement = params["measurement"]
+
+	s.batchSize = defaultBatchSize
+	if batchSize, ok := params["batch_size"]; ok {
+		bs, err := strconv.Atoi(batchSize)
+		if err != nil || bs < 1 {
+			return fmt.Errorf("invalid batch_size '%s': must be an integer >= 1", batchSize)
+		}
+		s.batchSize = bs
+	}
+
+	s.maxPendingPoints = defaultMaxPendingPoints
+	if s.batchSize > s.maxPendingPoints {
+		s.maxPendingPoints = s.batchSize
+	}
+	if maxPendingPoints, ok := params["max_pending_points"]; ok {
+		mp, err := strconv.Atoi(maxPendingPoints)
+		if err != nil || mp < s.batchSize {
+			return fmt.Errorf("invalid max_pending_points '%s': must be an integer >= batch_size", maxPendingPoints)
+		}
+		s.maxPendingPoints = mp
+	}
+
+	precision := time.Second
+	if p, ok := params["precision"]; ok {
+		if precision, ok = precisions[p]; !ok {
+			return fmt.Errorf("invalid precision '%s': must be s, ms, us or ns", p)
+		}
+	}
+
+	// Failed points are kept by the sink, so the client must not also queue them for retrying
+	options := influxdb2.DefaultOptions().SetPrecision(precision).SetMaxRetries(0)
+	s.client = influxdb2.NewClientWithOptions(params["url"], params["token"], options)
+	s.writeAPI = s.client.WriteAPIBlocking(params["org"], params["bucket"])
+
+	return nil
+}
+
+// Write converts each row of the record to a point, and writes the points once batch_size points are buffered
+func (s *InfluxDbSink) Write(record arrow.Record) error {
+	points, err := s.toPoints(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.points = append(s.points, points...)
+	if len(s.points) >= s.batchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *InfluxDbSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.flush()
+}
+
+func (s *InfluxDbSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+
+	err := s.flush()
+	s.client.Close()
+	s.client = nil
+	s.writeAPI = nil
+
+	return err
+}
+
+func (s *InfluxDbSink) flush() error {
+	if len(s.points) == 0 {
+		return nil
+	}
+	if s.writeAPI == nil {
+		return errors.New("influxdb sink is not initialized or closed")
+	}
+
+	// Points are kept to be retried on the next flush if the write fails, up to max_pending_points
+	if err := s.writeAPI.WritePoint(context.Background(), s.points...); err != nil {
+		err = fmt.Errorf("failed to write %d points to influxdb: %w", len(s.points), err)
+		if dropped := len(s.points) - s.maxPendingPoints; dropped > 0 {
+			s.points = append([]*write.Point(nil), s.points[dropped:]...)
+			log.Printf("influxdb sink dropped the %d oldest pending points: %s", dropped, err.Error())
+		}
+		return err
+	}
+	s.points = nil
+
+	return nil
+}
+
+// Converts the rows to points. measure.<name> and other numeric or boolean columns are written as fields,
+// id.<name>, cat.<name> and other string columns as tags, and the tags list as a comma-delimited "tags" tag.
+// Rows without any non-null field are skipped as line protocol requires at least one field.
+func (s *InfluxDbSink) toPoints(record arrow.Record) ([]*write.Point, error) {
+	schema := record.Schema()
+	timeIndices := schema.FieldIndices("time")
+	if len(timeIndices) == 0 {
+		return nil, errors.New("record has no 'time' column")
+	}
+	timeColumn := record.Column(timeIndices[0])
+
+	var points []*write.Point
+	for i := 0; i < int(record.NumRows()); i++ {
+		if timeColumn.IsNull(i) {
+			return nil, fmt.Errorf("row %d has a null time", i)
+		}
+		t, err := timeValue(timeColumn, i)
+		if err != nil {
+			return nil, err
+		}
+
+		point := influxdb2.NewPointWithMeasurement(s.measurement).SetTime(t)
+		hasFields := false
+		for j, column := range record.Columns() {
+			name := schema.Field(j).Name
+			if j == timeIndices[0] || column.IsNull(i) {
+				continue
+			}
+
+			if name == "tags" {
+				if tags := listValues(column, i); len(tags) > 0 {
+					point.AddTag("tags", strings.Join(tags, ","))
+				}
+				continue
+			}
+
+			switch {
+			case strings.HasPrefix(name, "measure."):
+				name = strings.TrimPrefix(name, "measure.")
+			case strings.HasPrefix(name, "id."):
+				name = strings.TrimPrefix(name, "id.")
+			case strings.HasPrefix(name, "cat."):
+				name = strings.TrimPrefix(name, "cat.")
+			}
+
+			if tag, ok := stringValue(column, i); ok {
+				point.AddTag(name, tag)
+				continue
+			}
+
+			if field, ok := fieldValue(column, i); ok {
+				point.AddField(name, field)
+				hasFields = true
+				continue
+			}
+
+			return nil, fmt.Errorf("unsupported type %s of column '%s'", column.DataType(), schema.Field(j).Name)
+		}
+
+		if hasFields {
+			points = append(points, point)
+		}
+	}
+
+	return points, nil
+}
+
+// Returns the time of the row. Int64 times are in seconds, like the output of the data processors.
+func timeValue(column arrow.Array, i int) (time.Time, error) {
+	switch c := column.(type) {
+	case *array.Int64:
+		return time.Unix(c.Value(i), 0), nil
+	case *array.Timestamp:
+		return c.Value(i).ToTime(c.DataType().(*arrow.TimestampType).Unit), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported type %s of column 'time'", column.DataType())
+}
+
+func stringValue(column arrow.Array, i int) (string, bool) {
+	switch c := column.(type) {
+	case *array.String:
+		return c.Value(i), true
+	case *array.LargeString:
+		return c.Value(i), true
+	case *array.Dictionary:
+		return stringValue(c.Dictionary(), c.GetValueIndex(i))
+	}
+	return "", false
+}
+
+func fieldValue(column arrow.Array, i int) (interface{}, bool) {
+	switch c := column.(type) {
+	case *array.Int8:
+		return int64(c.Value(i)), true
+	case *array.Int16:
+		return int64(c.Value(i)), true
+	case *array.Int32:
+		return int64(c.Value(i)), true
+	case *array.Int64:
+		return c.Value(i), true
+	case *array.Uint8:
+		return uint64(c.Value(i)), true
+	case *array.Uint16:
+		return uint64(c.Value(i)), true
+	case *array.Uint32:
+		return uint64(c.Value(i)), true
+	case *array.Uint64:
+		return c.Value(i), true
+	case *array.Float32:
+		return float64(c.Value(i)), true
+	case *array.Float64:
+		return c.Value(i), true
+	case *array.Boolean:
+		return c.Value(i), true
+	case *array.Decimal128:
+		// Line protocol has no decimal type
+		return c.Value(i).ToFloat64(c.DataType().(*arrow.Decimal128Type).Scale), true
+	case *array.Dictionary:
+		return fieldValue(c.Dictionary(), c.GetValueIndex(i))
+	}
+	return nil, false
+}
+
+func listValues(column arrow.Array, i int) []string {
+	list, ok := column.(*array.List)
+	if !ok {
+		return nil
+	}
+	offsets := list.Offsets()
+	var values []string
+	for j := int(offsets[i]); j < int(offsets[i+1]); j++ {
+		if value, ok := stringValue(list.ListValues(), j); ok && !list.ListValues().IsNull(j) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,256 @@
+package influxdb
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/decimal128"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockServer struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	writes  []string
+	queries []string
+	fail    bool
+}
+
+func newMockServer() *mockServer {
+	s := &mockServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if s.fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		s.writes = append(s.writes, string(body))
+		s.queries = append(s.queries, r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return s
+}
+
+func TestInfluxDbSink(t *testing.T) {
+	server := newMockServer()
+	defer server.Close()
+
+	s := NewInfluxDbSink()
+	err := s.Init(map[string]string{
+		"url":         server.URL,
+		"token":       "fake-token-for-test",
+		"org":         "my-org",
+		"bucket":      "my-bucket",
+		"measurement": "btcusd",
+		"batch_size":  "4",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	record := getTestRecord()
+	defer record.Release()
+
+	// The row without fields is skipped, so the batch isn't full yet
+	assert.NoError(t, s.Write(record))
+	assert.Empty(t, server.writes)
+
+	// Filling the batch writes it
+	assert.NoError(t, s.Write(record))
+	if assert.Len(t, server.writes, 1) {
+		assert.Equal(t, "bucket=my-bucket&org=my-org&precision=s", server.queries[0])
+		assert.Equal(t, strings.Join([]string{
+			`btcusd,side=buy,tags=a\,b close=16339.56,volume=12i 1605312000`,
+			`btcusd,tags=c close=16100.5 1605315600`,
+			`btcusd,side=buy,tags=a\,b close=16339.56,volume=12i 1605312000`,
+			`btcusd,tags=c close=16100.5 1605315600`,
+			"",
+		}, "\n"), server.writes[0])
+	}
+
+	// Points are kept when the write fails
+	server.fail = true
+	assert.NoError(t, s.Write(record))
+	assert.Error(t, s.Flush())
+
+	server.fail = false
+	assert.NoError(t, s.Close())
+	if assert.Len(t, server.writes, 2) {
+		assert.Equal(t, strings.Join([]string{
+			`btcusd,side=buy,tags=a\,b close=16339.56,volume=12i 1605312000`,
+			`btcusd,tags=c close=16100.5 1605315600`,
+			"",
+		}, "\n"), server.writes[1])
+	}
+
+	assert.NoError(t, s.Write(record))
+	assert.Error(t, s.Flush())
+}
+
+// Tests decimal measurements are float fields and dictionaries of strings are tags
+func TestInfluxDbSinkDecimalDictionary(t *testing.T) {
+	server := newMockServer()
+	defer server.Close()
+
+	s := NewInfluxDbSink()
+	err := s.Init(map[string]string{
+		"url":         server.URL,
+		"token":       "fake-token-for-test",
+		"bucket":      "my-bucket",
+		"measurement": "btcusd",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pool := memory.NewGoAllocator()
+	dictionaryType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "id.symbol", Type: dictionaryType},
+		{Name: "measure.close", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}, nil)
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605315600}, nil)
+	symbolBuilder := recordBuilder.Field(1).(*array.BinaryDictionaryBuilder)
+	assert.NoError(t, symbolBuilder.AppendString("BTC-USD"))
+	assert.NoError(t, symbolBuilder.AppendString("ETH-USD"))
+	recordBuilder.Field(2).(*array.Decimal128Builder).AppendValues([]decimal128.Num{decimal128.FromI64(1633956), decimal128.FromI64(-5)}, nil)
+	listBuilder := recordBuilder.Field(3).(*array.ListBuilder)
+	listBuilder.Append(true)
+	listBuilder.Append(true)
+	record := recordBuilder.NewRecord()
+	defer record.Release()
+
+	assert.NoError(t, s.Write(record))
+	assert.NoError(t, s.Close())
+	if assert.Len(t, server.writes, 1) {
+		assert.Equal(t, strings.Join([]string{
+			`btcusd,symbol=BTC-USD close=16339.56 1605312000`,
+			`btcusd,symbol=ETH-USD close=-0.05 1605315600`,
+			"",
+		}, "\n"), server.writes[0])
+	}
+}
+
+// Tests the oldest points are dropped beyond max_pending_points while writes fail
+func TestInfluxDbSinkMaxPendingPoints(t *testing.T) {
+	server := newMockServer()
+	defer server.Close()
+	server.fail = true
+
+	s := NewInfluxDbSink()
+	err := s.Init(map[string]string{
+		"url":                server.URL,
+		"token":              "fake-token-for-test",
+		"bucket":             "my-bucket",
+		"measurement":        "btcusd",
+		"batch_size":         "2",
+		"max_pending_points": "3",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	record := getTestRecord()
+	defer record.Release()
+
+	// Each record has 2 points, so every write flushes and fails
+	for i := 0; i < 5; i++ {
+		assert.Error(t, s.Write(record))
+		assert.LessOrEqual(t, len(s.points), 3)
+	}
+
+	server.mutex.Lock()
+	server.fail = false
+	server.mutex.Unlock()
+	assert.NoError(t, s.Flush())
+	if assert.Len(t, server.writes, 1) {
+		assert.Equal(t, strings.Join([]string{
+			`btcusd,tags=c close=16100.5 1605315600`,
+			`btcusd,side=buy,tags=a\,b close=16339.56,volume=12i 1605312000`,
+			`btcusd,tags=c close=16100.5 1605315600`,
+			"",
+		}, "\n"), server.writes[0])
+	}
+
+	assert.NoError(t, s.Close())
+}
+
+func TestInfluxDbSinkInvalidParams(t *testing.T) {
+	validParams := map[string]string{
+		"url":         "http://localhost:8086",
+		"token":       "fake-token-for-test",
+		"bucket":      "my-bucket",
+		"measurement": "btcusd",
+	}
+
+	invalidParams := []map[string]string{
+		{"url": ""},
+		{"token": ""},
+		{"bucket": ""},
+		{"measurement": ""},
+		{"batch_size": "0"},
+		{"precision": "m"},
+		{"batch_size": "10", "max_pending_points": "5"},
+		{"max_pending_points": "none"},
+	}
+
+	for _, invalid := range invalidParams {
+		params := make(map[string]string)
+		for key, value := range validParams {
+			params[key] = value
+		}
+		for key, value := range invalid {
+			params[key] = value
+		}
+
+		s := NewInfluxDbSink()
+		assert.Error(t, s.Init(params), "params %v", invalid)
+	}
+
+	s := NewInfluxDbSink()
+	assert.NoError(t, s.Init(validParams))
+	assert.NoError(t, s.Close())
+}
+
+func getTestRecord() arrow.Record {
+	pool := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "measure.close", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "volume", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "cat.side", Type: arrow.BinaryTypes.String},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	}, nil)
+
+	recordBuilder := array.NewRecordBuilder(pool, schema)
+	defer recordBuilder.Release()
+
+	recordBuilder.Field(0).(*array.Int64Builder).AppendValues([]int64{1605312000, 1605313800, 1605315600}, nil)
+	recordBuilder.Field(1).(*array.Float64Builder).AppendValues([]float64{16339.56, 0, 16100.5}, []bool{true, false, true})
+	recordBuilder.Field(2).(*array.Int64Builder).AppendValues([]int64{12, 0, 0}, []bool{true, false, false})
+	recordBuilder.Field(3).(*array.StringBuilder).AppendValues([]string{"buy", "sell", ""}, []bool{true, true, false})
+	listBuilder := recordBuilder.Field(4).(*array.ListBuilder)
+	valueBuilder := listBuilder.ValueBuilder().(*array.StringBuilder)
+	listBuilder.Append(true)
+	valueBuilder.AppendValues([]string{"a", "b"}, nil)
+	listBuilder.Append(true)
+	listBuilder.Append(true)
+	valueBuilder.Append("c")
+
+	return recordBuilder.NewRecord()
+}